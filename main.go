@@ -6,6 +6,7 @@ import (
 	"github.com/neo4j/neo4j-go-driver/neo4j"
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
+	"io"
 	"io/ioutil"
 	"log"
 	"math/rand"
@@ -19,35 +20,125 @@ import (
 )
 
 var fInitMode bool
+var fDryRun bool
 var fLatencyMode bool
+var fReportLatencies bool
 var fScale int64
 var fClients int
+var fPoolSize int
 var fRate float64
 var fAddress string
 var fUser string
 var fPassword string
 var fEncryptionMode string
 var fDuration time.Duration
+var fTransactions uint64
+var fWarmup time.Duration
 var fProgress time.Duration
+var fProgressFormat string
+var fNoProgress bool
+var fProgressByWorker bool
+var fProgressKey string
+var fProgressJump float64
+var fTables bool
+var fSummaryOnly bool
+var fWebhook string
+var fOtlpEndpoint string
+var fOtlpTemporality string
+var fTags map[string]string
+var fBaseline string
+var fInterpolatePercentiles bool
+var fSamples bool
+var fTrimRates float64
+var fSampleInterval time.Duration
 var fVariables map[string]string
 var fWorkloads []string
 var fOutputFormat string
+var fPercentiles []float64
+var fPercentileTargets map[string]string
+var fLatencyUnit string
+var fOutputFile string
+var fOutputDestination string
+var fTraceFile string
+var fTopSlow int
+var fCountRecords bool
+var fLatencyMin time.Duration
+var fLatencyMax time.Duration
+var fSignificantFigures int
+var fRunId string
+var fDiagnostics bool
+var fDeterministic bool
+var fAppend bool
+var fNoHeader bool
+var fScenarioSlug bool
+var fCdfPoints int
+var fCompareSort string
+var fPrecision int
+var fMergeHistograms []string
+var fMaxP99 time.Duration
+var fMinRate float64
 
 func init() {
 	pflag.BoolVarP(&fInitMode, "init", "i", false, "when running built-in workloads, run their built-in dataset generator first")
+	pflag.BoolVar(&fDryRun, "dry-run", false, "write what the run would do, its scripts and their weights, clients, duration or transaction count and rate, and exit without running it; custom scripts are still checked against the database with EXPLAIN")
 	pflag.Int64VarP(&fScale, "scale", "s", 1, "sets the `scale` variable, impact depends on workload")
 	pflag.IntVarP(&fClients, "clients", "c", 1, "number of concurrent clients / sessions")
+	pflag.IntVar(&fPoolSize, "pool-size", neobench.DefaultPoolSize, "connections the driver keeps per server; transactions wait for one when they're all in use, which counts towards the connection acquisition time reported")
 	pflag.Float64VarP(&fRate, "rate", "r", 1, "in latency mode (see -l) sets total transactions per second")
 	pflag.StringVarP(&fAddress, "address", "a", "neo4j://localhost:7687", "address to connect to")
 	pflag.StringVarP(&fUser, "user", "u", "neo4j", "username")
 	pflag.StringVarP(&fPassword, "password", "p", "neo4j", "password")
 	pflag.StringVarP(&fEncryptionMode, "encryption", "e", "auto", "whether to use encryption, `auto`, `true` or `false`")
 	pflag.DurationVarP(&fDuration, "duration", "d", 60*time.Second, "duration to run, ex: 15s, 1m, 10h")
-	pflag.DurationVar(&fProgress, "progress", 10*time.Second, "interval to report progress, ex: 15s, 1m, 1h")
+	pflag.Uint64Var(&fTransactions, "transactions", 0, "run this many transactions, split evenly between the clients, rather than for --duration; the run ends once every client is through its share, ex: 100000")
+	pflag.DurationVar(&fWarmup, "warmup", 0, "run the workload for this long before the measured --duration, and report how it did meanwhile as results of their own, in interactive, csv, tsv, json, protobuf and keyvalue output, ex: 30s")
+	pflag.DurationVar(&fProgress, "progress", neobench.DefaultProgressInterval, "interval to report progress, ex: 15s, 1m, 1h")
+	pflag.StringVar(&fProgressFormat, "progress-format", "text", "how to write progress to stderr, `text` or `json` for one JSON object per line, whatever the output format")
+	pflag.BoolVar(&fNoProgress, "no-progress", false, "don't report progress, results and errors are still reported")
+	pflag.StringVar(&fProgressKey, "progress-key", "both", "what progress has to change to be reported before --progress is up, the `section`, the step or both")
+	pflag.Float64Var(&fProgressJump, "progress-jump", 0, "also report progress before --progress is up once completeness moved by this many percentage points, 0 to wait, ex: 5")
+	pflag.BoolVar(&fProgressByWorker, "progress-by-worker", false, "in interactive output, list the progress of each worker under steps several workers share, and of each client through its share of runs by --transactions")
+	pflag.BoolVar(&fInterpolatePercentiles, "interpolate-percentiles", false, "in interactive output, estimate percentiles by interpolating between recorded latencies, for short runs where P99 and P99.9 land on the same value")
+	pflag.BoolVar(&fSummaryOnly, "summary-only", false, "in interactive output, report only the min, max, mean and stddev of latencies, leaving out their distribution")
+	pflag.BoolVar(&fTables, "tables", false, "in interactive output, draw latency summaries and distributions as bordered tables rather than indented lines")
+	pflag.BoolVar(&fSamples, "samples", false, "report throughput and latency for each sample interval while the workload runs, see --sample-interval")
+	pflag.Float64Var(&fTrimRates, "trim-rates", 0, "with --samples, also report the mean rate of the samples without the top and bottom this many percent of them, in interactive and json output, ex: 5")
+	pflag.DurationVar(&fSampleInterval, "sample-interval", time.Second, "interval to take samples at when --samples is set or with -o heatmap or hlog, ex: 1s, 10s")
 	pflag.StringToStringVarP(&fVariables, "define", "D", nil, "defines variables for workload scripts and query parameters")
 	pflag.StringSliceVarP(&fWorkloads, "workload", "w", []string{"builtin:tpcb-like"}, "path to workload script or builtin:[tpcb-like,ldbc-like]")
 	pflag.BoolVarP(&fLatencyMode, "latency", "l", false, "run in latency testing more rather than throughput mode")
-	pflag.StringVarP(&fOutputFormat, "output", "o", "auto", "output format, `auto`, `interactive` or `csv`")
+	pflag.BoolVar(&fReportLatencies, "report-latencies", false, "in throughput mode, report the latency distribution alongside the throughput")
+	pflag.StringVarP(&fOutputFormat, "output", "o", "auto", "output format, `auto`, `interactive`, `csv`, `tsv`, `json`, `prometheus`, `influx`, `markdown`, `html`, `hgrm`, `cdf`, `histogram`, `oneline`, `keyvalue`, `gobench`, `compare`, `heatmap`, `hlog`, `protobuf` or `quiet`, quiet is csv without progress output")
+	pflag.StringVar(&fOutputFile, "output-file", "", "write results to this file rather than stdout, gzip-compressed if it ends in .gz; progress is still written to stderr")
+	pflag.StringVar(&fOutputDestination, "output-destination", "", "stream results to a collector at tcp://host:port or unix:///path rather than stdout, progress is still written to stderr")
+	pflag.BoolVar(&fDeterministic, "deterministic", false, "leave timestamps, durations and progress timings out of the output, so runs with the same results write the same output, eg. for golden-file tests")
+	pflag.BoolVar(&fDiagnostics, "diagnostics", false, "report heap and GC stats of neobench itself over the run, to tell client-side pauses from server latency; in interactive and json output")
+	pflag.StringVar(&fBaseline, "baseline", "", "results written with -o json by an earlier run, to show the change in rate and latencies from in interactive output and as _delta columns in csv and tsv")
+	pflag.StringVar(&fWebhook, "webhook", "", "post a json summary of the results to this url once the run is done, eg. a Slack incoming webhook; failing to post is warned about, within 10s")
+	pflag.StringVar(&fOtlpEndpoint, "otlp-endpoint", "", "push the results as opentelemetry metrics to this otlp/http collector once the run is done, ex: http://localhost:4318; failing to push is warned about, after retrying for up to 10s")
+	pflag.StringVar(&fOtlpTemporality, "otlp-temporality", "cumulative", "temporality of the counters and histograms pushed with --otlp-endpoint, cumulative or delta, for backends that only take one of them")
+	pflag.StringVar(&fRunId, "run-id", "", "identify every result of this run with this id, for storage to dedupe and group results by; csv, tsv and keyvalue output get a run_id column or key, json and protobuf a run_id field, prometheus, influx and otlp a label, interactive, html, oneline, gobench and hlog a line or field of their own; markdown, compare, hgrm, cdf, histogram and heatmap output leave it out (default a random uuid, none with --deterministic)")
+	pflag.IntVar(&fTopSlow, "top-slow", 0, "list this many of the slowest transactions with their script and parameters, in interactive and json output, ex: 10")
+	pflag.BoolVar(&fCountRecords, "count-records", false, "count the records transactions return and estimate their size, reported as records and bytes per second; records are read while transactions are timed, so this adds to the latency measured")
+	pflag.StringVar(&fTraceFile, "trace-file", "", "write every transaction's start time, latency and script to this file as csv, for lining latencies up with GC logs and the like; about 40 bytes per transaction, gzip-compressed if it ends in .gz")
+	pflag.BoolVar(&fAppend, "output-append", false, "append to --output-file rather than overwriting it, locking the file for each write so concurrent runs can share it; csv and tsv headers are only written to an empty file")
+	pflag.BoolVar(&fAppend, "append", false, "")
+	_ = pflag.CommandLine.MarkDeprecated("append", "use --output-append instead")
+	pflag.BoolVar(&fNoHeader, "no-header", false, "leave out csv and tsv header rows")
+	pflag.StringToStringVar(&fTags, "tag", nil, "label every result with this tag, repeatable, ex: --tag pool_size=50; csv and tsv output get a tag_<key> column per tag, json a tags field and prometheus and influx a label")
+	pflag.BoolVar(&fScenarioSlug, "scenario-slug", false, "add a scenario_slug column to csv and tsv output, the scenario lowercased with spaces as underscores and without path separators, for naming files after; json output always has it")
+	pflag.StringVar(&fCompareSort, "compare-sort", "", "order -o compare rows by `scenario`, `rate`, `mean` or a percentile like p99, in the order they were reported if not set")
+	pflag.IntVar(&fCdfPoints, "cdf-points", neobench.DefaultCdfPoints, "number of rows to write with -o cdf, evenly spaced between the lowest and highest latency")
+	pflag.StringVar(&fLatencyUnit, "latency-unit", "ms", "unit to show latencies in, `us`, `ms`, `s` or `auto` to pick one by how long latencies are; json, prometheus, influx, protobuf, hgrm, cdf and histogram output always use ms")
+	pflag.DurationVar(&fLatencyMin, "latency-min", neobench.DefaultHistogramConfig.Min, "lowest latency the histograms record, lower ones are recorded as this; latencies are told apart no finer than this, in return for less memory, ex: 100us")
+	pflag.DurationVar(&fLatencyMax, "latency-max", neobench.DefaultHistogramConfig.Max, "highest latency the histograms record, higher ones are recorded as this and warned about, ex: 10s")
+	pflag.IntVar(&fSignificantFigures, "significant-figures", neobench.DefaultHistogramConfig.SignificantFigures, "significant figures latencies are recorded with, 1 to 5; each one more takes ten times the memory")
+	pflag.IntVar(&fPrecision, "precision", neobench.DefaultPrecision, "decimal places in latencies and rates, 0 to 9; for interactive, csv, tsv, markdown, html, oneline, keyvalue, compare and heatmap output")
+	pflag.Float64SliceVar(&fPercentiles, "percentiles", nil, "latency percentiles to report, ex: 50,90,99.9 (default depends on output format)")
+	pflag.StringToStringVar(&fPercentileTargets, "percentile-targets", nil, "latency targets to mark as met or missed in interactive, csv and tsv output, ex: 99=20ms,99.9=50ms")
+	pflag.DurationVar(&fMaxP99, "fail-if-p99-above", 0, "exit non-zero if P99 latency across all scripts is above this, ex: 50ms")
+	pflag.Float64Var(&fMinRate, "fail-if-tps-below", 0, "exit non-zero if total transactions per second is below this")
+	pflag.StringSliceVar(&fMergeHistograms, "merge-histograms", nil, "rather than running a benchmark, merge histogram files written with -o histogram and report their combined latencies")
 }
 
 func main() {
@@ -70,11 +161,17 @@ Options:
 	seed := time.Now().Unix()
 	scenario := describeScenario()
 
-	out, err := neobench.NewOutput(fOutputFormat)
-	if err != nil {
-		log.Fatal(err)
+	// Everything is validated before the output file is opened, so a mistyped flag doesn't leave it truncated
+	if fTrimRates > 0 && samplingInterval() == 0 {
+		log.Fatal("--trim-rates trims the rates of samples, so it needs --samples")
+	}
+	if fOutputFile != "" && fOutputDestination != "" {
+		log.Fatal("--output-file and --output-destination can't be used together, results go to one or the other")
+	}
+	// Rows are compressed as they're written, so separate runs' writes can't be interleaved in one file
+	if fAppend && neobench.IsGzipPath(fOutputFile) {
+		log.Fatal("--output-append can't be used with a .gz --output-file")
 	}
-
 	var encryptionMode neobench.EncryptionMode
 	switch strings.ToLower(fEncryptionMode) {
 	case "auto":
@@ -87,12 +184,147 @@ Options:
 		log.Fatalf("Invalid encryption mode '%s', needs to be one of 'auto', 'true' or 'false'", fEncryptionMode)
 	}
 
+	if fTopSlow < 0 || fTopSlow > neobench.MaxTopSlow {
+		log.Fatalf("Invalid top slow %d, needs to be between 0 and %d", fTopSlow, neobench.MaxTopSlow)
+	}
+	if err := histogramConfig().Validate(); err != nil {
+		log.Fatal(err)
+	}
+	if fTransactions > 0 && fTransactions < uint64(fClients) {
+		log.Fatalf("Invalid transactions %d, needs to be at least the number of clients, %d", fTransactions, fClients)
+	}
+	if fTransactions > 0 && fWarmup > 0 {
+		log.Fatalf("--warmup goes by time, so it can't be used with --transactions")
+	}
+	if fPoolSize < 1 {
+		log.Fatalf("Invalid pool size %d, needs to be at least 1", fPoolSize)
+	}
+
+	latencyUnit, err := neobench.ParseLatencyUnit(fLatencyUnit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	percentileTargets, err := neobench.ParsePercentileTargets(fPercentileTargets)
+	if err != nil {
+		log.Fatal(err)
+	}
+	baseline, err := readBaseline(fBaseline)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// A random id would make otherwise identical runs write different output
+	runId := fRunId
+	if runId == "" && !fDeterministic {
+		if runId, err = neobench.NewRunId(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	options := neobench.OutputOptions{
+		Percentiles:            fPercentiles,
+		PercentileTargets:      percentileTargets,
+		LatencyUnit:            latencyUnit,
+		Destination:            fOutputDestination,
+		ProgressInterval:       fProgress,
+		ProgressFormat:         fProgressFormat,
+		NoProgress:             fNoProgress,
+		ProgressByWorker:       fProgressByWorker,
+		ProgressKey:            fProgressKey,
+		ProgressJump:           fProgressJump,
+		Tables:                 fTables,
+		InterpolatePercentiles: fInterpolatePercentiles,
+		SummaryOnly:            fSummaryOnly,
+		TrimRates:              fTrimRates,
+		OmitHeader:             fNoHeader,
+		ScenarioSlug:           fScenarioSlug,
+		CdfPoints:              fCdfPoints,
+		CompareSortBy:          fCompareSort,
+		Webhook:                fWebhook,
+		OtlpEndpoint:           fOtlpEndpoint,
+		OtlpTemporality:        fOtlpTemporality,
+		Tags:                   fTags,
+		RunId:                  runId,
+		Warmup:                 fWarmup > 0,
+		Samples:                samplingInterval() > 0,
+		Baseline:               baseline,
+		Precision:              &fPrecision,
+		Deterministic:          fDeterministic,
+	}
+	if err := neobench.ValidateOutputOptions(fOutputFormat, options); err != nil {
+		log.Fatal(err)
+	}
+
+	var resultsFile io.Closer
+	var outStream io.Writer = os.Stdout
+	if fOutputFile != "" {
+		if neobench.IsGzipPath(fOutputFile) {
+			f, err := neobench.CreateGzipFile(fOutputFile)
+			if err != nil {
+				log.Fatalf("failed to create output file: %s", err)
+			}
+			resultsFile, outStream = f, f
+		} else if fAppend {
+			// Csv output asks the file whether it needs a header each time it writes one, see neobench.AppendFile
+			f, err := neobench.OpenAppendFile(fOutputFile)
+			if err != nil {
+				log.Fatalf("failed to open output file: %s", err)
+			}
+			resultsFile, outStream = f.File, f
+		} else {
+			f, err := os.Create(fOutputFile)
+			if err != nil {
+				log.Fatalf("failed to create output file: %s", err)
+			}
+			resultsFile, outStream = f, f
+		}
+	}
+	options.OutStream = outStream
+	out, err := neobench.NewOutput(fOutputFormat, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// os.Exit skips deferred calls, so close the results file explicitly before exiting
+	exit := func(code int) {
+		// Some outputs only write their results now
+		if err := out.Close(); err != nil && code == 0 {
+			code = errorExitCode(out, errors.Wrap(err, "failed to write results"))
+		}
+		// Errors reported along the way fail the run, even if it got as far as reporting results
+		if code == 0 && out.ErrorsReported() {
+			code = 1
+		}
+		if resultsFile != nil {
+			if err := resultsFile.Close(); err != nil {
+				out.Errorf("failed to close output file: %s", err)
+				code = 1
+			}
+		}
+		os.Exit(code)
+	}
+
+	if len(fMergeHistograms) > 0 {
+		result, err := mergeHistograms(fMergeHistograms)
+		if err != nil {
+			exit(errorExitCode(out, err))
+		}
+		// Outputs write their header here, eg. csv
+		if err := out.BenchmarkStart(result.DatabaseName, strings.Join(fMergeHistograms, ", "), result.Scenario); err != nil {
+			exit(errorExitCode(out, errors.Wrap(err, "failed to write results")))
+		}
+		if err := out.ReportLatency(result); err != nil {
+			exit(errorExitCode(out, errors.Wrap(err, "failed to write results")))
+		}
+		neobench.CheckSucceeded(out, result)
+		exit(0)
+	}
+
 	dbName := ""
 	if pflag.NArg() > 0 {
 		dbName = pflag.Arg(0)
 	}
 
-	driver, err := neobench.NewDriver(fAddress, fUser, fPassword, encryptionMode)
+	driver, err := neobench.NewDriver(fAddress, fUser, fPassword, encryptionMode, fPoolSize)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -118,6 +350,13 @@ Options:
 		log.Fatalf("%+v", err)
 	}
 
+	if fDryRun {
+		if err := out.ReportPlan(planRun(dbName, scenario, wrk)); err != nil {
+			exit(errorExitCode(out, errors.Wrap(err, "failed to write plan")))
+		}
+		exit(0)
+	}
+
 	if fInitMode {
 		err = initWorkload(fWorkloads, dbName, fScale, seed, driver, out)
 		if err != nil {
@@ -125,38 +364,121 @@ Options:
 		}
 	}
 
-	if fDuration == 0 {
+	if fDuration == 0 && fTransactions == 0 {
 		fmt.Printf("Duration (--duration) is 0, exiting without running any load\n")
-		os.Exit(0)
+		exit(0)
+	}
+
+	trace, closeTrace, err := openTrace(out)
+	if err != nil {
+		log.Fatalf("failed to create trace file: %s", err)
 	}
 
 	if fLatencyMode {
-		result, err := runBenchmark(driver, fAddress, dbName, scenario, out, wrk, fDuration, fLatencyMode, fClients, fRate, fProgress)
+		warmup, result, err := runBenchmark(driver, fAddress, dbName, scenario, out, wrk, fWarmup, fDuration, fTransactions, fLatencyMode, fClients, fPoolSize, fRate, fProgress, fProgressByWorker, samplingInterval(), trace, fTopSlow, histogramConfig(), fCountRecords, fDiagnostics)
+		closeTrace()
 		if err != nil {
-			out.Errorf(err.Error())
-			os.Exit(1)
+			exit(errorExitCode(out, err))
 		}
-		out.ReportLatency(result)
+		if warmup != nil {
+			if err := out.ReportLatency(*warmup); err != nil {
+				exit(errorExitCode(out, errors.Wrap(err, "failed to write warmup results")))
+			}
+		}
+		if err := out.ReportLatency(result); err != nil {
+			exit(errorExitCode(out, errors.Wrap(err, "failed to write results")))
+		}
+		neobench.CheckSucceeded(out, result)
+		neobench.CheckLatencyRange(out, result)
+		neobench.CheckRate(out, result)
+		neobench.CheckRateStability(out, result)
+		neobench.CheckClientCpu(out, result)
+		neobench.CheckThresholds(out, result, neobench.Thresholds{MaxP99: fMaxP99, MinRate: fMinRate})
 		if result.TotalFailed() == 0 {
-			os.Exit(0)
+			exit(0)
 		} else {
-			os.Exit(1)
+			exit(1)
 		}
 	} else {
-		result, err := runBenchmark(driver, fAddress, dbName, scenario, out, wrk, fDuration, fLatencyMode, fClients, fRate, fProgress)
+		warmup, result, err := runBenchmark(driver, fAddress, dbName, scenario, out, wrk, fWarmup, fDuration, fTransactions, fLatencyMode, fClients, fPoolSize, fRate, fProgress, fProgressByWorker, samplingInterval(), trace, fTopSlow, histogramConfig(), fCountRecords, fDiagnostics)
+		closeTrace()
 		if err != nil {
-			out.Errorf(err.Error())
-			os.Exit(1)
+			exit(errorExitCode(out, err))
 		}
-		out.ReportThroughput(result)
+		if warmup != nil {
+			if err := neobench.ReportThroughputResult(out, *warmup, fReportLatencies); err != nil {
+				exit(errorExitCode(out, errors.Wrap(err, "failed to write warmup results")))
+			}
+		}
+		err = neobench.ReportThroughputResult(out, result, fReportLatencies)
+		if err != nil {
+			exit(errorExitCode(out, errors.Wrap(err, "failed to write results")))
+		}
+		neobench.CheckSucceeded(out, result)
+		neobench.CheckLatencyRange(out, result)
+		neobench.CheckRateStability(out, result)
+		neobench.CheckClientCpu(out, result)
+		neobench.CheckThresholds(out, result, neobench.Thresholds{MaxP99: fMaxP99, MinRate: fMinRate})
 		if result.TotalFailed() == 0 {
-			os.Exit(0)
+			exit(0)
 		} else {
-			os.Exit(1)
+			exit(1)
 		}
 	}
 }
 
+// What latencies are recorded with, from --latency-min, --latency-max and --significant-figures
+func histogramConfig() neobench.HistogramConfig {
+	return neobench.HistogramConfig{Min: fLatencyMin, Max: fLatencyMax, SignificantFigures: fSignificantFigures}
+}
+
+// Results to compare against if --baseline is set, nil otherwise
+func readBaseline(path string) (*neobench.Baseline, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open baseline")
+	}
+	defer f.Close()
+	baseline, err := neobench.ReadBaseline(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read baseline from %s", path)
+	}
+	return baseline, nil
+}
+
+// Combines histograms written with `-o histogram` into a single-script result, to report on several runs as a whole
+func mergeHistograms(paths []string) (neobench.Result, error) {
+	result := neobench.NewResult("", fmt.Sprintf("--merge-histograms %s", strings.Join(paths, ",")))
+	merged := &neobench.ScriptResult{ScriptName: "merged"}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return result, errors.Wrap(err, "failed to open histogram file")
+		}
+		histograms, err := neobench.ReadHistograms(f)
+		_ = f.Close()
+		if err != nil {
+			return result, errors.Wrapf(err, "failed to read histograms from %s", path)
+		}
+		for _, h := range histograms {
+			if merged.Latencies == nil {
+				merged.Latencies = h
+			} else {
+				merged.Latencies.Merge(h)
+			}
+		}
+	}
+	if merged.Latencies == nil {
+		return result, fmt.Errorf("no histograms found in %s", strings.Join(paths, ", "))
+	}
+	merged.Succeeded = merged.Latencies.TotalCount()
+	result.Scripts[merged.ScriptName] = merged
+	return result, nil
+}
+
 func createWorkload(driver neo4j.Driver, dbName string, variables map[string]interface{}, seed int64) (neobench.Workload, error) {
 	var err error
 	scripts := make([]neobench.Script, 0)
@@ -273,6 +595,27 @@ func loadBuiltinWorkload(path string, weight float64) ([]neobench.Script, error)
 	return []neobench.Script{}, fmt.Errorf("unknown built-in workload: %s, supported built-in workloads are 'tpcb-like', 'match-only' and 'ldbc-like'", path)
 }
 
+// What runBenchmark would do with the flags given
+func planRun(dbName, scenario string, wrk neobench.Workload) neobench.Plan {
+	plan := neobench.Plan{
+		DatabaseName: dbName,
+		Scenario:     scenario,
+		Config:       neobench.RunConfig{Clients: fClients, Duration: fDuration, Transactions: fTransactions},
+		Init:         fInitMode,
+	}
+	// --duration doesn't apply to runs that go by count
+	if fTransactions > 0 {
+		plan.Config.Duration = 0
+	}
+	if fLatencyMode {
+		plan.Config.TargetRate = fRate
+	}
+	for _, script := range wrk.Scripts.Scripts {
+		plan.Scripts = append(plan.Scripts, neobench.PlannedScript{Name: script.Name, Weight: script.Weight, Readonly: script.Readonly})
+	}
+	return plan
+}
+
 func describeScenario() string {
 	out := strings.Builder{}
 	for _, path := range fWorkloads {
@@ -280,7 +623,11 @@ func describeScenario() string {
 	}
 	out.WriteString(fmt.Sprintf(" -c %d", fClients))
 	out.WriteString(fmt.Sprintf(" -s %d", fScale))
-	out.WriteString(fmt.Sprintf(" -d %s", fDuration))
+	if fTransactions > 0 {
+		out.WriteString(fmt.Sprintf(" --transactions %d", fTransactions))
+	} else {
+		out.WriteString(fmt.Sprintf(" -d %s", fDuration))
+	}
 	out.WriteString(fmt.Sprintf(" -e %s", fEncryptionMode))
 	if fLatencyMode {
 		out.WriteString(fmt.Sprintf(" -l -r %.3f", fRate))
@@ -291,8 +638,11 @@ func describeScenario() string {
 	return out.String()
 }
 
+// Runs the workload for warmup and then for runtime, or until the clients ran transactions between them if it's
+// set, giving results of each; the warmup results are nil without a warmup
 func runBenchmark(driver neo4j.Driver, url, databaseName, scenario string, out neobench.Output, wrk neobench.Workload,
-	runtime time.Duration, latencyMode bool, numClients int, rate float64, progressInterval time.Duration) (neobench.Result, error) {
+	warmup, runtime time.Duration, transactions uint64, latencyMode bool, numClients, poolSize int, rate float64, progressInterval time.Duration, progressByWorker bool,
+	sampleInterval time.Duration, trace *neobench.TraceWriter, topSlow int, histogram neobench.HistogramConfig, countRecords, diagnostics bool) (*neobench.Result, neobench.Result, error) {
 	stopCh, stop := neobench.SetupSignalHandler()
 	defer stop()
 
@@ -301,35 +651,134 @@ func runBenchmark(driver neo4j.Driver, url, databaseName, scenario string, out n
 		ratePerWorkerDuration = neobench.TotalRatePerSecondToDurationPerClient(numClients, rate)
 	}
 
-	out.BenchmarkStart(databaseName, url, scenario)
+	config := neobench.RunConfig{Clients: numClients, Duration: runtime}
+	if transactions > 0 {
+		config.Duration, config.Transactions = 0, transactions
+	}
+	if latencyMode {
+		config.TargetRate = rate
+	}
 
+	// Not knowing the server version is no reason not to run the benchmark
+	serverVersion, err := neobench.ServerVersion(driver, databaseName)
+	if err != nil {
+		out.Warnf("failed to query Neo4j version: %s", err)
+	}
+
+	if err := out.BenchmarkStart(databaseName, url, scenario); err != nil {
+		return nil, neobench.Result{}, err
+	}
+
+	// Read before the workers start, so reading it doesn't pause any transaction
+	memStart := neobench.ReadMemStats()
+	concurrency := neobench.NewConcurrencyTracker()
+	pool := neobench.NewConnectionPool(poolSize)
+	cpu := neobench.StartCpuTracker(time.Second)
+	defer cpu.Stop()
 	resultChan := make(chan neobench.WorkerResult, numClients)
 	resultRecorders := make([]*neobench.ResultRecorder, 0)
+	shares := neobench.TotalTransactionsToTransactionsPerClient(numClients, transactions)
 	var wg sync.WaitGroup
 	for i := 0; i < numClients; i++ {
 		wg.Add(1)
-		recorder := neobench.NewResultRecorder(int64(i))
+		recorder := neobench.NewResultRecorder(int64(i), histogram)
+		recorder.Trace = trace
+		recorder.Concurrency = concurrency
+		recorder.Pool = pool
+		recorder.TopSlow = topSlow
+		recorder.CountRecords = countRecords
 		resultRecorders = append(resultRecorders, recorder)
 		worker := neobench.NewWorker(driver, int64(i))
 		workerId := i
 		clientWork := wrk.NewClient()
+		// Zero, as with no transactions to run, runs until stopped
+		share := shares[i]
 		go func() {
 			defer wg.Done()
-			result := worker.RunBenchmark(clientWork, databaseName, ratePerWorkerDuration, 0, stopCh, recorder)
+			result := worker.RunBenchmark(clientWork, databaseName, ratePerWorkerDuration, share, stopCh, recorder)
 			resultChan <- result
 			if result.Error != nil {
-				out.Errorf("worker %d crashed: %s", workerId, result.Error)
+				out.ReportError(neobench.ClassifyError(result.Error), errors.Wrapf(result.Error, "worker %d crashed", workerId))
 				stop()
 			}
 		}()
 	}
-
-	deadline := time.Now().Add(runtime)
-	awaitCompletion(stopCh, deadline, out, databaseName, scenario, progressInterval, resultRecorders)
+	// Closed once every client is through its share of transactions
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	startTime := time.Now()
+	var warmupResult *neobench.Result
+	if warmup > 0 {
+		warmupConfig := config
+		warmupConfig.Duration = warmup
+		sampleRates, err := awaitCompletion(stopCh, finished, startTime.Add(warmup), out, databaseName, scenario, warmupConfig, true, progressInterval, false, sampleInterval, resultRecorders)
+		interrupted := isClosed(stopCh)
+		if err != nil {
+			stop()
+			wg.Wait()
+			return nil, neobench.Result{}, err
+		}
+		// Workers carry on; what they did so far is the warmup, and the measured run starts over from here
+		endTime := time.Now()
+		result := neobench.NewResult(databaseName, scenario)
+		for _, r := range resultRecorders {
+			result.Add(r.Complete(endTime))
+			// The warmup's last, partial sample would otherwise count towards the first of the measured run
+			r.SampleReport(endTime)
+		}
+		result.Warmup = true
+		result.Interrupted = interrupted
+		result.Config = warmupConfig
+		result.StartTime, result.EndTime = startTime, endTime
+		result.Duration = endTime.Sub(startTime)
+		result.NeobenchVersion = neobench.Version
+		result.Neo4jVersion = serverVersion
+		result.SampleRates = sampleRates
+		result.Concurrency = concurrency.Restart(result.Duration)
+		result.PoolWait = pool.Restart()
+		result.ClientCpu = cpu.Restart()
+		warmupResult = &result
+		startTime = endTime
+	}
+	deadline := startTime.Add(runtime)
+	sampleRates, err := awaitCompletion(stopCh, finished, deadline, out, databaseName, scenario, config, false, progressInterval, progressByWorker, sampleInterval, resultRecorders)
+	// Stopped before the deadline or the clients' last transaction, by a signal or a crashing worker; the workers
+	// still hand in what they did
+	interrupted := isClosed(stopCh)
 	stop()
 	wg.Wait()
+	if err != nil {
+		return nil, neobench.Result{}, err
+	}
 
-	return collectResults(databaseName, scenario, out, numClients, resultChan)
+	result, err := collectResults(databaseName, scenario, out, numClients, resultChan)
+	result.Config = config
+	result.StartTime, result.EndTime = startTime, time.Now()
+	result.Duration = result.EndTime.Sub(startTime)
+	result.NeobenchVersion = neobench.Version
+	result.Neo4jVersion = serverVersion
+	result.SampleRates = sampleRates
+	result.Concurrency = concurrency.Summary(result.Duration)
+	result.PoolWait = pool.Summary()
+	result.ClientCpu = cpu.Summary()
+	result.Interrupted = interrupted
+	if diagnostics {
+		result.Process = neobench.ProcessStatsSince(memStart)
+	}
+	return warmupResult, result, err
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
 }
 
 func collectResults(databaseName, scenario string, out neobench.Output, concurrency int, resultChan chan neobench.WorkerResult) (neobench.Result, error) {
@@ -343,7 +792,7 @@ func collectResults(databaseName, scenario string, out neobench.Output, concurre
 	// Process results into one histogram and check for errors
 	for _, res := range results {
 		if res.Error != nil {
-			out.Errorf("Worker failed: %v", res.Error)
+			out.ReportError(neobench.ClassifyError(res.Error), errors.Wrap(res.Error, "Worker failed"))
 			continue
 		}
 		total.Add(res)
@@ -352,6 +801,16 @@ func collectResults(databaseName, scenario string, out neobench.Output, concurre
 	return total, nil
 }
 
+// Reports err and gives the exit code to use; a closed pipe just means the reader is done with us,
+// so that exits quietly
+func errorExitCode(out neobench.Output, err error) int {
+	if neobench.IsBrokenPipe(err) {
+		return 0
+	}
+	out.Errorf(err.Error())
+	return 1
+}
+
 func initWorkload(paths []string, dbName string, scale, seed int64, driver neo4j.Driver, out neobench.Output) error {
 	for _, path := range paths {
 		if path == "builtin:tpcb-like" {
@@ -367,33 +826,110 @@ func initWorkload(paths []string, dbName string, scale, seed int64, driver neo4j
 	return nil
 }
 
-func awaitCompletion(stopCh chan struct{}, deadline time.Time, out neobench.Output, databaseName, scenario string, progressInterval time.Duration, recorders []*neobench.ResultRecorder) {
+// Trace to record transactions to if --trace-file is set, nil otherwise, and a function that writes out the
+// rest of it once the run is over; failing to write the trace is reported, but leaves the results alone
+func openTrace(out neobench.Output) (*neobench.TraceWriter, func(), error) {
+	if fTraceFile == "" {
+		return nil, func() {}, nil
+	}
+	var f io.WriteCloser
+	var err error
+	if neobench.IsGzipPath(fTraceFile) {
+		f, err = neobench.CreateGzipFile(fTraceFile)
+	} else {
+		f, err = os.Create(fTraceFile)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !neobench.IsGzipPath(fTraceFile) {
+		out.Warnf("tracing every transaction to %s, which grows by about %d bytes per transaction; at 10000 transactions per second that's %.1fGB an hour; name it .gz to compress it",
+			fTraceFile, neobench.TraceBytesPerTransaction, float64(neobench.TraceBytesPerTransaction*10000*3600)/1e9)
+	}
+	trace := neobench.NewTraceWriter(f)
+	return trace, func() {
+		if err := trace.Flush(); err != nil {
+			out.Errorf("failed to write trace file: %s", err)
+		}
+		if err := f.Close(); err != nil {
+			out.Errorf("failed to close trace file: %s", err)
+		}
+	}, nil
+}
+
+// Sampling interval to use, zero unless --samples is set; heatmap and hlog output are made of samples, so they always are
+func samplingInterval() time.Duration {
+	if !fSamples && fOutputFormat != "heatmap" && fOutputFormat != "hlog" {
+		return 0
+	}
+	return fSampleInterval
+}
+
+// Waits until the deadline, or until finished is closed for runs that go by config.Transactions; sampleInterval
+// of zero disables sampling; returns the total rate of each sample taken
+func awaitCompletion(stopCh, finished chan struct{}, deadline time.Time, out neobench.Output, databaseName, scenario string, config neobench.RunConfig, warmup bool,
+	progressInterval time.Duration, progressByWorker bool, sampleInterval time.Duration, recorders []*neobench.ResultRecorder) ([]float64, error) {
 	nextProgressReport := time.Now().Add(progressInterval)
+	sampleStart := time.Now()
 	originalDelta := deadline.Sub(time.Now()).Seconds()
+	// Transactions run so far, for the completeness of runs that go by count
+	var ran int64
+	shares := neobench.TotalTransactionsToTransactionsPerClient(len(recorders), config.Transactions)
+	var sampleRates []float64
 	for {
 		select {
 		case <-stopCh:
-			return
+			return sampleRates, nil
+		case <-finished:
+			return sampleRates, nil
 		default:
 		}
 
 		now := time.Now()
 		delta := deadline.Sub(now)
-		if delta < 2*time.Second {
+		if config.Transactions == 0 && delta < 2*time.Second {
 			time.Sleep(delta)
 			break
 		}
 
+		if sampleInterval > 0 && now.Sub(sampleStart) >= sampleInterval {
+			sample := neobench.IntervalResult{Result: neobench.NewResult(databaseName, scenario), Start: sampleStart, End: now}
+			sample.Config = config
+			sample.Warmup = warmup
+			sample.Duration = now.Sub(sampleStart)
+			for _, r := range recorders {
+				sample.Add(r.SampleReport(now))
+			}
+			sampleStart = now
+			sampleRates = append(sampleRates, sample.TotalRate())
+			if err := out.ReportInterval(sample); err != nil {
+				return sampleRates, err
+			}
+		}
+
+		// Reported on every pass rather than every progress interval, outputs rate-limit progress themselves
+		if progressByWorker && config.Transactions > 0 {
+			neobench.ReportClientProgress(out, recorders, shares)
+		}
+
 		if now.After(nextProgressReport) {
 			nextProgressReport = nextProgressReport.Add(progressInterval)
 			checkpoint := neobench.NewResult(databaseName, scenario)
+			checkpoint.Config = config
 			for _, r := range recorders {
 				checkpoint.Add(r.ProgressReport(time.Now()))
 			}
 
 			completeness := 1 - delta.Seconds()/originalDelta
-			out.ReportWorkloadProgress(completeness, checkpoint)
+			if config.Transactions > 0 {
+				ran += checkpoint.TotalSucceeded() + checkpoint.TotalFailed()
+				completeness = float64(ran) / float64(config.Transactions)
+			}
+			if err := out.ReportWorkloadProgress(completeness, checkpoint); err != nil {
+				return sampleRates, err
+			}
 		}
 		time.Sleep(time.Millisecond * 100)
 	}
+	return sampleRates, nil
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Runs main with the arguments after "--" when the test binary is started by runNeobench, since main exits
+func TestMain(m *testing.M) {
+	if os.Getenv("NEOBENCH_TEST_MAIN") != "" {
+		for i, arg := range os.Args {
+			if arg == "--" {
+				os.Args = append([]string{"neobench"}, os.Args[i+1:]...)
+				break
+			}
+		}
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func runNeobench(t *testing.T, args ...string) error {
+	cmd := exec.Command(os.Args[0], append([]string{"--"}, args...)...)
+	cmd.Env = append(os.Environ(), "NEOBENCH_TEST_MAIN=1")
+	output, err := cmd.CombinedOutput()
+	t.Log(string(output))
+	return err
+}
+
+func TestBadFlagsLeaveTheOutputFileAlone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.csv")
+
+	for _, args := range [][]string{
+		{"--pool-size", "0"},
+		{"--latency-unit", "fortnights"},
+		{"--tag", "run_id=1"},
+		{"-o", "nonsense"},
+	} {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("earlier results\n"), 0644))
+		err := runNeobench(t, append([]string{"--output-file", path, "-e=false", "-d", "1s"}, args...)...)
+		assert.Error(t, err, "%v", args)
+		written, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "earlier results\n", string(written), "%v", args)
+	}
+}
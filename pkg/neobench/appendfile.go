@@ -0,0 +1,44 @@
+package neobench
+
+import "os"
+
+// A results file shared with other neobench processes, opened for appending so several runs can collect their
+// results in one CSV. Each write holds an advisory lock on the file, so rows from concurrent runs don't
+// interleave, and header rows written with WriteWithHeader only go in if the file is empty while the lock is
+// held, so the file gets exactly one header however many runs append to it.
+type AppendFile struct {
+	*os.File
+}
+
+func OpenAppendFile(path string) (*AppendFile, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &AppendFile{File: f}, nil
+}
+
+func (f *AppendFile) Write(p []byte) (int, error) {
+	if err := lockFile(f.File); err != nil {
+		return 0, err
+	}
+	defer unlockFile(f.File)
+	return f.File.Write(p)
+}
+
+// Writes rows, led by header if nothing has been written to the file yet
+func (f *AppendFile) WriteWithHeader(header, rows string) error {
+	if err := lockFile(f.File); err != nil {
+		return err
+	}
+	defer unlockFile(f.File)
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > 0 {
+		header = ""
+	}
+	_, err = f.File.WriteString(header + rows)
+	return err
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package neobench
+
+import (
+	"os"
+	"syscall"
+)
+
+// Blocks until no other process holds the lock
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,158 @@
+package neobench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Results of an earlier run to compare against, read with ReadBaseline from what json output wrote, so tuning
+// can be tracked as "+12% tps, -8% P99" rather than by diffing results by hand
+type Baseline struct {
+	Total   BaselineScript
+	Scripts map[string]BaselineScript
+}
+
+// Rate is in transactions per second, latencies in microseconds like everywhere else. Percentiles are by
+// percentileColumnName, and only the ones the earlier run reported are known
+type BaselineScript struct {
+	Rate float64
+	// False if the earlier run had no latencies for the script, in which case Mean and Percentiles are empty
+	Latencies   bool
+	Mean        float64
+	Percentiles map[string]float64
+}
+
+// Reads the last result in output written with -o json
+func ReadBaseline(r io.Reader) (*Baseline, error) {
+	dec := json.NewDecoder(r)
+	var last *jsonResult
+	for {
+		doc := jsonResult{}
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		last = &doc
+	}
+	if last == nil {
+		return nil, fmt.Errorf("no results found, baselines are results written with -o json")
+	}
+	baseline := &Baseline{Total: baselineScript(last.Total), Scripts: make(map[string]BaselineScript, len(last.Scripts))}
+	for _, script := range last.Scripts {
+		baseline.Scripts[script.Script] = baselineScript(script)
+	}
+	return baseline, nil
+}
+
+func baselineScript(doc jsonScriptResult) BaselineScript {
+	script := BaselineScript{Rate: doc.Rate, Percentiles: make(map[string]float64)}
+	if doc.Latency == nil {
+		return script
+	}
+	script.Latencies, script.Mean = true, doc.Latency.Mean*1000
+	for name, ms := range doc.Latency.Percentiles {
+		script.Percentiles[name] = ms * 1000
+	}
+	return script
+}
+
+// The earlier run's results for the script, TotalScriptName being the workload as a whole
+func (b *Baseline) script(name string) (BaselineScript, bool) {
+	if name == TotalScriptName {
+		return b.Total, true
+	}
+	script, found := b.Scripts[name]
+	return script, found
+}
+
+// Latency at percentile q in the earlier run, false if it wasn't reported
+func (s BaselineScript) percentile(q float64) (float64, bool) {
+	micros, found := s.Percentiles[percentileColumnName(q)]
+	return micros, found && s.Latencies
+}
+
+// How much value changed from base, as a percentage of base; zero if base is
+func percentChange(value, base float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return (value - base) / base * 100
+}
+
+// Positive numbers get a plus sign too, so the direction of a change is clear at a glance
+func formatSigned(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return s
+	}
+	return "+" + s
+}
+
+// Changes in rate, mean and the given percentiles of the workload as a whole, eg. "Compared to baseline:
+// +12.0% tps (+50.000), P99 -8.0% (-1.200ms)"; improvements are green and regressions red. Percentiles the baseline
+// didn't report are left out
+func writeBaselineComparison(result Result, baseline *Baseline, percentiles []float64, unit LatencyUnit, places int,
+	s *strings.Builder, color bool) {
+	if baseline == nil {
+		return
+	}
+	total := result.Total()
+	base := baseline.Total
+	change := func(label string, value, base float64, higherIsBetter bool, format func(float64) string) string {
+		text := fmt.Sprintf("%s %s (%s)", label, formatSigned(fmt.Sprintf("%.1f%%", percentChange(value, base))), formatSigned(format(value-base)))
+		if value == base {
+			return text
+		}
+		if (value > base) == higherIsBetter {
+			return colorize(color, ansiGreen, text)
+		}
+		return colorize(color, ansiRed, text)
+	}
+	latency := func(micros float64) string { return unit.format(micros, places) }
+	parts := []string{change("tps", result.TotalRate(), base.Rate, true, func(v float64) string { return formatRate(v, places) })}
+	if total.Latencies.TotalCount() > 0 && base.Latencies {
+		parts = append(parts, change("mean", total.Latencies.Mean(), base.Mean, false, latency))
+		for _, q := range percentiles {
+			if micros, found := base.percentile(q); found {
+				parts = append(parts, change("P"+strconv.FormatFloat(q, 'f', -1, 64),
+					float64(valueAtPercentile(total.Latencies, q)), micros, false, latency))
+			}
+		}
+	}
+	s.WriteString(fmt.Sprintf("Compared to baseline: %s\n", strings.Join(parts, ", ")))
+}
+
+// Difference of a metric from the baseline, absolute with the given decimal places and as a percentage of the
+// baseline, as name_delta and name_delta_percent; empty if the baseline doesn't have the script or the metric.
+// Latencies are scaled by latency units
+func csvDeltaColumns(name string, baseline *Baseline, places int, scale float64,
+	metric func(s *ScriptResult, base BaselineScript) (value, baseValue float64, ok bool)) []csvColumn {
+	delta := func(s *ScriptResult) (value, baseValue float64, ok bool) {
+		base, found := baseline.script(s.ScriptName)
+		if !found {
+			return 0, 0, false
+		}
+		return metric(s, base)
+	}
+	return []csvColumn{
+		csvNumber(name+"_delta", func(r Result, s *ScriptResult) string {
+			value, baseValue, ok := delta(s)
+			if !ok {
+				return ""
+			}
+			return formatDecimal((value-baseValue)/scale, places)
+		}),
+		csvNumber(name+"_delta_percent", func(r Result, s *ScriptResult) string {
+			value, baseValue, ok := delta(s)
+			if !ok || baseValue == 0 {
+				return ""
+			}
+			return fmtFloat(percentChange(value, baseValue))
+		}),
+	}
+}
@@ -0,0 +1,80 @@
+package neobench
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Counts transactions in flight across all workers, for the concurrency a run actually had. Each worker runs
+// one transaction at a time, but with a target rate they sit idle between transactions, so how many are in
+// flight depends on how fast the database answers. Shared by the workers' ResultRecorders, like a TraceWriter.
+type ConcurrencyTracker struct {
+	mut      sync.Mutex
+	inFlight int
+	max      int
+	// Time spent in transactions, summed over workers
+	busy time.Duration
+}
+
+func NewConcurrencyTracker() *ConcurrencyTracker {
+	return &ConcurrencyTracker{}
+}
+
+func (c *ConcurrencyTracker) begin() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.inFlight++
+	if c.inFlight > c.max {
+		c.max = c.inFlight
+	}
+}
+
+func (c *ConcurrencyTracker) end(took time.Duration) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.inFlight--
+	c.busy += took
+}
+
+// Concurrency over a run that took duration; nil if the duration isn't known
+func (c *ConcurrencyTracker) Summary(duration time.Duration) *Concurrency {
+	if duration <= 0 {
+		return nil
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return &Concurrency{Mean: c.busy.Seconds() / duration.Seconds(), Max: c.max}
+}
+
+// Concurrency so far, like Summary, and starts counting over, eg. once the warmup of a run is over; transactions
+// still in flight are counted in the next summary
+func (c *ConcurrencyTracker) Restart(duration time.Duration) *Concurrency {
+	summary := c.Summary(duration)
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.busy, c.max = 0, c.inFlight
+	return summary
+}
+
+// Transactions in flight at once, see ConcurrencyTracker
+type Concurrency struct {
+	// Time spent in transactions over the duration of the run, so the average number in flight
+	Mean float64
+	// Most in flight at the same time
+	Max int
+}
+
+// Left out if the run didn't track concurrency
+func writeConcurrency(result Result, s *strings.Builder) {
+	c := result.Concurrency
+	if c == nil {
+		return
+	}
+	line := fmt.Sprintf("Concurrency: %.3f transactions in flight on average, %d at most", c.Mean, c.Max)
+	if result.Config.Clients > 0 {
+		line += fmt.Sprintf(", of %d clients", result.Config.Clients)
+	}
+	s.WriteString(line + "\n")
+}
@@ -0,0 +1,149 @@
+package neobench
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Share of the CPUs available to neobench it can use on average before the client, rather than the server,
+// is what limits the rate; see CheckClientCpu
+const ClientCpuSaturation = 0.9
+
+// Samples the CPU time of the neobench process over a run, to tell whether neobench itself limited the rate it
+// measured. CPU time is read from the operating system every interval, so short bursts of saturation show up
+// in the peak even when the mean is well below it.
+type CpuTracker struct {
+	mut sync.Mutex
+	// CPUs Go runs goroutines on, the most neobench can use at once
+	cpus    int
+	now     func() time.Time
+	cpuTime func() (time.Duration, error)
+	// False once CPU time couldn't be read, the tracker has nothing to report then
+	ok       bool
+	start    time.Time
+	startCpu time.Duration
+	last     time.Time
+	lastCpu  time.Duration
+	peak     float64
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// Starts sampling every interval, until Stop; returns a tracker that reports nothing if the process CPU time
+// can't be read on this system
+func StartCpuTracker(interval time.Duration) *CpuTracker {
+	c := newCpuTracker(runtime.GOMAXPROCS(0), time.Now, processCpuTime)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stopCh:
+				return
+			case <-ticker.C:
+				c.sample()
+			}
+		}
+	}()
+	return c
+}
+
+func newCpuTracker(cpus int, now func() time.Time, cpuTime func() (time.Duration, error)) *CpuTracker {
+	c := &CpuTracker{cpus: cpus, now: now, cpuTime: cpuTime, stopCh: make(chan struct{})}
+	c.start = now()
+	cpu, err := cpuTime()
+	c.ok = err == nil
+	c.startCpu, c.last, c.lastCpu = cpu, c.start, cpu
+	return c
+}
+
+// Peak is of the time since the last sample
+func (c *CpuTracker) sample() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if !c.ok {
+		return
+	}
+	now := c.now()
+	cpu, err := c.cpuTime()
+	if err != nil {
+		c.ok = false
+		return
+	}
+	if utilization := c.utilization(cpu-c.lastCpu, now.Sub(c.last)); utilization > c.peak {
+		c.peak = utilization
+	}
+	c.last, c.lastCpu = now, cpu
+}
+
+func (c *CpuTracker) utilization(cpu, wall time.Duration) float64 {
+	if wall <= 0 || c.cpus <= 0 {
+		return 0
+	}
+	return cpu.Seconds() / (wall.Seconds() * float64(c.cpus))
+}
+
+// CPU use so far; nil if CPU time couldn't be read
+func (c *CpuTracker) Summary() *ClientCpu {
+	c.sample()
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if !c.ok {
+		return nil
+	}
+	used := c.lastCpu - c.startCpu
+	mean := c.utilization(used, c.last.Sub(c.start))
+	peak := c.peak
+	if mean > peak {
+		peak = mean
+	}
+	return &ClientCpu{Cpus: c.cpus, Time: used, Mean: mean, Peak: peak}
+}
+
+// CPU use so far, like Summary, and starts counting over, eg. once the warmup of a run is over
+func (c *CpuTracker) Restart() *ClientCpu {
+	summary := c.Summary()
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.start, c.startCpu, c.peak = c.last, c.lastCpu, 0
+	return summary
+}
+
+// Stops sampling; safe to call more than once
+func (c *CpuTracker) Stop() {
+	c.stopOnce.Do(func() { close(c.stopCh) })
+}
+
+// CPU the neobench process used over a run; a client that used all of its CPUs measured its own limit rather
+// than the server's
+type ClientCpu struct {
+	// CPUs neobench could use at once, see runtime.GOMAXPROCS
+	Cpus int
+	// CPU time used, user and system
+	Time time.Duration
+	// Share of the CPUs used, on average over the run and in the busiest sample interval; 1 is all of them
+	Mean float64
+	Peak float64
+}
+
+// Warns if the client was busy enough that it, rather than the server, may have set the rate
+func CheckClientCpu(out Output, result Result) {
+	c := result.ClientCpu
+	if c != nil && c.Mean >= ClientCpuSaturation {
+		out.Warnf("neobench used %.1f%% of its %d CPUs on average; the client was saturated, so the rate may be "+
+			"its own limit rather than the server's, consider fewer clients per neobench process or a bigger client machine", c.Mean*100, c.Cpus)
+	}
+}
+
+// Left out if CPU use wasn't tracked
+func writeClientCpu(result Result, s *strings.Builder) {
+	c := result.ClientCpu
+	if c == nil {
+		return
+	}
+	s.WriteString(fmt.Sprintf("Client CPU: %.1f%% of %d CPUs on average, %.1f%% at most, %s of CPU time\n",
+		c.Mean*100, c.Cpus, c.Peak*100, c.Time.Round(time.Millisecond)))
+}
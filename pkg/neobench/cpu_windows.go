@@ -0,0 +1,17 @@
+package neobench
+
+import (
+	"syscall"
+	"time"
+)
+
+// User and kernel CPU time the process has used since it started
+func processCpuTime() (time.Duration, error) {
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(syscall.Handle(^uintptr(0)), &creation, &exit, &kernel, &user); err != nil {
+		return 0, err
+	}
+	// Durations come in 100ns ticks
+	ticks := func(t syscall.Filetime) int64 { return int64(t.HighDateTime)<<32 | int64(t.LowDateTime) }
+	return time.Duration((ticks(kernel) + ticks(user)) * 100), nil
+}
@@ -16,7 +16,8 @@ const (
 	EncryptionOn   EncryptionMode = 2
 )
 
-func NewDriver(urlStr, user, password string, encryptionMode EncryptionMode) (neo4j.Driver, error) {
+// poolSize is the most connections the driver keeps per server, see ConnectionPool
+func NewDriver(urlStr, user, password string, encryptionMode EncryptionMode, poolSize int) (neo4j.Driver, error) {
 	var encrypted bool
 	switch encryptionMode {
 	case EncryptionOff:
@@ -31,7 +32,10 @@ func NewDriver(urlStr, user, password string, encryptionMode EncryptionMode) (ne
 		encrypted = enabled
 	}
 
-	config := func(conf *neo4j.Config) { conf.Encrypted = encrypted }
+	config := func(conf *neo4j.Config) {
+		conf.Encrypted = encrypted
+		conf.MaxConnectionPoolSize = poolSize
+	}
 	return neo4j.NewDriver(urlStr, neo4j.BasicAuth(user, password, ""), config)
 }
 
@@ -57,3 +61,33 @@ func isTlsEnabled(urlStr string) (bool, error) {
 	socket.Close()
 	return true, nil
 }
+
+// Version of the Neo4j server the driver is connected to, according to dbms.components()
+func ServerVersion(driver neo4j.Driver, dbName string) (string, error) {
+	session, err := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeRead,
+		DatabaseName: dbName,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	version, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run("CALL dbms.components() YIELD name, versions WHERE name = 'Neo4j Kernel' RETURN versions[0]", nil)
+		if err != nil {
+			return nil, err
+		}
+		if !res.Next() {
+			if res.Err() != nil {
+				return nil, res.Err()
+			}
+			return nil, fmt.Errorf("dbms.components() did not list the Neo4j Kernel")
+		}
+		return res.Record().GetByIndex(0), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v", version), nil
+}
@@ -0,0 +1,80 @@
+package neobench
+
+import (
+	"github.com/neo4j/neo4j-go-driver/neo4j"
+	"github.com/pkg/errors"
+	"io"
+	"net"
+	"strings"
+)
+
+// What kind of failure an error is, to tell eg. a connection timeout from a constraint violation from a
+// deadlock when looking at what a stress run produced; see ClassifyError
+type ErrorCategory int
+
+const (
+	// Anything not recognized as one of the others
+	ErrorOther ErrorCategory = iota
+	// Couldn't reach the database or lost the connection to it
+	ErrorConnection
+	// Failures the database says may succeed if retried, eg. deadlocks and lock timeouts
+	ErrorTransient
+	// The transaction would have broken a schema constraint
+	ErrorConstraint
+	// The query wasn't valid Cypher
+	ErrorSyntax
+)
+
+// In the order breakdowns list them
+var ErrorCategories = []ErrorCategory{ErrorConnection, ErrorTransient, ErrorConstraint, ErrorSyntax, ErrorOther}
+
+func (c ErrorCategory) String() string {
+	switch c {
+	case ErrorConnection:
+		return "connection"
+	case ErrorTransient:
+		return "transient"
+	case ErrorConstraint:
+		return "constraint"
+	case ErrorSyntax:
+		return "syntax"
+	}
+	return "other"
+}
+
+// Picks the category of err by the Neo4j status code of server errors, eg. Neo.TransientError.Transaction.DeadlockDetected,
+// and by the type of driver and network errors
+func ClassifyError(err error) ErrorCategory {
+	cause := errors.Cause(err)
+	if _, ok := cause.(net.Error); ok || neo4j.IsServiceUnavailable(cause) || cause == io.EOF {
+		return ErrorConnection
+	}
+	return classifyStatus(groupError(err))
+}
+
+// Category of a Neo4j status code, as errors are grouped by in Result.FailedByErrorGroup
+func classifyStatus(code string) ErrorCategory {
+	switch {
+	case strings.HasPrefix(code, "Neo.TransientError."):
+		return ErrorTransient
+	case strings.HasPrefix(code, "Neo.ClientError.Schema.") && strings.Contains(code, "Constraint"):
+		return ErrorConstraint
+	case code == "Neo.ClientError.Statement.SyntaxError":
+		return ErrorSyntax
+	}
+	return ErrorOther
+}
+
+// Failed transactions by category, from the status codes they are grouped by; errors without one are classified
+// by the first of the group, which the others are assumed to be like
+func (r *Result) FailedByCategory() map[ErrorCategory]int64 {
+	counts := make(map[ErrorCategory]int64)
+	for code, group := range r.FailedByErrorGroup {
+		category := classifyStatus(code)
+		if category == ErrorOther && group.FirstFailure != nil {
+			category = ClassifyError(group.FirstFailure)
+		}
+		counts[category] += group.Count
+	}
+	return counts
+}
@@ -0,0 +1,44 @@
+package neobench
+
+import (
+	"compress/gzip"
+	"os"
+	"strings"
+)
+
+// Whether path names a gzip file, which results and traces are compressed into rather than written as they are
+func IsGzipPath(path string) bool {
+	return strings.HasSuffix(path, ".gz")
+}
+
+// Compresses everything written to it into a file, for traces and results that would be huge otherwise. Flush
+// writes out what's been compressed so far, so a run that's killed leaves a file that's readable up to there;
+// Close ends the gzip stream, which is what makes the file whole, and closes the file.
+type GzipFile struct {
+	gz   *gzip.Writer
+	file *os.File
+}
+
+func CreateGzipFile(path string) (*GzipFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GzipFile{gz: gzip.NewWriter(f), file: f}, nil
+}
+
+func (f *GzipFile) Write(p []byte) (int, error) {
+	return f.gz.Write(p)
+}
+
+func (f *GzipFile) Flush() error {
+	return f.gz.Flush()
+}
+
+func (f *GzipFile) Close() error {
+	err := f.gz.Close()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -3,9 +3,15 @@ package neobench
 import (
 	"fmt"
 	"github.com/codahale/hdrhistogram"
+	"github.com/pkg/errors"
 	"io"
+	"math"
+	"net"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -13,12 +19,49 @@ type ProgressReport struct {
 	Section      string
 	Step         string
 	Completeness float64
+	// Set when several workers share a step, each reporting progress on its part of it, see FanInProgressOutput
+	Worker string
+	// Fraction of the step's work the worker has; zero means the workers share it equally
+	Share float64
+	// Latest report of each worker, ordered by worker, on reports combined from theirs
+	Workers []ProgressReport
 }
 
 type Result struct {
 	// Targeted database
 	DatabaseName string
 	Scenario     string
+	// How the benchmark was configured; zero-valued if not known
+	Config RunConfig
+	// Wall-clock time the workload ran for, zero if not known
+	Duration time.Duration
+	// When measurement started and ended, eg. for lining results up with server logs; zero if not known
+	StartTime time.Time
+	EndTime   time.Time
+	// What produced the results, see Version; empty if not known
+	NeobenchVersion string
+	Neo4jVersion    string
+	// Total transactions per second in each sample interval, in order; empty unless the run was sampled
+	SampleRates []float64
+	// Memory and GC of the neobench process over the run, nil unless asked for with --diagnostics
+	Process *ProcessStats
+	// Transactions in flight at once over the run, nil if not tracked
+	Concurrency *Concurrency
+	// Time transactions took to get a connection from the driver's pool, nil if not tracked
+	PoolWait *PoolWait
+	// CPU the neobench process used over the run, nil if it couldn't be read
+	ClientCpu *ClientCpu
+	// The slowest transactions of the run, slowest first; empty unless kept, see ResultRecorder.TopSlow
+	Slowest []SlowTransaction
+	// Identifies the run its results came from, so storage can dedupe and group them; see TaggingOutput
+	RunId string
+	// Free-form labels of the run, eg. the parameter a sweep varies; see TaggingOutput
+	Tags map[string]string
+	// Whether these are of the warmup before the measured run, rather than of the run itself
+	Warmup bool
+	// Whether the run was stopped before its configured duration was up, eg. with Ctrl-C or by a worker
+	// crashing, so these are the results of however much of it ran
+	Interrupted bool
 
 	FailedByErrorGroup map[string]FailureGroup
 
@@ -26,6 +69,54 @@ type Result struct {
 	Scripts map[string]*ScriptResult
 }
 
+// Results for one sampling interval of a run, see Output.ReportInterval; Duration is the length of the interval
+type IntervalResult struct {
+	Result
+	Start time.Time
+	End   time.Time
+}
+
+// How a benchmark was run, carried along with its results so they are self-describing
+type RunConfig struct {
+	Clients int
+	// Total transactions per second the workload is paced at, zero when unbounded as in throughput mode
+	TargetRate float64
+	// Configured duration, the actual time the run took is in Result.Duration
+	Duration time.Duration
+	// Transactions to run in total for runs that go by count rather than by Duration, zero otherwise
+	Transactions uint64
+}
+
+// What a run would do, reported instead of running it with --dry-run, see Output.ReportPlan
+type Plan struct {
+	DatabaseName string
+	Scenario     string
+	// Target rate is zero in throughput mode, where transactions run as fast as the clients manage, and
+	// duration is zero for runs that go by Transactions
+	Config RunConfig
+	// Whether the built-in datasets are generated before the run, see --init
+	Init    bool
+	Scripts []PlannedScript
+}
+
+type PlannedScript struct {
+	Name     string
+	Weight   float64
+	Readonly bool
+}
+
+// Fraction of transactions that run the script, going by its weight
+func (p Plan) share(script PlannedScript) float64 {
+	var total float64
+	for _, s := range p.Scripts {
+		total += s.Weight
+	}
+	if total <= 0 {
+		return 0
+	}
+	return script.Weight / total
+}
+
 func NewResult(databaseName, scenario string) Result {
 	return Result{
 		DatabaseName:       databaseName,
@@ -49,6 +140,194 @@ func (r *Result) TotalFailed() (n int64) {
 	return
 }
 
+// Percentage of attempted transactions that failed, across all scripts
+func (r *Result) ErrorRate() float64 {
+	return errorRate(r.TotalSucceeded(), r.TotalFailed())
+}
+
+func (r *Result) TotalQueryRate() (n float64) {
+	for _, s := range r.Scripts {
+		n += s.QueryRate
+	}
+	return
+}
+
+func (r *Result) TotalRecordRate() (n float64) {
+	for _, s := range r.Scripts {
+		n += s.RecordRate
+	}
+	return
+}
+
+func (r *Result) TotalByteRate() (n float64) {
+	for _, s := range r.Scripts {
+		n += s.ByteRate
+	}
+	return
+}
+
+// Attempts retried across all scripts, see ScriptResult.Retries
+func (r *Result) TotalRetries() (n int64) {
+	for _, s := range r.Scripts {
+		n += s.Retries
+	}
+	return
+}
+
+func (r *Result) TotalOutOfRange() (n int64) {
+	for _, s := range r.Scripts {
+		n += s.OutOfRange
+	}
+	return
+}
+
+// Reports a throughput mode run's results, as rates only unless reportLatencies is set, see --report-latencies
+func ReportThroughputResult(out Output, result Result, reportLatencies bool) error {
+	if reportLatencies {
+		return out.ReportLatency(result)
+	}
+	return out.ReportThroughput(result)
+}
+
+// Warns if any latencies were too long to record, since the tail of the distribution is wrong if so
+func CheckLatencyRange(out Output, result Result) {
+	if n := result.TotalOutOfRange(); n > 0 {
+		config := result.HistogramConfig()
+		if config.Min > 0 {
+			out.Warnf("%d samples fell outside the histogram's range of %s to %s; percentiles outside of it are unreliable, "+
+				"consider a wider --latency-min and --latency-max", n, config.Min, config.Max)
+			return
+		}
+		out.Warnf("%d samples exceeded the histogram's max of %s; tail percentiles are unreliable, consider a higher --latency-max",
+			n, config.Max)
+	}
+}
+
+// How far short of the target rate the achieved rate may fall before CheckRate warns, as a fraction of the target
+const RateShortfallTolerance = 0.05
+
+// Warns if a run paced at a target rate didn't keep up with it; latencies are measured from when transactions
+// were scheduled to start, so they then include time spent waiting behind earlier transactions
+func CheckRate(out Output, result Result) {
+	target := result.Config.TargetRate
+	if target <= 0 {
+		return
+	}
+	achieved := result.TotalRate()
+	shortfall := (target - achieved) / target
+	if shortfall > RateShortfallTolerance {
+		out.Warnf("benchmark fell behind the target rate, %.3f of %.3f transactions per second (%.1f%% short); "+
+			"latencies include time transactions waited to start, add clients or lower the rate to measure the database alone",
+			achieved, target, shortfall*100)
+	}
+}
+
+// Fails the run if no transaction succeeded; there are no latencies then, and a run that did nothing but
+// fail shouldn't pass for one that measured something
+func CheckSucceeded(out Output, result Result) {
+	if result.TotalSucceeded() == 0 {
+		out.Errorf("no successful transactions recorded, so there are no latencies to report")
+	}
+}
+
+// How much the rate may vary between samples, as a coefficient of variation, before CheckRateStability warns
+const RateVariationTolerance = 0.2
+
+// Warns if the rate swung a lot between samples; a mean over a rate that oscillates doesn't describe any part
+// of the run, and the swings are often from GC, checkpoints or throttling rather than the workload
+func CheckRateStability(out Output, result Result) {
+	cv, ok := result.RateVariation()
+	if ok && cv > RateVariationTolerance {
+		out.Warnf("transactions per second varied by %.1f%% between samples (coefficient of variation); "+
+			"the mean rate may not be representative", cv*100)
+	}
+}
+
+// Coefficient of variation of SampleRates, the standard deviation as a fraction of the mean; false if there
+// are too few samples, or no transactions, to tell
+func (r *Result) RateVariation() (float64, bool) {
+	if len(r.SampleRates) < 2 {
+		return 0, false
+	}
+	var sum float64
+	for _, rate := range r.SampleRates {
+		sum += rate
+	}
+	mean := sum / float64(len(r.SampleRates))
+	if mean <= 0 {
+		return 0, false
+	}
+	var squares float64
+	for _, rate := range r.SampleRates {
+		squares += (rate - mean) * (rate - mean)
+	}
+	return math.Sqrt(squares/float64(len(r.SampleRates))) / mean, true
+}
+
+// Lowest and highest rate of any sample interval, see CheckRateStability; false unless the run was sampled.
+// Samples are only taken within the measurement window, see Result.StartTime
+func (r *Result) SampleRateRange() (min, max float64, ok bool) {
+	if len(r.SampleRates) == 0 {
+		return 0, 0, false
+	}
+	min, max = r.SampleRates[0], r.SampleRates[0]
+	for _, rate := range r.SampleRates[1:] {
+		min, max = math.Min(min, rate), math.Max(max, rate)
+	}
+	return min, max, true
+}
+
+// Mean of SampleRates without the highest and lowest percent of them, see CheckRateStability; with percent
+// zero it's the plain mean of the samples. False unless the run was
+// sampled; at least one sample is always kept
+func (r *Result) TrimmedSampleRate(percent float64) (float64, bool) {
+	if len(r.SampleRates) == 0 {
+		return 0, false
+	}
+	rates := append([]float64{}, r.SampleRates...)
+	sort.Float64s(rates)
+	trim := int(float64(len(rates)) * percent / 100)
+	if 2*trim >= len(rates) {
+		trim = (len(rates) - 1) / 2
+	}
+	rates = rates[trim : len(rates)-trim]
+	var sum float64
+	for _, rate := range rates {
+		sum += rate
+	}
+	return sum / float64(len(rates)), true
+}
+
+// Percentiles the throughput distribution is reported at; the low end is where throughput problems show up
+var DefaultRatePercentiles = []float64{1, 10, 25, 50, 75, 90, 99}
+
+// Rate that q percent of SampleRates were at or below, eg. the P10 of a run that did under 900 transactions
+// per second in 10% of its samples is 900; false unless there are at least two samples to make a distribution
+// of. Runs have few samples compared to transactions, so this ranks them exactly rather than bucketing them
+// in a histogram
+func (r *Result) SampleRatePercentile(q float64) (float64, bool) {
+	if len(r.SampleRates) < 2 {
+		return 0, false
+	}
+	rates := append([]float64{}, r.SampleRates...)
+	sort.Float64s(rates)
+	rank := int(math.Ceil(q/100*float64(len(rates)))) - 1
+	if rank < 0 {
+		rank = 0
+	} else if rank >= len(rates) {
+		rank = len(rates) - 1
+	}
+	return rates[rank], true
+}
+
+// Fails unless percent is something TrimmedSampleRate can trim from each end of the samples
+func ValidateTrimRates(percent float64) error {
+	if percent < 0 || percent >= 50 {
+		return fmt.Errorf("invalid rate trim: %v, it must be at least 0 and less than 50 percent", percent)
+	}
+	return nil
+}
+
 func (r *Result) TotalRate() (n float64) {
 	for _, s := range r.Scripts {
 		n += s.Rate
@@ -56,23 +335,135 @@ func (r *Result) TotalRate() (n float64) {
 	return
 }
 
+// Successful transactions per second over the whole run, as opposed to TotalRate which sums what each worker
+// measured; zero if the Duration is not known
+func (r *Result) MeasuredRate() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	var n int64
+	for _, s := range r.Scripts {
+		n += s.Latencies.TotalCount()
+	}
+	return float64(n) / r.Duration.Seconds()
+}
+
+// Scenario as something safe to use in a filename, see ScenarioSlug
+func (r *Result) ScenarioSlug() string {
+	return ScenarioSlug(r.Scenario)
+}
+
+// Lowercase scenario with runs of whitespace replaced by an underscore and anything but letters, digits,
+// '.', '-' and '_' left out, so path separators and characters some filesystems reject go away; eg.
+// "-c 4 -w builtin:tpcb-like" becomes "-c_4_-w_builtintpcb-like"
+func ScenarioSlug(scenario string) string {
+	s := strings.Builder{}
+	for _, word := range strings.Fields(strings.ToLower(scenario)) {
+		if s.Len() > 0 {
+			s.WriteRune('_')
+		}
+		for _, c := range word {
+			if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '.' || c == '-' || c == '_' {
+				s.WriteRune(c)
+			}
+		}
+	}
+	return s.String()
+}
+
+// What the latencies were recorded with, going by the first script's; DefaultHistogramConfig if there are none.
+// Every script's is the same, unless the result was put together by hand; see MergeResults
+func (r *Result) HistogramConfig() HistogramConfig {
+	var first *ScriptResult
+	for _, s := range r.Scripts {
+		if first == nil || s.ScriptName < first.ScriptName {
+			first = s
+		}
+	}
+	if first == nil {
+		return DefaultHistogramConfig
+	}
+	return histogramConfigOf(first.Latencies)
+}
+
+// Name of the ScriptResult returned by Result.Total()
+const TotalScriptName = "<total>"
+
+// Combines the results of all scripts into one, used to report on the workload as a whole
+func (r *Result) Total() *ScriptResult {
+	total := &ScriptResult{
+		ScriptName: TotalScriptName,
+		Latencies:  r.HistogramConfig().newHistogram(),
+	}
+	for _, s := range r.Scripts {
+		total.Rate += s.Rate
+		total.Succeeded += s.Succeeded
+		total.Failed += s.Failed
+		total.OutOfRange += s.OutOfRange
+		total.Queries += s.Queries
+		total.QueryRate += s.QueryRate
+		total.Records += s.Records
+		total.RecordRate += s.RecordRate
+		total.Bytes += s.Bytes
+		total.ByteRate += s.ByteRate
+		total.Retries += s.Retries
+		total.Latencies.Merge(s.Latencies)
+		total.mergePhases(s.Phases)
+		total.mergeAccessModes(s.AccessModes)
+		total.mergeWithoutRetries(s.WithoutRetries)
+	}
+	return total
+}
+
 func (r *Result) Add(res WorkerResult) {
 	for _, workerScriptResult := range res.Scripts {
 		combinedScriptResult := r.Scripts[workerScriptResult.ScriptName]
 		if combinedScriptResult == nil {
-			r.Scripts[workerScriptResult.ScriptName] = &ScriptResult{
+			combinedScriptResult = &ScriptResult{
 				ScriptName: workerScriptResult.ScriptName,
 				Latencies:  hdrhistogram.Import(workerScriptResult.Latencies.Export()),
 				Rate:       workerScriptResult.Rate,
 				Succeeded:  workerScriptResult.Succeeded,
 				Failed:     workerScriptResult.Failed,
+				OutOfRange: workerScriptResult.OutOfRange,
+				Queries:    workerScriptResult.Queries,
+				QueryRate:  workerScriptResult.QueryRate,
+				Records:    workerScriptResult.Records,
+				RecordRate: workerScriptResult.RecordRate,
+				Bytes:      workerScriptResult.Bytes,
+				ByteRate:   workerScriptResult.ByteRate,
+				Retries:    workerScriptResult.Retries,
 			}
+			combinedScriptResult.mergePhases(workerScriptResult.Phases)
+			combinedScriptResult.mergeAccessModes(workerScriptResult.AccessModes)
+			combinedScriptResult.mergeWithoutRetries(workerScriptResult.WithoutRetries)
+			r.Scripts[workerScriptResult.ScriptName] = combinedScriptResult
 		} else {
 			combinedScriptResult.Rate += workerScriptResult.Rate
 			combinedScriptResult.Succeeded += workerScriptResult.Succeeded
 			combinedScriptResult.Failed += workerScriptResult.Failed
+			combinedScriptResult.OutOfRange += workerScriptResult.OutOfRange
+			combinedScriptResult.Queries += workerScriptResult.Queries
+			combinedScriptResult.QueryRate += workerScriptResult.QueryRate
+			combinedScriptResult.Records += workerScriptResult.Records
+			combinedScriptResult.RecordRate += workerScriptResult.RecordRate
+			combinedScriptResult.Bytes += workerScriptResult.Bytes
+			combinedScriptResult.ByteRate += workerScriptResult.ByteRate
+			combinedScriptResult.Retries += workerScriptResult.Retries
 			combinedScriptResult.Latencies.Merge(workerScriptResult.Latencies)
+			combinedScriptResult.mergePhases(workerScriptResult.Phases)
+			combinedScriptResult.mergeAccessModes(workerScriptResult.AccessModes)
+			combinedScriptResult.mergeWithoutRetries(workerScriptResult.WithoutRetries)
+		}
+	}
+	// Each worker kept as many as it was asked to, unless it ran fewer transactions than that, so the longest
+	// list has as many as the run is meant to keep
+	if len(res.Slowest) > 0 {
+		n := len(r.Slowest)
+		if len(res.Slowest) > n {
+			n = len(res.Slowest)
 		}
+		r.Slowest = keepSlowest(append(append([]SlowTransaction{}, r.Slowest...), res.Slowest...), n)
 	}
 	for name, group := range res.FailedByErrorGroup {
 		existing, found := r.FailedByErrorGroup[name]
@@ -87,6 +478,121 @@ func (r *Result) Add(res WorkerResult) {
 	}
 }
 
+// Combines results, eg. from separate runs against the same database, into one that can be reported on as a
+// whole. Scripts with the same name are merged, scenarios are joined and the config and database name are kept
+// only if all results agree on them; sample rates are left out, since samples of separate runs don't line up.
+// The runs are taken to be one after another, so durations add up and each rate is of the transactions of all
+// of them over their combined duration, rather than the sum of their rates.
+// Fails if any two latency histograms for the same script or phase were created with different bounds, since
+// merging those would silently lose or misplace values.
+func MergeResults(results []Result) (Result, error) {
+	if len(results) == 0 {
+		return Result{}, fmt.Errorf("no results to merge")
+	}
+	merged := NewResult(results[0].DatabaseName, "")
+	merged.Config = results[0].Config
+	scenarios := make([]string, 0, len(results))
+	// How long each script ran for, over all the runs it was in
+	seconds := make(map[string]float64)
+	for _, result := range results {
+		if result.DatabaseName != merged.DatabaseName {
+			merged.DatabaseName = ""
+		}
+		if result.Config != merged.Config {
+			merged.Config = RunConfig{}
+		}
+		merged.Duration += result.Duration
+		if !result.StartTime.IsZero() && (merged.StartTime.IsZero() || result.StartTime.Before(merged.StartTime)) {
+			merged.StartTime = result.StartTime
+		}
+		if result.EndTime.After(merged.EndTime) {
+			merged.EndTime = result.EndTime
+		}
+		if result.Scenario != "" && !containsString(scenarios, result.Scenario) {
+			scenarios = append(scenarios, result.Scenario)
+		}
+		for _, script := range result.Scripts {
+			if err := merged.mergeScript(script); err != nil {
+				return Result{}, err
+			}
+			seconds[script.ScriptName] += scriptSeconds(result, script)
+		}
+		merged.Add(WorkerResult{FailedByErrorGroup: result.FailedByErrorGroup, Slowest: result.Slowest})
+	}
+	for name, script := range merged.Scripts {
+		if s := seconds[name]; s > 0 {
+			script.Rate = float64(script.Succeeded+script.Failed) / s
+			script.QueryRate = float64(script.Queries) / s
+			script.RecordRate = float64(script.Records) / s
+			script.ByteRate = float64(script.Bytes) / s
+		}
+	}
+	merged.Scenario = strings.Join(scenarios, "; ")
+	return merged, nil
+}
+
+// The result's duration, or if it doesn't know it, however long the script's rate says its transactions took
+func scriptSeconds(result Result, script *ScriptResult) float64 {
+	if result.Duration > 0 {
+		return result.Duration.Seconds()
+	}
+	if script.Rate > 0 {
+		return float64(script.Succeeded+script.Failed) / script.Rate
+	}
+	return 0
+}
+
+func (r *Result) mergeScript(script *ScriptResult) error {
+	existing, found := r.Scripts[script.ScriptName]
+	if !found {
+		r.Add(WorkerResult{Scripts: map[string]*ScriptResult{script.ScriptName: script}})
+		return nil
+	}
+	if err := checkHistogramsCompatible(existing.Latencies, script.Latencies); err != nil {
+		return errors.Wrapf(err, "cannot merge latencies of %s", script.ScriptName)
+	}
+	for phase, histo := range script.Phases {
+		if existingHisto, found := existing.Phases[phase]; found {
+			if err := checkHistogramsCompatible(existingHisto, histo); err != nil {
+				return errors.Wrapf(err, "cannot merge %s latencies of %s", phase, script.ScriptName)
+			}
+		}
+	}
+	for mode, histo := range script.AccessModes {
+		if existingHisto, found := existing.AccessModes[mode]; found {
+			if err := checkHistogramsCompatible(existingHisto, histo); err != nil {
+				return errors.Wrapf(err, "cannot merge %s latencies of %s", mode, script.ScriptName)
+			}
+		}
+	}
+	if existing.WithoutRetries != nil && script.WithoutRetries != nil {
+		if err := checkHistogramsCompatible(existing.WithoutRetries, script.WithoutRetries); err != nil {
+			return errors.Wrapf(err, "cannot merge latencies without retries of %s", script.ScriptName)
+		}
+	}
+	r.Add(WorkerResult{Scripts: map[string]*ScriptResult{script.ScriptName: script}})
+	return nil
+}
+
+func checkHistogramsCompatible(a, b *hdrhistogram.Histogram) error {
+	if a.LowestTrackableValue() != b.LowestTrackableValue() || a.HighestTrackableValue() != b.HighestTrackableValue() ||
+		a.SignificantFigures() != b.SignificantFigures() {
+		return fmt.Errorf("histograms track different ranges, [%d, %d] with %d significant figures vs [%d, %d] with %d",
+			a.LowestTrackableValue(), a.HighestTrackableValue(), a.SignificantFigures(),
+			b.LowestTrackableValue(), b.HighestTrackableValue(), b.SignificantFigures())
+	}
+	return nil
+}
+
+func containsString(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
 // Result for one script; normally a workload is just one script, but we allow workloads to be made up of
 // lots of scripts as well, with a weighted random mix of them. We report results per-script, since latencies
 // between different scripts will mean totally different things.
@@ -98,292 +604,1948 @@ type ScriptResult struct {
 	Failed    int64
 	Succeeded int64
 	Latencies *hdrhistogram.Histogram
+	// Latencies of each phase of successful transactions, by phase name, see Phases; nil if not measured.
+	// Unlike Latencies these are actual durations, they aren't corrected for coordinated omission
+	Phases map[string]*hdrhistogram.Histogram
+	// Latencies of successful transactions by the access mode they ran in, see AccessModes; read and write
+	// transactions are routed to different cluster members, so they're often worlds apart. Corrected for
+	// coordinated omission like Latencies, and nil for results that weren't recorded transaction by transaction
+	AccessModes map[string]*hdrhistogram.Histogram
+	// Latencies too long for Latencies to track, they're recorded as the longest it can track instead
+	OutOfRange int64
+	// Statements run by successful transactions, and how many per second; a transaction can run several,
+	// so this is closer to the load on the server than Rate is
+	Queries   int64
+	QueryRate float64
+	// Records returned to successful transactions, and an estimate of their size in bytes from the values they
+	// hold, with how many of each per second; a rough measure of network load, see approxValueSize. Zero unless
+	// counted, see ResultRecorder.CountRecords
+	Records    int64
+	RecordRate float64
+	Bytes      int64
+	ByteRate   float64
+	// Attempts the driver retried after transient failures, of successful and failed transactions alike. Latencies
+	// include the time retries took, WithoutRetries doesn't
+	Retries int64
+	// Latencies of successful transactions with the attempts the driver retried, and its waits between them, taken
+	// out: what latency would have been had the last attempt been the first. Corrected for coordinated omission
+	// like Latencies; nil if phases weren't measured
+	WithoutRetries *hdrhistogram.Histogram
 }
 
-type Output interface {
-	// scenario is a string describing the flags you'd need to pass to neobench to run an equivalent load
-	BenchmarkStart(databaseName, url, scenario string)
-	ReportProgress(report ProgressReport)
-	ReportWorkloadProgress(completeness float64, checkpoint Result)
-	ReportThroughput(result Result)
-	ReportLatency(result Result)
-	Errorf(format string, a ...interface{})
+// Percentage of attempted transactions that failed
+func (s *ScriptResult) ErrorRate() float64 {
+	return errorRate(s.Succeeded, s.Failed)
 }
 
-func NewOutput(name string) (Output, error) {
-	if name == "auto" {
-		fi, _ := os.Stdout.Stat()
-		if fi.Mode()&os.ModeCharDevice == 0 {
-			return &CsvOutput{
-				ErrStream: os.Stderr,
-				OutStream: os.Stdout,
-			}, nil
-		} else {
-			return &InteractiveOutput{
-				ErrStream: os.Stderr,
-				OutStream: os.Stdout,
-			}, nil
-		}
+func errorRate(succeeded, failed int64) float64 {
+	if succeeded+failed == 0 {
+		return 0
 	}
-	if name == "interactive" {
-		return &InteractiveOutput{
-			ErrStream: os.Stderr,
-			OutStream: os.Stdout,
-		}, nil
+	return 100 * float64(failed) / float64(succeeded+failed)
+}
+
+// Phases of a transaction, timed separately to tell driver connection pool problems apart from slow queries
+const (
+	// Getting a connection from the driver and beginning a transaction on it
+	PhaseConnectionAcquire = "connection acquire"
+	// Running the statements and committing, on the attempt that succeeded; attempts retried before it are left out,
+	// see ScriptResult.WithoutRetries
+	PhaseQuery = "query"
+)
+
+// Order phases are reported in
+var Phases = []string{PhaseConnectionAcquire, PhaseQuery}
+
+func (s *ScriptResult) recordPhase(phase string, latency time.Duration) error {
+	if s.Phases == nil {
+		s.Phases = make(map[string]*hdrhistogram.Histogram)
 	}
-	if name == "csv" {
-		return &CsvOutput{
-			ErrStream: os.Stderr,
-			OutStream: os.Stdout,
-		}, nil
+	histo, found := s.Phases[phase]
+	if !found {
+		histo = histogramConfigOf(s.Latencies).newHistogram()
+		s.Phases[phase] = histo
 	}
-	return nil, fmt.Errorf("unknown output format: %s, supported formats are 'auto', 'interactive' and 'csv'", name)
+	// Phases never take longer than the whole transaction, so anything out of range is counted there
+	if _, err := recordClamped(histo, latency); err != nil {
+		return errors.Wrapf(err, "failed to record %s latency: %s", phase, latency)
+	}
+	return nil
 }
 
-type InteractiveOutput struct {
-	ErrStream io.Writer
-	OutStream io.Writer
-	// Used to rate-limit progress reporting
-	LastProgressReport ProgressReport
-	LastProgressTime   time.Time
-}
+// Access modes transactions run in, from UnitOfWork.Readonly
+const (
+	AccessModeRead  = "read"
+	AccessModeWrite = "write"
+)
 
-func (o *InteractiveOutput) BenchmarkStart(databaseName, url, scenario string) {
-	if databaseName == "" {
-		databaseName = "<default>"
+// Order access modes are reported in
+var AccessModes = []string{AccessModeRead, AccessModeWrite}
+
+// Out of range latencies are counted by the caller, in OutOfRange, as they are for Latencies
+func (s *ScriptResult) recordAccessMode(mode string, latency time.Duration) error {
+	if s.AccessModes == nil {
+		s.AccessModes = make(map[string]*hdrhistogram.Histogram)
 	}
-	_, err := fmt.Fprintf(o.ErrStream,
-		"Starting workload on database %s against %s\n"+
-			"Scenario: %s\n", databaseName, url, scenario)
-	if err != nil {
-		panic(err)
+	histo, found := s.AccessModes[mode]
+	if !found {
+		histo = histogramConfigOf(s.Latencies).newHistogram()
+		s.AccessModes[mode] = histo
+	}
+	if _, err := recordClamped(histo, latency); err != nil {
+		return errors.Wrapf(err, "failed to record %s latency: %s", mode, latency)
 	}
+	return nil
 }
 
-func (o *InteractiveOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
-	_, err := fmt.Fprintf(o.ErrStream, "[%.02f%%] %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
-	if err != nil {
-		panic(err)
+// Records a latency, clamped to the range the histogram can track, and returns whether it had to be clamped;
+// hdrhistogram would reject the value otherwise
+func recordClamped(histo *hdrhistogram.Histogram, latency time.Duration) (bool, error) {
+	micros := latency.Microseconds()
+	clamped := false
+	if micros > histo.HighestTrackableValue() {
+		micros, clamped = histo.HighestTrackableValue(), true
+	} else if micros < histo.LowestTrackableValue() {
+		micros, clamped = histo.LowestTrackableValue(), true
 	}
+	return clamped, histo.RecordValue(micros)
 }
 
-func (o *InteractiveOutput) ReportProgress(report ProgressReport) {
-	now := time.Now()
-	if report.Section == o.LastProgressReport.Section && report.Step == o.LastProgressReport.Step && now.Sub(o.LastProgressTime).Seconds() < 10 {
+// Merges phase latencies from another result for the same script into this one
+func (s *ScriptResult) mergePhases(phases map[string]*hdrhistogram.Histogram) {
+	s.Phases = mergeNamedHistograms(s.Phases, phases)
+}
+
+// Merges latencies without retries from another result for the same script into this one, copying rather than
+// sharing them like mergeNamedHistograms
+func (s *ScriptResult) mergeWithoutRetries(histo *hdrhistogram.Histogram) {
+	if histo == nil {
 		return
 	}
-	o.LastProgressReport = report
-	o.LastProgressTime = now
-	_, err := fmt.Fprintf(o.ErrStream, "[%s][%s] %.02f%%\n", report.Section, report.Step, report.Completeness*100)
-	if err != nil {
-		panic(err)
+	if s.WithoutRetries == nil {
+		s.WithoutRetries = hdrhistogram.Import(histo.Export())
+		return
 	}
+	s.WithoutRetries.Merge(histo)
 }
 
-func (o *InteractiveOutput) ReportThroughput(result Result) {
-	s := strings.Builder{}
-
-	s.WriteString("== Results ==\n")
-	s.WriteString(fmt.Sprintf("Scenario: %s\n", result.Scenario))
-	s.WriteString(fmt.Sprintf("Successful Transactions: %d (%.3f per second)\n", result.TotalSucceeded(), result.TotalRate()))
-	s.WriteString("\n")
-	for _, script := range result.Scripts {
-		s.WriteString(fmt.Sprintf("  [%s]: %.03f successful transactions per second\n", script.ScriptName, script.Rate))
-	}
-	s.WriteString("\n")
-	writeErrorReport(result, &s)
-
-	_, err := fmt.Fprintf(o.OutStream, s.String())
-	if err != nil {
-		panic(err)
+func (s *ScriptResult) recordWithoutRetries(latency time.Duration) error {
+	if s.WithoutRetries == nil {
+		s.WithoutRetries = histogramConfigOf(s.Latencies).newHistogram()
 	}
+	// Never longer than the whole transaction, so anything out of range is counted there
+	_, err := recordClamped(s.WithoutRetries, latency)
+	return err
 }
 
-func (o *InteractiveOutput) ReportLatency(result Result) {
-	s := strings.Builder{}
-
-	s.WriteString("== Results ==\n")
-
-	s.WriteString(fmt.Sprintf("Scenario: %s\n", result.Scenario))
-	s.WriteString(fmt.Sprintf("Successful Transactions: %d (%.3f per second)\n", result.TotalSucceeded(), result.TotalRate()))
+// Merges access mode latencies from another result for the same script into this one
+func (s *ScriptResult) mergeAccessModes(modes map[string]*hdrhistogram.Histogram) {
+	s.AccessModes = mergeNamedHistograms(s.AccessModes, modes)
+}
 
-	if result.TotalSucceeded() > 0 {
-		for _, workload := range result.Scripts {
-			s.WriteString("\n")
-			s.WriteString(fmt.Sprintf("-- Script: %s --\n\n", workload.ScriptName))
-			summarizeLatency(workload, &s, "  ")
+// Histograms in from are copied rather than shared, so into can be merged into later without changing them
+func mergeNamedHistograms(into, from map[string]*hdrhistogram.Histogram) map[string]*hdrhistogram.Histogram {
+	for name, histo := range from {
+		if into == nil {
+			into = make(map[string]*hdrhistogram.Histogram)
+		}
+		existing, found := into[name]
+		if !found {
+			into[name] = hdrhistogram.Import(histo.Export())
+		} else {
+			existing.Merge(histo)
 		}
 	}
-	s.WriteString("\n")
-	writeErrorReport(result, &s)
-
-	_, err := fmt.Fprint(o.OutStream, s.String())
-	if err != nil {
-		panic(err)
-	}
+	return into
 }
 
-func summarizeLatency(script *ScriptResult, s *strings.Builder, indent string) {
-	histo := script.Latencies
-	lines := []string{
-		fmt.Sprintf("Successful Transactions: %d (%.3f per second)\n\n", script.Succeeded, script.Rate),
-		fmt.Sprintf("Max: %.3fms, Min: %.3fms, Mean: %.3fms, Stddev: %.3f\n\n",
-			float64(histo.Max())/1000.0, float64(histo.Min())/1000.0, histo.Mean()/1000.0, histo.StdDev()/1000.0),
-		fmt.Sprintf("Latency distribution:\n"),
-		fmt.Sprintf("  P00.000: %.03fms\n", float64(histo.Min())/1000.0),
-		fmt.Sprintf("  P25.000: %.03fms\n", float64(histo.ValueAtQuantile(25))/1000.0),
-		fmt.Sprintf("  P50.000: %.03fms\n", float64(histo.ValueAtQuantile(50))/1000.0),
-		fmt.Sprintf("  P75.000: %.03fms\n", float64(histo.ValueAtQuantile(75))/1000.0),
-		fmt.Sprintf("  P95.000: %.03fms\n", float64(histo.ValueAtQuantile(95))/1000.0),
-		fmt.Sprintf("  P99.000: %.03fms\n", float64(histo.ValueAtQuantile(99))/1000.0),
-		fmt.Sprintf("  P99.999: %.03fms\n", float64(histo.ValueAtQuantile(99.999))/1000.0),
-	}
-	for _, line := range lines {
-		s.WriteString(indent)
-		s.WriteString(line)
-	}
+// Methods that write results return an error if writing fails; see IsBrokenPipe.
+// Progress and errors are best-effort, since there's nowhere left to report it if writing those fails.
+type Output interface {
+	// scenario is a string describing the flags you'd need to pass to neobench to run an equivalent load
+	BenchmarkStart(databaseName, url, scenario string) error
+	ReportProgress(report ProgressReport)
+	ReportWorkloadProgress(completeness float64, checkpoint Result) error
+	// Reports throughput and latency over one sampling interval while the workload runs, to show how
+	// they change over time; called only if sampling is enabled
+	ReportInterval(sample IntervalResult) error
+	// Reports transaction rates only
+	ReportThroughput(result Result) error
+	// Reports transaction rates together with the latency distribution; this is the complete report,
+	// so use it any time you want both from the same run
+	ReportLatency(result Result) error
+	// Reports what a run would do, rather than its results, when asked to plan it without running it
+	ReportPlan(plan Plan) error
+	// Reports an error that fails the run, even if it goes on to report partial results
+	Errorf(format string, a ...interface{})
+	// Like Errorf, for an error of a known kind; outputs count them by category, and break failures down by
+	// category along with them at the end, see ClassifyError
+	ReportError(category ErrorCategory, err error)
+	// Reports something that went wrong without failing the run, eg. a transient error that was retried
+	Warnf(format string, a ...interface{})
+	// True if Errorf or ReportError has been called, so the run should exit non-zero
+	ErrorsReported() bool
+	// Called once nothing more will be reported, for outputs that write some or all of their results only
+	// once they've seen them all; outputs that write results as they're reported have nothing to do here
+	Close() error
 }
 
-func writeErrorReport(result Result, s *strings.Builder) {
-	s.WriteString(fmt.Sprintf("Error stats:\n"))
-	if result.TotalFailed() == 0 {
-		s.WriteString(fmt.Sprintf("  No errors!\n"))
-	} else {
-		s.WriteString(fmt.Sprintf("  Failed transactions: %d (%.3f %%)\n", result.TotalFailed(), 100*float64(result.TotalFailed())/float64(result.TotalFailed()+result.TotalSucceeded())))
-		s.WriteString(fmt.Sprintf("\n"))
-		s.WriteString(fmt.Sprintf("  Causes:\n"))
-		for name, info := range result.FailedByErrorGroup {
-			s.WriteString(fmt.Sprintf("    %s: %d failures\n", name, info.Count))
-			s.WriteString(fmt.Sprintf("      (ex: %s)\n", info.FirstFailure))
-		}
-	}
+// An Output that can go on to report another run, eg. when neobench is embedded to run benchmarks in a loop;
+// every output neobench has is one. It's apart from Output so outputs written elsewhere needn't be, see ResetOutput
+type ResettableOutput interface {
+	Output
+	// Forgets what the output keeps about the run it's reporting on. Per run: progress rate-limiting and step
+	// timings, bars left drawn, samples and errors counted by category for the error report. Lasting: settings,
+	// results kept to write on Close, headers already written, the clock heatmap rows are timed by, and
+	// ErrorsReported, so a failed run still fails the process. RecordingOutput keeps only what's reported, so
+	// it forgets all of it
+	Reset()
 }
 
-func (o *InteractiveOutput) Errorf(format string, a ...interface{}) {
-	_, err := fmt.Fprintf(o.ErrStream, "ERROR: %s\n", fmt.Sprintf(format, a...))
-	if err != nil {
-		panic(err)
+// Resets out if it's a ResettableOutput; outputs that aren't have nothing they know to forget
+func ResetOutput(out Output) {
+	if resettable, ok := out.(ResettableOutput); ok {
+		resettable.Reset()
 	}
 }
 
-// Writes simple progress to stderr, and then a result for easy import into eg. a spreadsheet or other app
-// in CSV format to stdout
-type CsvOutput struct {
-	ErrStream io.Writer
-	OutStream io.Writer
+// Embedded in each output to rate-limit progress reports and time their steps; NewOutput sets it up from
+// OutputOptions, see configureProgress
+type ProgressReporter struct {
+	// Minimum time between progress reports for the same step, zero reports every update
+	ProgressInterval time.Duration
 	// Used to rate-limit progress reporting
 	LastProgressReport ProgressReport
 	LastProgressTime   time.Time
+	timer              progressTimer
 }
 
-func (o *CsvOutput) BenchmarkStart(databaseName, url, scenario string) {
-	if databaseName == "" {
-		databaseName = "<default>"
-	}
-	_, err := fmt.Fprintf(o.ErrStream,
-		"Starting workload on database %s against %s\n"+
-			"Scenario: %s\n", databaseName, url, scenario)
-	if err != nil {
-		panic(err)
-	}
+func (p *ProgressReporter) progressReporter() *ProgressReporter {
+	return p
+}
 
-	columnNames := make([]string, 0, len(csvColumns))
-	for _, col := range csvColumns {
-		columnNames = append(columnNames, col.name)
+// Sets up the ProgressReporter of outputs that embed one
+func configureProgress(out Output, options OutputOptions) {
+	if o, ok := out.(interface{ progressReporter() *ProgressReporter }); ok {
+		p := o.progressReporter()
+		p.ProgressInterval = options.ProgressInterval
+		p.timer.hidden = options.Deterministic
 	}
-	_, err = fmt.Fprintf(o.OutStream, "%s\n", strings.Join(columnNames, ","))
-	if err != nil {
-		panic(err)
+}
+
+// Records report and returns true if it's due to be written, see progressIsDue; newStep is true if it starts
+// a step, which restarts the step's timer
+func (p *ProgressReporter) due(report ProgressReport, interval time.Duration, now time.Time) (due, newStep bool) {
+	if !progressIsDue(report, p.LastProgressReport, p.LastProgressTime, now, interval) {
+		return false, false
 	}
+	newStep = report.Section != p.LastProgressReport.Section || report.Step != p.LastProgressReport.Step
+	p.LastProgressReport, p.LastProgressTime = report, now
+	p.timer.update(report, newStep, now)
+	return true, newStep
 }
 
-func (o *CsvOutput) ReportProgress(report ProgressReport) {
+// Implements Output.ReportProgress for outputs that write progress lines to w
+func (p *ProgressReporter) reportProgress(w io.Writer, report ProgressReport) {
 	now := time.Now()
-	if report.Section == o.LastProgressReport.Section && report.Step == o.LastProgressReport.Step && now.Sub(o.LastProgressTime).Seconds() < 10 {
-		return
-	}
-	o.LastProgressReport = report
-	o.LastProgressTime = now
-	_, err := fmt.Fprintf(o.ErrStream, "[%s][%s] %.02f%%\n", report.Section, report.Step, report.Completeness*100)
-	if err != nil {
-		panic(err)
+	if due, _ := p.due(report, p.ProgressInterval, now); due {
+		writeProgress(w, report, p.timer.describe(report, now))
 	}
 }
 
-func (o *CsvOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
-	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done\n", completeness*100)
-	if err != nil {
-		panic(err)
+// Forgets progress reported so far, for Output.Reset
+func (p *ProgressReporter) resetProgress() {
+	p.LastProgressReport, p.LastProgressTime = ProgressReport{}, time.Time{}
+	p.timer.stepStart, p.timer.stepStartCompleteness = time.Time{}, 0
+}
+
+// Embedded in each output to implement Output.ErrorsReported
+type errorTracker struct {
+	errorsReported bool
+	// Errors passed to ReportError, for the breakdown in the error report
+	reportedByCategory map[ErrorCategory]int64
+}
+
+func (t *errorTracker) ErrorsReported() bool {
+	return t.errorsReported
+}
+
+// Errors by category are of the run they were reported in; whether any were reported outlasts it
+func (t *errorTracker) reset() {
+	t.reportedByCategory = nil
+}
+
+// Implements Output.ReportError for outputs that write errors as text, with errorf, their Errorf
+func (t *errorTracker) reportError(errorf func(format string, a ...interface{}), category ErrorCategory, err error) {
+	t.trackError(category)
+	errorf("%s error: %s", category, err)
+}
+
+func (t *errorTracker) trackError(category ErrorCategory) {
+	t.errorsReported = true
+	if t.reportedByCategory == nil {
+		t.reportedByCategory = make(map[ErrorCategory]int64)
+	}
+	t.reportedByCategory[category]++
+}
+
+// True if err means whoever was reading our output went away, eg. when piping into `head`;
+// that's not a failure of the benchmark, so callers should exit quietly rather than complain
+func IsBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrClosedPipe)
+}
+
+// Settings shared by the output formats
+type OutputOptions struct {
+	// Latency percentiles to report, each in the range [0, 100]. If empty, each format uses its own default set
+	Percentiles []float64
+	// Where results are written, defaults to stdout
+	OutStream io.Writer
+	// Where to stream results to instead of OutStream, tcp://host:port or unix:///path/to/socket, eg. for
+	// a collector gathering results from many machines; NewOutput fails if it can't connect, and the
+	// connection is closed on Close
+	Destination string
+	// Where progress and errors are written, defaults to stderr
+	ErrStream io.Writer
+	// Minimum time between progress reports for the same step, zero reports every update;
+	// see DefaultProgressInterval
+	ProgressInterval time.Duration
+	// How progress is written to stderr, "text" or "json", see JsonProgressOutput; defaults to text
+	ProgressFormat string
+	// Don't report progress at all, see NoProgressOutput; takes precedence over ProgressFormat
+	NoProgress bool
+	// What a progress report has to change to be written before ProgressInterval is up, and how far completeness
+	// has to move for the same, in percentage points; see ThrottledProgressOutput
+	ProgressKey  string
+	ProgressJump float64
+	// List the progress of each worker under the combined progress of a step, for interactive output
+	ProgressByWorker bool
+	// Draw latency summaries as bordered tables, for interactive output
+	Tables bool
+	// Interpolate percentiles between recorded latencies, for interactive output, see InteractiveOutput
+	InterpolatePercentiles bool
+	// Leave latency distributions out of interactive output, see InteractiveOutput
+	SummaryOnly bool
+	// Percent of the highest and lowest sample rates to leave out of a trimmed mean rate, for interactive and json
+	// output of sampled runs, see Result.TrimmedSampleRate; zero leaves the trimmed rate out
+	TrimRates float64
+	// Leave out header rows, for csv, tsv and quiet output
+	OmitHeader bool
+	// Add a scenario_slug column to csv, tsv and quiet output, see ScenarioSlug
+	ScenarioSlug bool
+	// Rows in cdf output, see CdfOutput
+	CdfPoints int
+	// Unit to show latencies in, for the formats meant to be read by people; defaults to milliseconds.
+	// Formats meant for machines always use milliseconds, so their schema doesn't change
+	LatencyUnit LatencyUnit
+	// Latency targets to mark as met or missed, for interactive, csv, tsv and quiet output
+	PercentileTargets PercentileTargets
+	// Metric to order compare output by, see CompareOutput.SortBy
+	CompareSortBy string
+	// Url to post a summary of the results to once they're all in, see WebhookOutput
+	Webhook string
+	// Collector to push the final results to as OpenTelemetry metrics, see OtlpOutput; an invalid one is warned
+	// about rather than failing
+	OtlpEndpoint string
+	// Temporality of the metrics pushed to OtlpEndpoint, see OtlpOutput.Temporality
+	OtlpTemporality string
+	// Labels every result carries, see TaggingOutput; csv, tsv and quiet output get a column per tag
+	Tags map[string]string
+	// Id every result carries, see TaggingOutput and NewRunId; csv, tsv and quiet output get a run_id column
+	RunId string
+	// Whether the run reports its warmup as results of their own, see Result.Warmup; csv, tsv and quiet output
+	// get a warmup column, and formats in WarmupFormats are the only ones to get the warmup results
+	Warmup bool
+	// Whether the run reports samples while it runs; csv, tsv and quiet output then lead each row with its kind,
+	// see CsvOutput.Samples
+	Samples bool
+	// Results to compare against, for interactive, csv, tsv and quiet output, see Baseline
+	Baseline *Baseline
+	// Decimal places in latencies and rates, from 0 to MaxPrecision, for the formats that take a Precision;
+	// defaults to DefaultPrecision
+	Precision *int
+	// Leave out everything that changes between runs with the same results, for golden-file tests of the
+	// formatting: timestamps, durations and process stats in results, see DeterministicOutput, and timings in
+	// progress. Every progress report is written, as rate-limiting would make which ones depend on timing, and
+	// influx points are all at the epoch
+	Deterministic bool
+}
+
+const DefaultProgressInterval = 10 * time.Second
+
+// Output formats NewOutput knows; quiet writes csv results like csv does, but only errors go to stderr
+var OutputFormats = []string{"auto", "interactive", "csv", "tsv", "json", "prometheus", "influx", "markdown", "html", "hgrm",
+	"cdf", "histogram", "oneline", "keyvalue", "gobench", "compare", "heatmap", "hlog", "protobuf", "quiet"}
+
+// Fails if NewOutput would, for reasons other than the streams; for checking options before opening a file for
+// the output, which would be left truncated otherwise
+func ValidateOutputOptions(name string, options OutputOptions) error {
+	if !containsString(OutputFormats, name) {
+		return unknownOutputFormat(name)
+	}
+	for _, q := range options.Percentiles {
+		if q < 0 || q > 100 {
+			return fmt.Errorf("invalid percentile: %v, percentiles must be between 0 and 100", q)
+		}
+	}
+	if err := ValidateCompareSort(options.CompareSortBy); err != nil {
+		return err
+	}
+	if options.Webhook != "" {
+		if err := ValidateWebhook(options.Webhook); err != nil {
+			return err
+		}
+	}
+	if err := ValidateTags(options.Tags); err != nil {
+		return err
+	}
+	if err := ValidateRunId(options.RunId); err != nil {
+		return err
+	}
+	if err := ValidateTrimRates(options.TrimRates); err != nil {
+		return err
+	}
+	if options.Precision != nil {
+		if err := ValidatePrecision(*options.Precision); err != nil {
+			return err
+		}
+	}
+	if options.ProgressFormat != "" && options.ProgressFormat != "text" && options.ProgressFormat != "json" {
+		return fmt.Errorf("unknown progress format: %s, supported formats are 'text' and 'json'", options.ProgressFormat)
+	}
+	if err := ValidateProgressKey(options.ProgressKey); err != nil {
+		return err
+	}
+	if err := ValidateOtlpTemporality(options.OtlpTemporality); err != nil {
+		return err
+	}
+	if options.ProgressJump < 0 || options.ProgressJump > 100 {
+		return fmt.Errorf("invalid progress jump: %v, it must be between 0 and 100 percentage points", options.ProgressJump)
+	}
+	return nil
+}
+
+func unknownOutputFormat(name string) error {
+	quoted := make([]string, len(OutputFormats))
+	for i, format := range OutputFormats {
+		quoted[i] = "'" + format + "'"
+	}
+	return fmt.Errorf("unknown output format: %s, supported formats are %s and %s "+
+		"('quiet' writes csv results like 'csv' does, but only errors go to stderr)", name,
+		strings.Join(quoted[:len(quoted)-1], ", "), quoted[len(quoted)-1])
+}
+
+func NewOutput(name string, options OutputOptions) (Output, error) {
+	if err := ValidateOutputOptions(name, options); err != nil {
+		return nil, err
+	}
+	outStream, errStream := options.OutStream, options.ErrStream
+	if outStream == nil {
+		outStream = os.Stdout
+	}
+	if errStream == nil {
+		errStream = os.Stderr
+	}
+	var conn net.Conn
+	if options.Destination != "" {
+		var err error
+		if conn, err = dialDestination(options.Destination); err != nil {
+			return nil, errors.Wrapf(err, "failed to connect to %s", options.Destination)
+		}
+		outStream = conn
+	}
+	if options.Deterministic {
+		options.ProgressInterval = 0
+	}
+	// Auto picks the format by where results go, and the bar by where progress goes, so results piped to a
+	// file are csv while the terminal still gets a bar; interactive output draws its own
+	progressBar := name == "auto" && !isTerminal(outStream) && isTerminal(errStream) && !options.Deterministic
+	// Throttled outputs are given every report that gets through, so they don't rate-limit on their own
+	var throttle *ThrottledProgressOutput
+	drawsBar := (name == "auto" || name == "interactive") && isTerminal(errStream) && options.ProgressFormat != "json"
+	if ((options.ProgressKey != "" && options.ProgressKey != "both") || options.ProgressJump > 0) && !drawsBar && !options.Deterministic {
+		throttle = &ThrottledProgressOutput{Interval: options.ProgressInterval, Key: options.ProgressKey, Jump: options.ProgressJump}
+		options.ProgressInterval = 0
+	}
+	out, err := newFormatOutput(name, options, outStream, errStream)
+	if err != nil {
+		if conn != nil {
+			_ = conn.Close()
+		}
+		return nil, err
+	}
+	configureProgress(out, options)
+	format := out
+	if options.Warmup && !containsString(WarmupFormats, name) {
+		out = &NoWarmupOutput{out}
+	}
+	if options.Deterministic {
+		out = &DeterministicOutput{out}
+	}
+	out = &FlushingOutput{Output: out, Stream: outStream}
+	if conn != nil {
+		out = &ClosingOutput{Output: out, Closer: conn}
+	}
+	if options.NoProgress {
+		out = &NoProgressOutput{out}
+	} else if options.ProgressFormat == "json" {
+		jsonProgress := &JsonProgressOutput{Output: out, ErrStream: errStream}
+		configureProgress(jsonProgress, options)
+		out = jsonProgress
+	} else if progressBar {
+		out = &ProgressBarOutput{Output: out, ErrStream: errStream}
+	}
+	if options.Webhook != "" {
+		out = &WebhookOutput{Output: out, URL: options.Webhook}
+	}
+	if options.OtlpEndpoint != "" {
+		if err := ValidateOtlpEndpoint(options.OtlpEndpoint); err != nil {
+			out.Warnf("not exporting metrics: %s", err)
+		} else {
+			out = &OtlpOutput{Output: out, Endpoint: options.OtlpEndpoint, Tags: options.Tags, Temporality: options.OtlpTemporality}
+		}
+	}
+	// Around the webhook and otlp outputs, so what they send is tagged too
+	if len(options.Tags) > 0 || options.RunId != "" {
+		tagging := &TaggingOutput{Output: out, Tags: options.Tags, RunId: options.RunId}
+		// Quiet output writes only errors to stderr and interactive output has the id with its results
+		switch format.(type) {
+		case *QuietOutput, *InteractiveOutput:
+		default:
+			if !options.NoProgress {
+				_, jsonFormat := format.(*JsonOutput)
+				tagging.ErrStream, tagging.JsonEvents = errStream, jsonFormat || options.ProgressFormat == "json"
+			}
+		}
+		out = tagging
+	}
+	if throttle != nil {
+		throttle.Output = out
+		out = throttle
+	}
+	out = &FanInProgressOutput{Output: out}
+	// Workers report errors from their own goroutines
+	return &SynchronizedOutput{Output: out}, nil
+}
+
+func newFormatOutput(name string, options OutputOptions, outStream, errStream io.Writer) (Output, error) {
+	if name == "auto" {
+		if isTerminal(outStream) {
+			name = "interactive"
+		} else {
+			name = "csv"
+		}
+	}
+	if name == "interactive" {
+		return &InteractiveOutput{
+			ProgressBar:            isTerminal(errStream) && !options.Deterministic,
+			ProgressByWorker:       options.ProgressByWorker,
+			LatencyUnit:            options.LatencyUnit,
+			Precision:              options.Precision,
+			Color:                  useColor(outStream),
+			Sparklines:             isTerminal(outStream),
+			Tables:                 options.Tables,
+			Baseline:               options.Baseline,
+			InterpolatePercentiles: options.InterpolatePercentiles,
+			SummaryOnly:            options.SummaryOnly,
+			TrimRates:              options.TrimRates,
+			ErrColor:               useColor(errStream),
+			ErrStream:              errStream,
+			OutStream:              outStream,
+			Percentiles:            options.Percentiles,
+			Targets:                options.PercentileTargets,
+		}, nil
+	}
+	if name == "csv" {
+		return &CsvOutput{
+			OmitHeader:   options.OmitHeader,
+			ScenarioSlug: options.ScenarioSlug,
+			Baseline:     options.Baseline,
+			Tags:         options.Tags,
+			RunId:        options.RunId,
+			Warmup:       options.Warmup,
+			Samples:      options.Samples,
+			ErrStream:    errStream,
+			OutStream:    outStream,
+			Percentiles:  options.Percentiles,
+			Targets:      options.PercentileTargets,
+			LatencyUnit:  options.LatencyUnit,
+			Precision:    options.Precision,
+		}, nil
+	}
+	if name == "tsv" {
+		return &CsvOutput{
+			OmitHeader:   options.OmitHeader,
+			ScenarioSlug: options.ScenarioSlug,
+			Baseline:     options.Baseline,
+			Tags:         options.Tags,
+			RunId:        options.RunId,
+			Warmup:       options.Warmup,
+			Samples:      options.Samples,
+			Tabs:         true,
+			ErrStream:    errStream,
+			OutStream:    outStream,
+			Percentiles:  options.Percentiles,
+			Targets:      options.PercentileTargets,
+			LatencyUnit:  options.LatencyUnit,
+			Precision:    options.Precision,
+		}, nil
+	}
+	if name == "json" {
+		return &JsonOutput{
+			ErrStream:   errStream,
+			OutStream:   outStream,
+			Percentiles: options.Percentiles,
+			TrimRates:   options.TrimRates,
+		}, nil
+	}
+	if name == "prometheus" {
+		return &PrometheusOutput{
+			ErrStream:   errStream,
+			OutStream:   outStream,
+			Percentiles: options.Percentiles,
+		}, nil
+	}
+	if name == "influx" {
+		out := &InfluxOutput{
+			ErrStream:   errStream,
+			OutStream:   outStream,
+			Percentiles: options.Percentiles,
+		}
+		if options.Deterministic {
+			out.now = func() time.Time { return time.Unix(0, 0) }
+		}
+		return out, nil
+	}
+	if name == "markdown" {
+		return &MarkdownOutput{
+			ErrStream:   errStream,
+			OutStream:   outStream,
+			Percentiles: options.Percentiles,
+			LatencyUnit: options.LatencyUnit,
+			Precision:   options.Precision,
+		}, nil
+	}
+	if name == "html" {
+		return &HtmlOutput{
+			ErrStream:   errStream,
+			OutStream:   outStream,
+			Percentiles: options.Percentiles,
+			LatencyUnit: options.LatencyUnit,
+			Precision:   options.Precision,
+		}, nil
+	}
+	if name == "oneline" {
+		return &OnelineOutput{
+			ErrStream: errStream,
+			OutStream: outStream,
+			Precision: options.Precision,
+		}, nil
+	}
+	if name == "keyvalue" {
+		return &KeyvalueOutput{
+			ErrStream:   errStream,
+			OutStream:   outStream,
+			Percentiles: options.Percentiles,
+			Precision:   options.Precision,
+		}, nil
+	}
+	if name == "gobench" {
+		return &GobenchOutput{
+			ErrStream: errStream,
+			OutStream: outStream,
+		}, nil
+	}
+	if name == "compare" {
+		return &CompareOutput{
+			ErrStream:   errStream,
+			OutStream:   outStream,
+			Percentiles: options.Percentiles,
+			LatencyUnit: options.LatencyUnit,
+			Precision:   options.Precision,
+			SortBy:      options.CompareSortBy,
+		}, nil
+	}
+	if name == "heatmap" {
+		return &HeatmapOutput{
+			ErrStream:   errStream,
+			OutStream:   outStream,
+			Percentiles: options.Percentiles,
+			LatencyUnit: options.LatencyUnit,
+			Precision:   options.Precision,
+		}, nil
+	}
+	if name == "hlog" {
+		return &HistlogOutput{
+			ErrStream: errStream,
+			OutStream: outStream,
+		}, nil
+	}
+	if name == "quiet" {
+		return &QuietOutput{CsvOutput{
+			OmitHeader:   options.OmitHeader,
+			ScenarioSlug: options.ScenarioSlug,
+			Baseline:     options.Baseline,
+			Tags:         options.Tags,
+			RunId:        options.RunId,
+			Warmup:       options.Warmup,
+			Samples:      options.Samples,
+			ErrStream:    errStream,
+			OutStream:    outStream,
+			Percentiles:  options.Percentiles,
+			Targets:      options.PercentileTargets,
+			LatencyUnit:  options.LatencyUnit,
+			Precision:    options.Precision,
+		}}, nil
+	}
+	if name == "protobuf" {
+		return &ProtobufOutput{
+			ErrStream:   errStream,
+			OutStream:   outStream,
+			Percentiles: options.Percentiles,
+		}, nil
+	}
+	if name == "hgrm" {
+		return &HgrmOutput{
+			ErrStream: errStream,
+			OutStream: outStream,
+		}, nil
+	}
+	if name == "cdf" {
+		return &CdfOutput{
+			ErrStream: errStream,
+			OutStream: outStream,
+			Points:    options.CdfPoints,
+		}, nil
+	}
+	if name == "histogram" {
+		return &HistogramOutput{
+			ErrStream: errStream,
+			OutStream: outStream,
+		}, nil
+	}
+	return nil, unknownOutputFormat(name)
+}
+
+// True if w is a character device, eg. a terminal rather than a pipe or a file
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Color is used for terminals, unless turned off with the NO_COLOR environment variable, see https://no-color.org
+func useColor(w io.Writer) bool {
+	return os.Getenv("NO_COLOR") == "" && isTerminal(w)
+}
+
+// ANSI SGR codes used by colorize
+const (
+	ansiBold   = "1"
+	ansiRed    = "31"
+	ansiGreen  = "32"
+	ansiYellow = "33"
+	ansiCyan   = "36"
+)
+
+// Wraps s in the given ANSI style, or leaves it alone if color is disabled
+func colorize(enabled bool, code, s string) string {
+	if !enabled {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// Latency percentiles each format reports unless told otherwise
+var (
+	DefaultInteractivePercentiles = []float64{0, 10, 25, 50, 75, 95, 99, 99.9, 99.99, 99.999}
+	DefaultCsvPercentiles         = []float64{0, 25, 50, 75, 99, 99.999, 100}
+	DefaultJsonPercentiles        = []float64{25, 50, 75, 95, 99, 99.999}
+)
+
+type InteractiveOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Latency percentiles to report, in ascending order whatever order they're given in; defaults to
+	// DefaultInteractivePercentiles
+	Percentiles []float64
+	// Reported percentiles with a target are marked as meeting it or not
+	Targets PercentileTargets
+	// Unit to show latencies in, defaults to milliseconds
+	LatencyUnit LatencyUnit
+	// Decimal places in latencies and rates, defaults to DefaultPrecision
+	Precision *int
+	ProgressReporter
+	// Draw progress as a single bar that is redrawn in place, rather than one line per update;
+	// only makes sense when ErrStream is a terminal
+	ProgressBar bool
+	// Write a line for each worker under the combined progress of steps workers share; the bar is a single
+	// line, so this writes lines instead of drawing it
+	ProgressByWorker bool
+	// Whether to use ANSI colors in OutStream and ErrStream respectively, see useColor
+	Color    bool
+	ErrColor bool
+	// Draw the shape of each latency distribution as a line of unicode bars, which only reads well on a terminal
+	Sparklines bool
+	// Draw each latency summary and distribution as a bordered table rather than indented lines, easier to scan
+	// when the sections of several scripts stack up
+	Tables bool
+	// Estimate percentiles by interpolating between recorded latencies rather than reporting the value of the
+	// bucket they fall in, see interpolatedValueAtPercentile; smoother for short runs, and labeled as estimates
+	InterpolatePercentiles bool
+	// Leave the distribution out of each latency summary, keeping only min, max, mean and stddev, for quick
+	// checks where the percentiles are just noise
+	SummaryOnly bool
+	// Percent of the highest and lowest sample rates to leave out of a trimmed mean rate shown next to the mean of
+	// all samples, see Result.TrimmedSampleRate; zero leaves it out
+	TrimRates float64
+	// Results to show changes from, nil to leave comparisons out
+	Baseline *Baseline
+	// Length of the progress bar line, while the cursor is at the end of it rather than on a fresh line
+	progressBarDrawn int
+	// Rates of the most recent samples, for the sparkline in ReportInterval
+	sampleRates []float64
+	errorTracker
+}
+
+// The bar doesn't scroll the terminal, so it can be redrawn much more often than progress lines are written
+const progressBarRedrawInterval = 200 * time.Millisecond
+
+func (o *InteractiveOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	o.endProgressBar()
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *InteractiveOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	o.endProgressBar()
+	_, err := fmt.Fprintf(o.ErrStream, "[%.02f%%] %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+func (o *InteractiveOutput) ReportProgress(report ProgressReport) {
+	now := time.Now()
+	interval := o.ProgressInterval
+	if o.ProgressBar {
+		interval = progressBarRedrawInterval
+	}
+	due, newStep := o.due(report, interval, now)
+	if !due {
+		return
+	}
+	timing := o.timer.describe(report, now)
+	if o.ProgressByWorker && len(report.Workers) > 0 {
+		o.endProgressBar()
+		writeProgress(o.ErrStream, report, timing)
+		for _, worker := range report.Workers {
+			_, _ = fmt.Fprintf(o.ErrStream, "  [%s] %.02f%%\n", worker.Worker, worker.Completeness*100)
+		}
+		return
+	}
+	if !o.ProgressBar {
+		writeProgress(o.ErrStream, report, timing)
+		return
+	}
+
+	if newStep {
+		o.endProgressBar()
+	}
+	o.progressBarDrawn = drawProgressBar(o.ErrStream, report, timing, o.progressBarDrawn)
+}
+
+// Redraws the bar over the line drawn last, which was drawn characters long, and returns the length of the new one
+func drawProgressBar(w io.Writer, report ProgressReport, timing string, drawn int) int {
+	line := fmt.Sprintf("[%s][%s] %s %6.02f%%%s", report.Section, report.Step,
+		progressBar(report.Completeness, 30), report.Completeness*100, timing)
+	// Pad to cover what's left of a longer previous line
+	padding := ""
+	if drawn > len(line) {
+		padding = strings.Repeat(" ", drawn-len(line))
+	}
+	// Best-effort, see Output
+	_, _ = fmt.Fprintf(w, "\r%s%s", line, padding)
+	return len(line)
+}
+
+// Moves to a fresh line if a progress bar is drawn, so other output doesn't get appended to the bar
+func (o *InteractiveOutput) endProgressBar() {
+	if o.progressBarDrawn > 0 {
+		_, _ = fmt.Fprint(o.ErrStream, "\n")
+		o.progressBarDrawn = 0
+	}
+}
+
+// eg. [##########          ] for 50% done with a width of 20
+func progressBar(completeness float64, width int) string {
+	done := int(math.Round(completeness * float64(width)))
+	if done < 0 {
+		done = 0
+	}
+	if done > width {
+		done = width
+	}
+	return "[" + strings.Repeat("#", done) + strings.Repeat(" ", width-done) + "]"
+}
+
+// Number of recent samples shown in the sparkline written by ReportInterval
+const sampleSparklineLength = 20
+
+func (o *InteractiveOutput) ReportInterval(sample IntervalResult) error {
+	o.endProgressBar()
+	total := sample.Total()
+	o.sampleRates = append(o.sampleRates, total.Rate)
+	if len(o.sampleRates) > sampleSparklineLength {
+		o.sampleRates = o.sampleRates[len(o.sampleRates)-sampleSparklineLength:]
+	}
+	unit, places := resolveLatencyUnit(o.LatencyUnit, sample.Result), decimalPlaces(o.Precision)
+	_, err := fmt.Fprintf(o.ErrStream, "[%s] %-*s %s tps, P50 %s, P99 %s, %d failures\n",
+		sample.End.Format("15:04:05"), sampleSparklineLength, sparkline(o.sampleRates), formatRate(total.Rate, places),
+		unit.format(valueAtPercentile(total.Latencies, 50), places), unit.format(valueAtPercentile(total.Latencies, 99), places),
+		total.Failed)
+	return err
+}
+
+// Number of bars in the sparkline of each latency distribution, see InteractiveOutput.Sparklines
+const histogramSparklineLength = 20
+
+// Shape of the distribution from min to max latency, as counts in equally wide buckets drawn by sparkline
+func histogramSparkline(histo *hdrhistogram.Histogram, buckets int) string {
+	counts := make([]float64, buckets)
+	min, max := histo.Min(), histo.Max()
+	width := float64(max-min+1) / float64(buckets)
+	for _, bar := range histo.Distribution() {
+		if bar.Count == 0 {
+			continue
+		}
+		// Buckets of the histogram are rounded, so their middle can be just outside the recorded range
+		value := (bar.From + bar.To) / 2
+		if value < min {
+			value = min
+		}
+		i := int(float64(value-min) / width)
+		if i >= buckets {
+			i = buckets - 1
+		}
+		counts[i] += float64(bar.Count)
+	}
+	return sparkline(counts)
+}
+
+// Bar chart of values as a line of text, scaled to the largest value, eg. "▁▃▅█"
+func sparkline(values []float64) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	max := 0.0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	s := strings.Builder{}
+	for _, v := range values {
+		bar := 0
+		if max > 0 {
+			bar = int(math.Round(v / max * float64(len(bars)-1)))
+		}
+		s.WriteRune(bars[bar])
+	}
+	return s.String()
+}
+
+func (o *InteractiveOutput) ReportThroughput(result Result) error {
+	o.endProgressBar()
+	_, err := fmt.Fprint(o.OutStream, o.FormatThroughputSummary(result))
+	return err
+}
+
+// The results section ReportThroughput writes, for embedding in reports of your own; formatted with o's settings,
+// and colored if o.Color is set
+func (o *InteractiveOutput) FormatThroughputSummary(result Result) string {
+	s := strings.Builder{}
+
+	s.WriteString(colorize(o.Color, ansiCyan, resultsHeading(result)) + "\n")
+	s.WriteString(fmt.Sprintf("Scenario: %s\n", result.Scenario))
+	writeVersions(result, &s)
+	writeRunId(result, &s)
+	writeTags(result, &s)
+	writeMeasurementWindow(result, &s)
+	writeInterrupted(result, &s, o.Color)
+	writeRequested(result, &s, o.Color)
+	places := decimalPlaces(o.Precision)
+	rate := formatRate(result.TotalRate(), places) + " per second"
+	if min, max, ok := result.SampleRateRange(); ok {
+		rate += fmt.Sprintf(", min %s and max %s in a sample", formatRate(min, places), formatRate(max, places))
+	}
+	s.WriteString(colorize(o.Color, ansiBold, fmt.Sprintf("Successful Transactions: %d (%s)", result.TotalSucceeded(), rate)) + "\n")
+	writeRateStability(result, &s)
+	writeTrimmedRate(result, o.TrimRates, places, &s)
+	s.WriteString(fmt.Sprintf("Queries: %d (%s per second)\n", result.Total().Queries, formatRate(result.TotalQueryRate(), places)))
+	if total := result.Total(); total.Records > 0 {
+		s.WriteString(fmt.Sprintf("Records: %d (%s per second, about %s bytes per second)\n",
+			total.Records, formatRate(result.TotalRecordRate(), places), formatRate(result.TotalByteRate(), places)))
+	}
+	writeErrorRate(result, &s, o.Color)
+	writeRetries(result, &s)
+	writeConcurrency(result, &s)
+	writePoolWait(result, &s)
+	writeClientCpu(result, &s)
+	writeBaselineComparison(result, o.Baseline, o.percentiles(), resolveLatencyUnit(o.LatencyUnit, result), places, &s, o.Color)
+	s.WriteString("\n")
+	unit := resolveLatencyUnit(o.LatencyUnit, result)
+	for _, script := range result.Scripts {
+		s.WriteString(fmt.Sprintf("  [%s]: %s successful transactions per second, %s queries per second",
+			script.ScriptName, formatRate(script.Rate, places), formatRate(script.QueryRate, places)))
+		// Latencies are only comparable between runs in latency mode, but they're still useful as ballpark figures
+		if script.Latencies.TotalCount() > 0 {
+			s.WriteString(fmt.Sprintf(", mean latency %s, P99 %s",
+				unit.format(script.Latencies.Mean(), places), unit.format(valueAtPercentile(script.Latencies, 99), places)))
+		}
+		s.WriteString("\n")
+	}
+	s.WriteString("\n")
+	writeRateDistribution(result, places, &s)
+	writeSlowest(result, unit, places, &s)
+	writeProcessStats(result, &s)
+	writeErrorReport(result, o.reportedByCategory, &s, o.Color)
+	return s.String()
+}
+
+func (o *InteractiveOutput) ReportLatency(result Result) error {
+	o.endProgressBar()
+	_, err := fmt.Fprint(o.OutStream, o.FormatLatencySummary(result))
+	return err
+}
+
+// The results section ReportLatency writes, for embedding in reports of your own; formatted with o's settings,
+// and colored if o.Color is set
+func (o *InteractiveOutput) FormatLatencySummary(result Result) string {
+	s := strings.Builder{}
+
+	s.WriteString(colorize(o.Color, ansiCyan, resultsHeading(result)) + "\n")
+
+	s.WriteString(fmt.Sprintf("Scenario: %s\n", result.Scenario))
+	writeVersions(result, &s)
+	writeRunId(result, &s)
+	writeTags(result, &s)
+	writeMeasurementWindow(result, &s)
+	writeInterrupted(result, &s, o.Color)
+	writeRequested(result, &s, o.Color)
+	places := decimalPlaces(o.Precision)
+	s.WriteString(colorize(o.Color, ansiBold, fmt.Sprintf("Successful Transactions: %d (%s per second)", result.TotalSucceeded(), formatRate(result.TotalRate(), places))) + "\n")
+	writeErrorRate(result, &s, o.Color)
+	writeRetries(result, &s)
+	if result.Duration > 0 {
+		s.WriteString(colorize(o.Color, ansiBold, fmt.Sprintf("Measured Rate: %s successful transactions per second over %s",
+			formatRate(result.MeasuredRate(), places), result.Duration.Round(time.Millisecond))) + "\n")
+	}
+	writeRateStability(result, &s)
+	writeTrimmedRate(result, o.TrimRates, places, &s)
+	writeConcurrency(result, &s)
+	writePoolWait(result, &s)
+	writeClientCpu(result, &s)
+	writeBaselineComparison(result, o.Baseline, o.percentiles(), resolveLatencyUnit(o.LatencyUnit, result), places, &s, o.Color)
+
+	if result.TotalSucceeded() > 0 {
+		unit := resolveLatencyUnit(o.LatencyUnit, result)
+		for _, workload := range result.Scripts {
+			s.WriteString("\n")
+			s.WriteString(colorize(o.Color, ansiCyan, fmt.Sprintf("-- Script: %s --", workload.ScriptName)) + "\n\n")
+			o.summarizeLatency(workload, unit, &s, "  ")
+		}
+		if len(result.Scripts) > 1 {
+			s.WriteString("\n")
+			s.WriteString(colorize(o.Color, ansiCyan, "-- All scripts --") + "\n\n")
+			o.summarizeLatency(result.Total(), unit, &s, "  ")
+		}
+		s.WriteString(fmt.Sprintf("\nLatencies are accurate to %s\n", describePrecision(sortedScripts(result)[0].Latencies)))
+	} else {
+		s.WriteString("\n" + colorize(o.Color, ansiRed, noLatenciesMessage) + "\n")
+	}
+	s.WriteString("\n")
+	writeRateDistribution(result, places, &s)
+	writeSlowest(result, resolveLatencyUnit(o.LatencyUnit, result), places, &s)
+	writeProcessStats(result, &s)
+	writeErrorReport(result, o.reportedByCategory, &s, o.Color)
+	return s.String()
+}
+
+func (o *InteractiveOutput) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultInteractivePercentiles
+	}
+	percentiles := append([]float64{}, o.Percentiles...)
+	sort.Float64s(percentiles)
+	return percentiles
+}
+
+// Written in place of latencies where there are none, rather than zeros that look like a measurement
+const noLatenciesMessage = "No successful transactions recorded, so there are no latencies to report"
+
+func (o *InteractiveOutput) summarizeLatency(script *ScriptResult, unit LatencyUnit, s *strings.Builder, indent string) {
+	percentiles, targets, places, color := o.percentiles(), o.Targets, decimalPlaces(o.Precision), o.Color
+	histo := script.Latencies
+	if histo.TotalCount() == 0 {
+		s.WriteString(indent + colorize(color, ansiRed, noLatenciesMessage) + "\n")
+		return
+	}
+	distribution := func(q float64) string {
+		latency := float64(valueAtPercentile(histo, q))
+		if o.InterpolatePercentiles {
+			latency = interpolatedValueAtPercentile(histo, q)
+		}
+		value := colorize(color, ansiBold, unit.format(latency, places))
+		if q >= tailPercentile {
+			value += fmt.Sprintf(" (n=%d)", countAtOrAbove(histo, int64(math.Ceil(latency))))
+		}
+		if target, found := targets[q]; found {
+			mark := colorize(color, ansiGreen, "✓")
+			if !targets.met(q, int64(math.Ceil(latency))) {
+				mark = colorize(color, ansiRed, "✗")
+			}
+			value += fmt.Sprintf(" %s (target %s)", mark, unit.format(target.Microseconds(), places))
+		}
+		return value
+	}
+	var lines []string
+	if o.Tables {
+		rows := [][]string{
+			{"Successful Transactions", fmt.Sprintf("%d (%s per second)", script.Succeeded, formatRate(script.Rate, places))},
+			{"Max", unit.format(histo.Max(), places)},
+			{"Min", unit.format(histo.Min(), places)},
+			{"Mean", unit.format(histo.Mean(), places)},
+			{"Stddev", unit.format(histo.StdDev(), places)},
+			{"Standard error of the mean", unit.format(standardError(histo), places)},
+			{"95% confidence interval", fmt.Sprintf("%s - %s", unit.format(histo.Mean()-ci95*standardError(histo), places),
+				unit.format(histo.Mean()+ci95*standardError(histo), places))},
+		}
+		if o.Sparklines && !o.SummaryOnly {
+			rows = append(rows, []string{"Distribution", histogramSparkline(histo, histogramSparklineLength)})
+		}
+		if !o.SummaryOnly {
+			// Separates the summary from the distribution
+			rows = append(rows, nil)
+			for _, q := range percentiles {
+				label := "P" + percentileLabel(q)
+				if o.InterpolatePercentiles {
+					label += " (interpolated)"
+				}
+				rows = append(rows, []string{label, distribution(q)})
+			}
+		}
+		lines = drawTable(rows)
+	} else {
+		lines = []string{
+			fmt.Sprintf("Successful Transactions: %d (%s per second)\n\n", script.Succeeded, formatRate(script.Rate, places)),
+			fmt.Sprintf("Max: %s, Min: %s, Mean: %s, Stddev: %s\n",
+				unit.format(histo.Max(), places), unit.format(histo.Min(), places), unit.format(histo.Mean(), places), unit.format(histo.StdDev(), places)),
+			fmt.Sprintf("Standard error of the mean: %s, 95%% confidence interval of the mean: %s - %s\n",
+				unit.format(standardError(histo), places), unit.format(histo.Mean()-ci95*standardError(histo), places),
+				unit.format(histo.Mean()+ci95*standardError(histo), places)),
+		}
+		if !o.SummaryOnly {
+			lines = append(lines, "\n")
+			heading := "Latency distribution"
+			if o.InterpolatePercentiles {
+				heading += " (interpolated)"
+			}
+			if o.Sparklines {
+				lines = append(lines, fmt.Sprintf("%s: %s (%s to %s)\n", heading, histogramSparkline(histo, histogramSparklineLength),
+					unit.format(histo.Min(), places), unit.format(histo.Max(), places)))
+			} else {
+				lines = append(lines, heading+":\n")
+			}
+			for _, q := range percentiles {
+				lines = append(lines, fmt.Sprintf("  P%s: %s\n", percentileLabel(q), distribution(q)))
+			}
+		}
+	}
+	if script.Retries > 0 {
+		lines = append(lines, "\n", fmt.Sprintf("Retries: %d, latency including retries: mean %s, P99 %s\n", script.Retries,
+			unit.format(histo.Mean(), places), unit.format(valueAtPercentile(histo, 99), places)))
+		if without := script.WithoutRetries; without != nil {
+			lines = append(lines, fmt.Sprintf("  Without retries: mean %s, P99 %s\n",
+				unit.format(without.Mean(), places), unit.format(valueAtPercentile(without, 99), places)))
+		}
+	}
+	if len(script.Phases) > 0 {
+		lines = append(lines, "\n", "Latency by phase:\n")
+		for _, phase := range Phases {
+			if phaseHisto, found := script.Phases[phase]; found {
+				lines = append(lines, fmt.Sprintf("  %s: mean %s, P50 %s, P99 %s, max %s\n", phase,
+					unit.format(phaseHisto.Mean(), places), unit.format(valueAtPercentile(phaseHisto, 50), places),
+					unit.format(valueAtPercentile(phaseHisto, 99), places), unit.format(phaseHisto.Max(), places)))
+			}
+		}
+	}
+	// With only one access mode, its latencies are the same as the script's
+	if len(script.AccessModes) > 1 {
+		for _, mode := range AccessModes {
+			if modeHisto, found := script.AccessModes[mode]; found {
+				lines = append(lines, "\n", fmt.Sprintf("%s latency: %d transactions, mean %s, P50 %s, P99 %s, max %s\n",
+					strings.ToUpper(mode[:1])+mode[1:], modeHisto.TotalCount(), unit.format(modeHisto.Mean(), places),
+					unit.format(valueAtPercentile(modeHisto, 50), places), unit.format(valueAtPercentile(modeHisto, 99), places),
+					unit.format(modeHisto.Max(), places)))
+			}
+		}
+	}
+	for _, line := range lines {
+		if line != "\n" {
+			s.WriteString(indent)
+		}
+		s.WriteString(line)
+	}
+}
+
+// Lines of a table with a border around it and between its columns, each as wide as its widest cell; a nil row
+// draws a line across, to split the table into sections
+func drawTable(rows [][]string) []string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := displayWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	border := "+"
+	for _, w := range widths {
+		border += strings.Repeat("-", w+2) + "+"
+	}
+	border += "\n"
+
+	lines := []string{border}
+	for _, row := range rows {
+		if row == nil {
+			lines = append(lines, border)
+			continue
+		}
+		line := "|"
+		for i, w := range widths {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			line += " " + cell + strings.Repeat(" ", w-displayWidth(cell)) + " |"
+		}
+		lines = append(lines, line+"\n")
+	}
+	return append(lines, border)
+}
+
+// Columns s takes up on a terminal: one per rune, leaving out the ANSI styles colorize adds
+func displayWidth(s string) int {
+	width, escaped := 0, false
+	for _, c := range s {
+		switch {
+		case c == '\x1b':
+			escaped = true
+		case escaped:
+			escaped = c != 'm'
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// How precise values recorded in histo are, eg. "3 significant figures, between 1µs and 1h0m0s"; latencies are
+// in microseconds, so nothing below one is tracked whatever the histogram says its lowest value is
+func describePrecision(histo *hdrhistogram.Histogram) string {
+	lowest := histo.LowestTrackableValue()
+	if lowest < 1 {
+		lowest = 1
+	}
+	return fmt.Sprintf("%d significant figures, between %s and %s", histo.SignificantFigures(),
+		time.Duration(lowest)*time.Microsecond, time.Duration(histo.HighestTrackableValue())*time.Microsecond)
+}
+
+// Readable summary of a plan; formats without a layout of their own for plans write this
+func writePlan(w io.Writer, plan Plan, color bool) error {
+	s := strings.Builder{}
+	s.WriteString(colorize(color, ansiCyan, "== Plan ==") + "\n")
+	s.WriteString(fmt.Sprintf("Scenario: %s\n", plan.Scenario))
+	databaseName := plan.DatabaseName
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	s.WriteString(fmt.Sprintf("Database: %s\n", databaseName))
+	s.WriteString(fmt.Sprintf("Clients: %d\n", plan.Config.Clients))
+	if plan.Config.Transactions > 0 {
+		s.WriteString(fmt.Sprintf("Transactions: %d\n", plan.Config.Transactions))
+	} else {
+		s.WriteString(fmt.Sprintf("Duration: %s\n", plan.Config.Duration))
+	}
+	if plan.Config.TargetRate > 0 {
+		s.WriteString(fmt.Sprintf("Mode: latency, at %.3f transactions per second in total\n", plan.Config.TargetRate))
+	} else {
+		s.WriteString("Mode: throughput, as many transactions per second as the clients manage\n")
+	}
+	if plan.Init {
+		s.WriteString("Dataset: generated before the run\n")
+	}
+	s.WriteString("Scripts:\n")
+	for _, script := range plan.Scripts {
+		s.WriteString(fmt.Sprintf("  [%s]: weight %s, %.1f%% of transactions", script.Name,
+			strconv.FormatFloat(script.Weight, 'f', -1, 64), plan.share(script)*100))
+		if script.Readonly {
+			s.WriteString(", read-only")
+		}
+		s.WriteString("\n")
+	}
+	_, err := fmt.Fprint(w, s.String())
+	return err
+}
+
+func writeRateStability(result Result, s *strings.Builder) {
+	if cv, ok := result.RateVariation(); ok {
+		s.WriteString(fmt.Sprintf("Rate stability: %.1f%% coefficient of variation over %d samples\n", cv*100, len(result.SampleRates)))
+	}
+}
+
+// Both means, and how far apart they are, so it's clear how much the trimmed samples moved it
+func writeTrimmedRate(result Result, percent float64, places int, s *strings.Builder) {
+	if percent <= 0 {
+		return
+	}
+	trimmed, ok := result.TrimmedSampleRate(percent)
+	if !ok {
+		return
+	}
+	mean, _ := result.TrimmedSampleRate(0)
+	change := ""
+	if mean > 0 {
+		change = fmt.Sprintf(" (%+.1f%%)", 100*(trimmed-mean)/mean)
+	}
+	s.WriteString(fmt.Sprintf("Trimmed mean rate: %s per second%s without the top and bottom %s%% of %d samples, %s per second with all of them\n",
+		formatRate(trimmed, places), change, strconv.FormatFloat(percent, 'f', -1, 64), len(result.SampleRates), formatRate(mean, places)))
+}
+
+// Left out unless the run was sampled, see SampleRatePercentile
+func writeRateDistribution(result Result, places int, s *strings.Builder) {
+	if len(result.SampleRates) < 2 {
+		return
+	}
+	s.WriteString(fmt.Sprintf("Throughput distribution over %d samples, Pn is the rate n%% of them were at or below:\n", len(result.SampleRates)))
+	for _, q := range DefaultRatePercentiles {
+		rate, _ := result.SampleRatePercentile(q)
+		s.WriteString(fmt.Sprintf("  P%s: %s per second\n", percentileLabel(q), formatRate(rate, places)))
+	}
+	s.WriteString("\n")
+}
+
+func writeMeasurementWindow(result Result, s *strings.Builder) {
+	if result.StartTime.IsZero() {
+		return
+	}
+	s.WriteString(fmt.Sprintf("Measured: %s to %s\n", formatTimestamp(result.StartTime), formatTimestamp(result.EndTime)))
+}
+
+// Left out unless the run was cut short
+func writeInterrupted(result Result, s *strings.Builder, color bool) {
+	if !result.Interrupted {
+		return
+	}
+	line := "Interrupted: these are the results of the run so far"
+	if result.Duration > 0 && result.Config.Duration > 0 {
+		line = fmt.Sprintf("Interrupted after %s of %s: these are the results of the run so far",
+			result.Duration.Round(time.Millisecond), result.Config.Duration)
+	}
+	s.WriteString(colorize(color, ansiYellow, line) + "\n")
+}
+
+// How many transactions ran against what the run was asked to do, so it's clear whether it got through its
+// budget; a rate-limited run of a set number of transactions that couldn't keep up with the rate takes longer
+// than the rate allows for, which is pointed out. Left out if the run's config isn't known
+func writeRequested(result Result, s *strings.Builder, color bool) {
+	config := result.Config
+	if config.Transactions == 0 && config.Duration == 0 {
+		return
+	}
+	actual := result.TotalSucceeded() + result.TotalFailed()
+	if config.Transactions == 0 {
+		s.WriteString(fmt.Sprintf("Transactions: %d (requested %s)\n", actual, config.Duration))
+		return
+	}
+	s.WriteString(fmt.Sprintf("Transactions: %d (requested %d)\n", actual, config.Transactions))
+	if config.TargetRate <= 0 || result.Duration <= 0 {
+		return
+	}
+	allowed := time.Duration(float64(config.Transactions) / config.TargetRate * float64(time.Second))
+	// Starting and stopping the workers takes a moment, which is no reason to call the rate missed
+	if result.Duration > allowed+allowed/20 {
+		s.WriteString(colorize(color, ansiYellow, fmt.Sprintf("Took %s, where %.3f transactions per second allows for %s: the rate wasn't kept up",
+			result.Duration.Round(time.Millisecond), config.TargetRate, allowed.Round(time.Millisecond))) + "\n")
+	}
+}
+
+// RFC 3339 in UTC, with milliseconds
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// Warmup results are a section of their own, so they aren't mistaken for those of the measured run
+func resultsHeading(result Result) string {
+	if result.Warmup {
+		return "== Warmup =="
+	}
+	return "== Results =="
+}
+
+func writeTags(result Result, s *strings.Builder) {
+	if len(result.Tags) == 0 {
+		return
+	}
+	tags := make([]string, 0, len(result.Tags))
+	for _, key := range sortedTagKeys(result.Tags) {
+		tags = append(tags, key+"="+result.Tags[key])
+	}
+	s.WriteString(fmt.Sprintf("Tags: %s\n", strings.Join(tags, ", ")))
+}
+
+func writeVersions(result Result, s *strings.Builder) {
+	if result.NeobenchVersion == "" && result.Neo4jVersion == "" {
+		return
+	}
+	s.WriteString(fmt.Sprintf("Versions: neobench %s, Neo4j %s\n", orUnknown(result.NeobenchVersion), orUnknown(result.Neo4jVersion)))
+}
+
+func orUnknown(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return v
+}
+
+// Left out if nothing was retried, which is most runs
+func writeRetries(result Result, s *strings.Builder) {
+	retries := result.TotalRetries()
+	if retries == 0 {
+		return
+	}
+	attempted := result.TotalSucceeded() + result.TotalFailed()
+	s.WriteString(fmt.Sprintf("Retries: %d (%.3f per transaction)\n", retries, float64(retries)/float64(attempted)))
+}
+
+// Flagged in red when anything failed, so a run with lots of failures doesn't pass for a clean one at a glance
+func writeErrorRate(result Result, s *strings.Builder, color bool) {
+	line := fmt.Sprintf("Errors: %d (%.3f%% of attempts)", result.TotalFailed(), result.ErrorRate())
+	if result.TotalFailed() > 0 {
+		line = colorize(color, ansiRed, line)
+	}
+	s.WriteString(line + "\n")
+}
+
+// Number of standard errors either side of the mean a 95% confidence interval spans, for a normal distribution
+const ci95 = 1.96
+
+// Standard error of the mean of the recorded values, zero if there are none
+func standardError(histo *hdrhistogram.Histogram) float64 {
+	if histo.TotalCount() == 0 {
+		return 0
+	}
+	return histo.StdDev() / math.Sqrt(float64(histo.TotalCount()))
+}
+
+// Failed transactions by cause and by category, then the errors passed to Output.ReportError, eg. worker crashes,
+// by category
+func writeErrorReport(result Result, reported map[ErrorCategory]int64, s *strings.Builder, color bool) {
+	s.WriteString(colorize(color, ansiCyan, "Error stats:") + "\n")
+	if result.TotalFailed() == 0 && len(reported) == 0 {
+		s.WriteString(fmt.Sprintf("  No errors!\n"))
+		return
+	}
+	// How many failed is in the results above, see writeErrorRate
+	if result.TotalFailed() > 0 {
+		s.WriteString(fmt.Sprintf("  Causes:\n"))
+		for name, info := range result.FailedByErrorGroup {
+			s.WriteString(fmt.Sprintf("    %s: %d failures\n", name, info.Count))
+			s.WriteString(fmt.Sprintf("      (ex: %s)\n", info.FirstFailure))
+		}
+		s.WriteString("\n")
+	}
+	writeErrorCategories(s, "Failed transactions by category", result.FailedByCategory())
+	writeErrorCategories(s, "Errors reported by category", reported)
+}
+
+// Leaves out categories without any, and the title too if there are none
+func writeErrorCategories(s *strings.Builder, title string, counts map[ErrorCategory]int64) {
+	written := false
+	for _, category := range ErrorCategories {
+		if n := counts[category]; n > 0 {
+			if !written {
+				s.WriteString("  " + title + ":\n")
+				written = true
+			}
+			s.WriteString(fmt.Sprintf("    %s: %d\n", category, n))
+		}
 	}
-	o.ReportLatency(checkpoint)
 }
 
-func (o *CsvOutput) ReportThroughput(result Result) {
-	columns := []string{"script", "succeeded", "failed", "transactions_per_second"}
+func (o *InteractiveOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	o.endProgressBar()
+	if !o.ErrColor {
+		writeError(o.ErrStream, format, a...)
+		return
+	}
+	// Best-effort, see Output
+	_, _ = fmt.Fprintln(o.ErrStream, colorize(true, ansiRed, "ERROR: "+fmt.Sprintf(format, a...)))
+}
 
-	s := strings.Builder{}
-	separator := ","
-	s.WriteString(strings.Join(columns, separator))
-	s.WriteString("\n")
+func (o *InteractiveOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
 
-	for _, script := range result.Scripts {
-		row := []float64{
-			float64(script.Succeeded),
-			float64(script.Failed),
-			script.Rate,
+func (o *InteractiveOutput) Warnf(format string, a ...interface{}) {
+	o.endProgressBar()
+	if !o.ErrColor {
+		writeWarning(o.ErrStream, format, a...)
+		return
+	}
+	// Best-effort, see Output
+	_, _ = fmt.Fprintln(o.ErrStream, colorize(true, ansiYellow, "WARN: "+fmt.Sprintf(format, a...)))
+}
+
+func (o *InteractiveOutput) ReportPlan(plan Plan) error {
+	o.endProgressBar()
+	return writePlan(o.OutStream, plan, o.Color)
+}
+
+func (o *InteractiveOutput) Close() error {
+	return nil
+}
+
+func (o *InteractiveOutput) Reset() {
+	o.endProgressBar()
+	o.resetProgress()
+	o.errorTracker.reset()
+	o.sampleRates = nil
+}
+
+// Unit latencies are shown in. Histograms record microseconds with three significant digits, from 1us up to
+// an hour unless configured otherwise, see HistogramConfig, so that's the best resolution any unit can show;
+// decimals beyond that are bucket boundaries
+type LatencyUnit struct {
+	Name string
+	// Microseconds in one of the unit
+	Micros float64
+}
+
+var (
+	LatencyMicroseconds = LatencyUnit{"us", 1}
+	LatencyMilliseconds = LatencyUnit{"ms", 1000}
+	LatencySeconds      = LatencyUnit{"s", 1000 * 1000}
+	// Picks one of the others depending on how long latencies are, see resolveLatencyUnit
+	LatencyAuto = LatencyUnit{Name: "auto"}
+)
+
+func ParseLatencyUnit(name string) (LatencyUnit, error) {
+	for _, unit := range []LatencyUnit{LatencyMicroseconds, LatencyMilliseconds, LatencySeconds, LatencyAuto} {
+		if name == unit.Name {
+			return unit, nil
 		}
-		s.WriteString(fmt.Sprintf("\"%s\",", script.ScriptName))
-		for i, cell := range row {
-			if i > 0 {
-				s.WriteString(separator)
-			}
-			s.WriteString(fmt.Sprintf("%.03f", cell))
+	}
+	return LatencyUnit{}, fmt.Errorf("unknown latency unit: %s, supported units are 'us', 'ms', 's' and 'auto'", name)
+}
+
+// The zero value means milliseconds; LatencyAuto picks the unit by the mean latency of all scripts combined,
+// so sub-millisecond workloads don't lose their resolution and slow ones don't show huge numbers
+func resolveLatencyUnit(unit LatencyUnit, result Result) LatencyUnit {
+	if unit == LatencyAuto {
+		mean := result.Total().Latencies.Mean()
+		if mean < 1000 {
+			return LatencyMicroseconds
 		}
-		s.WriteString("\n")
+		if mean >= 10*1000*1000 {
+			return LatencySeconds
+		}
+		return LatencyMilliseconds
+	}
+	if unit.Micros == 0 {
+		return LatencyMilliseconds
+	}
+	return unit
+}
+
+// Formats microseconds from a histogram in this unit with the given decimal places, eg. "1.234ms"; takes
+// int64 or float64
+func (u LatencyUnit) format(micros interface{}, places int) string {
+	switch v := micros.(type) {
+	case int64:
+		return formatDecimal(float64(v)/u.Micros, places) + u.Name
+	case float64:
+		return formatDecimal(v/u.Micros, places) + u.Name
 	}
+	return fmt.Sprintf("%v?", micros)
+}
 
-	if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
-		panic(err)
+// Value at percentile q in [0, 100]; the edges are the exact min and max, rather than the
+// bucket boundaries ValueAtQuantile rounds them to.
+func valueAtPercentile(histo *hdrhistogram.Histogram, q float64) int64 {
+	if q <= 0 {
+		return histo.Min()
+	}
+	if q >= 100 {
+		return histo.Max()
 	}
+	return histo.ValueAtQuantile(q)
+}
 
-	if result.TotalFailed() > 0 {
-		s.Reset()
-		writeErrorReport(result, &s)
-		if _, err := fmt.Fprint(o.ErrStream, s.String()); err != nil {
-			panic(err)
+// Transactions with latencies at or above value, to within the histogram's precision; the whole bucket value falls
+// in counts, so the latency at a percentile is always counted. Shows how many transactions a tail percentile is
+// based on, eg. P99.999 of a run of ten thousand is just the slowest one
+func countAtOrAbove(histo *hdrhistogram.Histogram, value int64) int64 {
+	var count int64
+	for _, bar := range histo.Distribution() {
+		if bar.To >= value {
+			count += bar.Count
 		}
 	}
+	return count
 }
 
-func (o *CsvOutput) ReportLatency(result Result) {
-	o.writeLatencyRow(result)
+// Percentiles from here up are the tail, which interactive output gives the number of transactions behind
+const tailPercentile = 99
+
+// Estimate of the value at percentile q in [0, 100], interpolating linearly between the recorded values either
+// side of its rank, with the values in each bucket taken to be spread evenly over it. ValueAtQuantile gives the
+// value of the bucket the rank falls in, so with few samples neighbouring percentiles come out the same, eg. P99
+// and P99.9 of a few hundred transactions are both the slowest one; this tells them apart, but it's an estimate
+func interpolatedValueAtPercentile(histo *hdrhistogram.Histogram, q float64) float64 {
+	n := histo.TotalCount()
+	if n == 0 || q <= 0 {
+		return float64(histo.Min())
+	}
+	if q >= 100 {
+		return float64(histo.Max())
+	}
+	rank := q / 100 * float64(n-1)
+	lower := int64(rank)
+	below, above := nthRecordedValue(histo, lower), nthRecordedValue(histo, lower+1)
+	return below + (above-below)*(rank-float64(lower))
 }
 
-func (o *CsvOutput) writeLatencyRow(result Result) {
-	s := strings.Builder{}
+// Value of the n-th recorded value from the lowest, counting from zero; within a bucket, values are taken to be
+// evenly spread over it, and never outside the exact min and max
+func nthRecordedValue(histo *hdrhistogram.Histogram, n int64) float64 {
+	var seen int64
+	for _, bar := range histo.Distribution() {
+		if n >= seen+bar.Count {
+			seen += bar.Count
+			continue
+		}
+		value := float64(bar.From) + float64(bar.To-bar.From)*(float64(n-seen)+0.5)/float64(bar.Count)
+		return math.Max(float64(histo.Min()), math.Min(float64(histo.Max()), value))
+	}
+	return float64(histo.Max())
+}
+
+// Percentile with at least three decimals, eg. 99.9 -> "99.900", 99.9999 -> "99.9999"
+func formatPercentile(q float64) string {
+	s := strconv.FormatFloat(q, 'f', -1, 64)
+	dot := strings.Index(s, ".")
+	if dot == -1 {
+		s, dot = s+".", len(s)
+	}
+	for len(s)-dot-1 < 3 {
+		s += "0"
+	}
+	return s
+}
+
+// Fixed-width label for interactive output, eg. 0 -> "00.000", 99.9 -> "99.900"
+func percentileLabel(q float64) string {
+	if q < 10 {
+		return "0" + formatPercentile(q)
+	}
+	return formatPercentile(q)
+}
+
+// Name for percentile columns and keys; whole percentiles keep their number, others have all
+// decimals appended; eg. 25 -> "p25", 99.9 -> "p99900", 99.999 -> "p99999"
+func percentileColumnName(q float64) string {
+	if q == math.Trunc(q) {
+		return fmt.Sprintf("p%d", int64(q))
+	}
+	return "p" + strings.Replace(formatPercentile(q), ".", "", 1)
+}
+
+// Writes simple progress to stderr, and then a result for easy import into eg. a spreadsheet or other app
+// in CSV format to stdout
+type CsvOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Latency percentiles to report, defaults to DefaultCsvPercentiles
+	Percentiles []float64
+	// Each target gets a column saying whether it was met, after the other latency columns
+	Targets PercentileTargets
+	// Unit latency columns are in, defaults to milliseconds; the header is written before there are any
+	// latencies to choose a unit by, so LatencyAuto means milliseconds here
+	LatencyUnit LatencyUnit
+	// Decimal places in latency and rate columns, defaults to DefaultPrecision
+	Precision *int
+	ProgressReporter
+	// Write tab-separated values, with text escaped rather than quoted, for tools that can't parse quoted CSV
+	Tabs bool
+	// Leave out header rows; an AppendFile OutStream otherwise only gets them while it's empty, so runs sharing
+	// it write one header between them
+	OmitHeader bool
+	// Add the scenario as a filesystem-safe slug in a last column, see ScenarioSlug
+	ScenarioSlug bool
+	// Add columns with the change in rate and latencies from these results, see csvDeltaColumns
+	Baseline *Baseline
+	// Tags the run is labelled with, see TaggingOutput; the header is written before any results, so the tag_
+	// columns are named after these keys, while their values come from each result
+	Tags map[string]string
+	// Add a run_id column, whose values come from each result, see Result.RunId
+	RunId string
+	// Add a warmup column, telling rows of the warmup apart from those of the measured run, see Result.Warmup
+	Warmup bool
+	// The run is sampled; every latency row is then led by row_kind, timestamp and interval_seconds columns, so
+	// samples and results share one header, see ReportInterval
+	Samples bool
+	errorTracker
+}
 
+func (o *CsvOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	if err := writeBenchmarkStart(o.ErrStream, databaseName, url, scenario); err != nil {
+		return err
+	}
+	return o.writeLatencyHeader()
+}
+
+func (o *CsvOutput) writeLatencyHeader() error {
+	// Another run may have written to a shared file by the time results come in, so the header goes with them
+	if _, ok := o.OutStream.(*AppendFile); ok || o.OmitHeader {
+		return nil
+	}
+	_, err := fmt.Fprint(o.OutStream, csvHeader(o.columns(), o.format()))
+	return err
+}
+
+func (o *CsvOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *CsvOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done\n", completeness*100)
+	if err != nil {
+		return err
+	}
+	return o.writeLatencyRow(checkpoint, "checkpoint")
+}
+
+// Throughput rows have columns of their own, predating the latency ones, see throughputColumns
+func (o *CsvOutput) ReportThroughput(result Result) error {
+	format := o.format()
+	columns := o.throughputColumns()
+	s := strings.Builder{}
 	for _, script := range result.Scripts {
-		for i, col := range csvColumns {
-			if i != 0 {
-				s.WriteString(",")
+		writeCsvRow(&s, result, script, columns, format)
+	}
+
+	if err := o.writeWithHeader(csvHeader(columns, format), s.String()); err != nil {
+		return err
+	}
+
+	return o.writeErrorReport(result)
+}
+
+func (o *CsvOutput) ReportLatency(result Result) error {
+	return o.writeLatencyRow(result, "result")
+}
+
+// kind is the row_kind of the rows in a sampled run, see sampleColumns
+func (o *CsvOutput) writeLatencyRow(result Result, kind string) error {
+	columns := o.latencyColumns()
+	if o.Samples {
+		columns = append(sampleColumns(kind, nil), columns...)
+	}
+	s := strings.Builder{}
+	writeCsvRows(&s, result, columns, o.format())
+	// Other files got the header from BenchmarkStart
+	header := ""
+	if _, ok := o.OutStream.(*AppendFile); ok {
+		header = csvHeader(columns, o.format())
+	}
+	if err := o.writeWithHeader(header, s.String()); err != nil {
+		return err
+	}
+
+	return o.writeErrorReport(result)
+}
+
+// Writes rows led by header, unless headers are left out
+func (o *CsvOutput) writeWithHeader(header, rows string) error {
+	if o.OmitHeader {
+		header = ""
+	}
+	if f, ok := o.OutStream.(*AppendFile); ok {
+		return f.WriteWithHeader(header, rows)
+	}
+	_, err := fmt.Fprint(o.OutStream, header+rows)
+	return err
+}
+
+// Samples are latency rows with a row_kind of "sample", when the sample was taken and how long it covers;
+// the final results follow as rows of kind "result", and workload checkpoints as rows of kind "checkpoint",
+// without those. Samples needs to be set for the header to have the columns.
+func (o *CsvOutput) ReportInterval(sample IntervalResult) error {
+	columns := append(sampleColumns("sample", &sample), o.latencyColumns()...)
+	s := strings.Builder{}
+	writeCsvRows(&s, sample.Result, columns, o.format())
+	// Other files got the header from BenchmarkStart
+	header := ""
+	if _, ok := o.OutStream.(*AppendFile); ok {
+		header = csvHeader(columns, o.format())
+	}
+	return o.writeWithHeader(header, s.String())
+}
+
+// Leading columns of a sampled run's latency rows of the given kind; sample is nil for rows other than samples
+func sampleColumns(kind string, sample *IntervalResult) []csvColumn {
+	return []csvColumn{
+		csvNumber("row_kind", func(r Result, s *ScriptResult) string { return kind }),
+		csvNumber("timestamp", func(r Result, s *ScriptResult) string {
+			if sample == nil {
+				return ""
 			}
-			s.WriteString(col.value(result, script))
+			return csvTimestamp(sample.End)
+		}),
+		csvNumber("interval_seconds", func(r Result, s *ScriptResult) string {
+			if sample == nil {
+				return ""
+			}
+			return fmtFloat(sample.Duration.Seconds())
+		}),
+	}
+}
+
+// How rows are written; CSV quotes text, TSV escapes the characters that would break its rows instead
+type csvFormat struct {
+	separator string
+	text      func(v string) string
+}
+
+var (
+	csvCommaFormat = csvFormat{",", func(v string) string { return `"` + strings.ReplaceAll(v, `"`, `""`) + `"` }}
+	csvTabFormat   = csvFormat{"\t", strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`).Replace}
+)
+
+func (o *CsvOutput) format() csvFormat {
+	if o.Tabs {
+		return csvTabFormat
+	}
+	return csvCommaFormat
+}
+
+func csvHeader(columns []csvColumn, format csvFormat) string {
+	columnNames := make([]string, 0, len(columns))
+	for _, col := range columns {
+		columnNames = append(columnNames, col.name)
+	}
+	return strings.Join(columnNames, format.separator) + "\n"
+}
+
+// One row per script
+func writeCsvRows(s *strings.Builder, result Result, columns []csvColumn, format csvFormat) {
+	scripts := make([]*ScriptResult, 0, len(result.Scripts)+1)
+	for _, script := range result.Scripts {
+		scripts = append(scripts, script)
+	}
+	// With several scripts, add a row for the workload as a whole
+	if len(result.Scripts) > 1 {
+		scripts = append(scripts, result.Total())
+	}
+
+	for _, script := range scripts {
+		writeCsvRow(s, result, script, columns, format)
+	}
+}
+
+func writeCsvRow(s *strings.Builder, result Result, script *ScriptResult, columns []csvColumn, format csvFormat) {
+	for i, col := range columns {
+		if i != 0 {
+			s.WriteString(format.separator)
 		}
-		s.WriteString("\n")
+		value := col.value(result, script)
+		if col.text {
+			value = format.text(value)
+		}
+		s.WriteString(value)
+	}
+	s.WriteString("\n")
+}
+
+// Failures don't fit in the CSV rows, so the details go to stderr
+func (o *CsvOutput) writeErrorReport(result Result) error {
+	if result.TotalFailed() == 0 {
+		return nil
 	}
+	s := strings.Builder{}
+	writeErrorReport(result, o.reportedByCategory, &s, false)
+	_, err := fmt.Fprint(o.ErrStream, s.String())
+	return err
+}
 
-	_, err := fmt.Fprint(o.OutStream, s.String())
-	if err != nil {
-		panic(err)
+// Decimal places latencies and rates are written with, unless a Precision option says otherwise
+const DefaultPrecision = 3
+
+// Most decimal places a Precision option can ask for; nanoseconds of seconds, and far beyond the three
+// significant figures latencies are accurate to
+const MaxPrecision = 9
+
+// Fails unless precision is a number of decimal places Precision options can ask for
+func ValidatePrecision(precision int) error {
+	if precision < 0 || precision > MaxPrecision {
+		return fmt.Errorf("invalid precision: %d, precision must be between 0 and %d decimal places", precision, MaxPrecision)
 	}
+	return nil
+}
 
-	if result.TotalFailed() > 0 {
-		s.Reset()
-		writeErrorReport(result, &s)
-		if _, err := fmt.Fprint(o.ErrStream, s.String()); err != nil {
-			panic(err)
+// Decimal places a Precision option asks for, DefaultPrecision if it's not set
+func decimalPlaces(precision *int) int {
+	if precision == nil {
+		return DefaultPrecision
+	}
+	return *precision
+}
+
+func formatDecimal(v float64, places int) string {
+	return fmt.Sprintf("%.*f", places, v)
+}
+
+// Rate for people to read: like formatDecimal, with the digits before the point grouped in threes from 10,000
+// up, eg. 523481 -> "523,481.000", so high rates can be read at a glance. Only for readable output, anything
+// meant to be parsed has plain numbers
+func formatRate(v float64, places int) string {
+	s := formatDecimal(v, places)
+	if math.Abs(v) < 10000 {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, fraction := s, ""
+	if dot := strings.Index(s, "."); dot != -1 {
+		whole, fraction = s[:dot], s[dot:]
+	}
+	grouped := strings.Builder{}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteRune(',')
 		}
+		grouped.WriteRune(digit)
 	}
+	return sign + grouped.String() + fraction
 }
 
 func fmtFloat(v interface{}) string {
@@ -396,39 +2558,355 @@ func fmtFloat(v interface{}) string {
 	return fmt.Sprintf("%v?", v)
 }
 
-var csvColumns = []struct {
+type csvColumn struct {
 	name  string
 	value func(r Result, s *ScriptResult) string
-}{
-	{"db", func(r Result, s *ScriptResult) string { return fmt.Sprintf("\"%s\"", r.DatabaseName) }},
-	{"script", func(r Result, s *ScriptResult) string { return fmt.Sprintf("\"%s\"", s.ScriptName) }},
-	{"rate", func(r Result, s *ScriptResult) string { return fmtFloat(s.Rate) }},
-	{"succeeded", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.TotalCount()) }},
-	{"failed", func(r Result, s *ScriptResult) string { return fmtFloat(s.Failed) }},
-	{"mean", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.Mean() / 1000.0) }},
-	{"stdev", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.StdDev()) }},
-	{"p0", func(r Result, s *ScriptResult) string { return fmtFloat(float64(s.Latencies.Min()) / 1000.0) }},
-	{"p25", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(25)) / 1000.0)
-	}},
-	{"p50", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(50)) / 1000.0)
-	}},
-	{"p75", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(75)) / 1000.0)
-	}},
-	{"p99", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(99)) / 1000.0)
-	}},
-	{"p99999", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(99.999)) / 1000.0)
-	}},
-	{"p100", func(r Result, s *ScriptResult) string { return fmtFloat(float64(s.Latencies.Max()) / 1000.0) }},
+	// Whether the value is text, which is written as csvFormat says, rather than a number
+	text bool
+}
+
+func csvNumber(name string, value func(r Result, s *ScriptResult) string) csvColumn {
+	return csvColumn{name: name, value: value}
+}
+
+// Latency in the given unit, computed from microseconds; empty for scripts without latencies, rather than
+// zeros that look like a measurement. The unit is appended to the name, like mean_ms, so the header says it.
+func csvLatency(name string, unit LatencyUnit, places int, micros func(h *hdrhistogram.Histogram) float64) csvColumn {
+	return csvNumber(name+"_"+unit.Name, func(r Result, s *ScriptResult) string {
+		if s.Latencies.TotalCount() == 0 {
+			return ""
+		}
+		return formatDecimal(micros(s.Latencies)/unit.Micros, places)
+	})
+}
+
+func csvText(name string, value func(r Result, s *ScriptResult) string) csvColumn {
+	return csvColumn{name: name, value: value, text: true}
+}
+
+// Rates are written with the given decimal places. Every name carries its unit, see the CSV output section of
+// the README; renaming a column breaks whatever loads the files, so new ones go at the end instead.
+func csvColumns(places int) []csvColumn {
+	return []csvColumn{
+		csvNumber("clients", func(r Result, s *ScriptResult) string { return fmt.Sprintf("%d", r.Config.Clients) }),
+		csvNumber("target_transactions_per_second", func(r Result, s *ScriptResult) string { return formatDecimal(r.Config.TargetRate, places) }),
+		csvNumber("duration_seconds", func(r Result, s *ScriptResult) string { return fmtFloat(r.Config.Duration.Seconds()) }),
+		csvText("db", func(r Result, s *ScriptResult) string { return r.DatabaseName }),
+		csvText("script", func(r Result, s *ScriptResult) string { return s.ScriptName }),
+		csvNumber("transactions_per_second", func(r Result, s *ScriptResult) string { return formatDecimal(s.Rate, places) }),
+		csvNumber("succeeded", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.TotalCount()) }),
+		csvNumber("failed", func(r Result, s *ScriptResult) string { return fmtFloat(s.Failed) }),
+		// Percentage of attempts that failed
+		csvNumber("error_rate_percent", func(r Result, s *ScriptResult) string { return fmtFloat(s.ErrorRate()) }),
+	}
+}
+
+// What produced the results; after the original columns, so those keep their position
+var csvVersionColumns = []csvColumn{
+	csvText("neobench_version", func(r Result, s *ScriptResult) string { return r.NeobenchVersion }),
+	csvText("neo4j_version", func(r Result, s *ScriptResult) string { return r.Neo4jVersion }),
+}
+
+// When measurement started and ended, empty if not known
+var csvWindowColumns = []csvColumn{
+	csvNumber("start_time", func(r Result, s *ScriptResult) string { return csvTimestamp(r.StartTime) }),
+	csvNumber("end_time", func(r Result, s *ScriptResult) string { return csvTimestamp(r.EndTime) }),
+}
+
+// Attempts the driver retried, see ScriptResult.Retries; after the percentile targets, so it doesn't move them
+var csvRetriesColumn = csvNumber("retries", func(r Result, s *ScriptResult) string { return fmt.Sprintf("%d", s.Retries) })
+
+// Transactions that failed, the count error_rate_percent is of; failed came first, so this one goes at the end
+var csvErrorsColumn = csvNumber("errors", func(r Result, s *ScriptResult) string { return fmt.Sprintf("%d", s.Failed) })
+
+func csvTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return formatTimestamp(t)
+}
+
+// All columns in latency rows; the fixed csvColumns followed by one column per percentile, csvVersionColumns,
+// the confidence in the mean, the precision of the histogram, csvWindowColumns, one column per percentile target,
+// retries, with a Baseline the change in rate, mean and each percentile from it and then the number of
+// transactions at or above each percentile, see countAtOrAbove
+func (o *CsvOutput) columns() []csvColumn {
+	if o.Samples {
+		return append(sampleColumns("result", nil), o.latencyColumns()...)
+	}
+	return o.latencyColumns()
+}
+
+func (o *CsvOutput) latencyColumns() []csvColumn {
+	percentiles := o.Percentiles
+	if len(percentiles) == 0 {
+		percentiles = DefaultCsvPercentiles
+	}
+	unit, places := o.latencyUnit(), decimalPlaces(o.Precision)
+	columns := csvColumns(places)
+	columns = append(columns,
+		csvLatency("mean", unit, places, func(h *hdrhistogram.Histogram) float64 { return h.Mean() }),
+		csvLatency("stdev", unit, places, func(h *hdrhistogram.Histogram) float64 { return h.StdDev() }))
+	for _, q := range percentiles {
+		q := q
+		columns = append(columns, csvLatency(percentileColumnName(q), unit, places, func(h *hdrhistogram.Histogram) float64 {
+			return float64(valueAtPercentile(h, q))
+		}))
+	}
+	columns = append(columns, csvVersionColumns...)
+	columns = append(columns,
+		csvLatency("mean_stderr", unit, places, standardError),
+		csvLatency("mean_ci95_low", unit, places, func(h *hdrhistogram.Histogram) float64 {
+			return h.Mean() - ci95*standardError(h)
+		}),
+		csvLatency("mean_ci95_high", unit, places, func(h *hdrhistogram.Histogram) float64 {
+			return h.Mean() + ci95*standardError(h)
+		}),
+		// Latencies are only accurate to this many significant figures, and only up to the max
+		csvNumber("significant_figures", func(r Result, s *ScriptResult) string {
+			return fmt.Sprintf("%d", s.Latencies.SignificantFigures())
+		}),
+		csvNumber("max_trackable_"+unit.Name, func(r Result, s *ScriptResult) string {
+			return formatDecimal(float64(s.Latencies.HighestTrackableValue())/unit.Micros, places)
+		}))
+	columns = append(columns, csvWindowColumns...)
+	for _, q := range o.Targets.percentiles() {
+		q := q
+		columns = append(columns, csvNumber(percentileColumnName(q)+"_target_met", func(r Result, s *ScriptResult) string {
+			return strconv.FormatBool(o.Targets.met(q, valueAtPercentile(s.Latencies, q)))
+		}))
+	}
+	columns = append(columns, csvRetriesColumn)
+	if o.Baseline != nil {
+		columns = append(columns, o.rateDeltaColumns(places)...)
+		columns = append(columns, o.latencyDeltaColumns("mean_"+unit.Name, unit, places, func(h *hdrhistogram.Histogram) float64 {
+			return h.Mean()
+		}, func(b BaselineScript) (float64, bool) { return b.Mean, b.Latencies })...)
+		for _, q := range percentiles {
+			q := q
+			columns = append(columns, o.latencyDeltaColumns(percentileColumnName(q)+"_"+unit.Name, unit, places, func(h *hdrhistogram.Histogram) float64 {
+				return float64(valueAtPercentile(h, q))
+			}, func(b BaselineScript) (float64, bool) { return b.percentile(q) })...)
+		}
+	}
+	for _, q := range percentiles {
+		columns = append(columns, csvTransactionsAtOrAbove(percentileColumnName(q), q))
+	}
+	columns = append(columns, csvErrorsColumn)
+	return o.withSlugColumn(columns)
+}
+
+// Transactions at or above the latency at percentile q, see countAtOrAbove
+func csvTransactionsAtOrAbove(name string, q float64) csvColumn {
+	return csvNumber(name+"_transactions", func(r Result, s *ScriptResult) string {
+		return fmt.Sprintf("%d", countAtOrAbove(s.Latencies, valueAtPercentile(s.Latencies, q)))
+	})
+}
+
+func (o *CsvOutput) rateDeltaColumns(places int) []csvColumn {
+	return csvDeltaColumns("transactions_per_second", o.Baseline, places, 1, func(s *ScriptResult, base BaselineScript) (float64, float64, bool) {
+		return s.Rate, base.Rate, true
+	})
+}
+
+// Left empty for scripts without latencies in either run
+func (o *CsvOutput) latencyDeltaColumns(name string, unit LatencyUnit, places int, micros func(h *hdrhistogram.Histogram) float64,
+	baseMicros func(b BaselineScript) (float64, bool)) []csvColumn {
+	return csvDeltaColumns(name, o.Baseline, places, unit.Micros, func(s *ScriptResult, base BaselineScript) (float64, float64, bool) {
+		baseValue, ok := baseMicros(base)
+		if !ok || s.Latencies.TotalCount() == 0 {
+			return 0, 0, false
+		}
+		return micros(s.Latencies), baseValue, true
+	})
+}
+
+func (o *CsvOutput) throughputColumns() []csvColumn {
+	unit, places := o.latencyUnit(), decimalPlaces(o.Precision)
+	fixed := csvColumns(places)
+	columns := []csvColumn{
+		// Clients, target rate and duration
+		fixed[0], fixed[1], fixed[2],
+		csvText("script", func(r Result, s *ScriptResult) string { return s.ScriptName }),
+		csvNumber("succeeded", func(r Result, s *ScriptResult) string { return fmtFloat(s.Succeeded) }),
+		csvNumber("failed", func(r Result, s *ScriptResult) string { return fmtFloat(s.Failed) }),
+		fixed[8],
+		csvNumber("transactions_per_second", func(r Result, s *ScriptResult) string { return formatDecimal(s.Rate, places) }),
+		csvLatency("mean_latency", unit, places, func(h *hdrhistogram.Histogram) float64 { return h.Mean() }),
+		csvLatency("p99_latency", unit, places, func(h *hdrhistogram.Histogram) float64 {
+			return float64(valueAtPercentile(h, 99))
+		}),
+	}
+	columns = append(columns, csvVersionColumns...)
+	columns = append(columns,
+		csvNumber("queries_per_second", func(r Result, s *ScriptResult) string { return formatDecimal(s.QueryRate, places) }),
+		csvNumber("records_per_second", func(r Result, s *ScriptResult) string { return formatDecimal(s.RecordRate, places) }),
+		csvNumber("bytes_per_second", func(r Result, s *ScriptResult) string { return formatDecimal(s.ByteRate, places) }),
+		csvWindowColumns[0], csvWindowColumns[1], csvRetriesColumn)
+	if o.Baseline != nil {
+		columns = append(columns, o.rateDeltaColumns(places)...)
+		columns = append(columns, o.latencyDeltaColumns("mean_latency_"+unit.Name, unit, places, func(h *hdrhistogram.Histogram) float64 {
+			return h.Mean()
+		}, func(b BaselineScript) (float64, bool) { return b.Mean, b.Latencies })...)
+		columns = append(columns, o.latencyDeltaColumns("p99_latency_"+unit.Name, unit, places, func(h *hdrhistogram.Histogram) float64 {
+			return float64(valueAtPercentile(h, 99))
+		}, func(b BaselineScript) (float64, bool) { return b.percentile(99) })...)
+	}
+	columns = append(columns, csvTransactionsAtOrAbove("p99_latency", 99), csvErrorsColumn)
+	return o.withSlugColumn(columns)
+}
+
+// The warmup column, the run id, a tag_<key> column per tag, and then the slug last, so they don't move any
+// other column
+func (o *CsvOutput) withSlugColumn(columns []csvColumn) []csvColumn {
+	if o.Warmup {
+		columns = append(columns, csvNumber("warmup", func(r Result, s *ScriptResult) string { return strconv.FormatBool(r.Warmup) }))
+	}
+	if o.RunId != "" {
+		columns = append(columns, csvText("run_id", func(r Result, s *ScriptResult) string { return r.RunId }))
+	}
+	for _, key := range sortedTagKeys(o.Tags) {
+		key := key
+		columns = append(columns, csvText("tag_"+key, func(r Result, s *ScriptResult) string { return r.Tags[key] }))
+	}
+	if !o.ScenarioSlug {
+		return columns
+	}
+	return append(columns, csvText("scenario_slug", func(r Result, s *ScriptResult) string { return r.ScenarioSlug() }))
+}
+
+func (o *CsvOutput) latencyUnit() LatencyUnit {
+	if o.LatencyUnit == LatencyAuto {
+		return LatencyMilliseconds
+	}
+	return resolveLatencyUnit(o.LatencyUnit, Result{})
 }
 
 func (o *CsvOutput) Errorf(format string, a ...interface{}) {
-	_, err := fmt.Fprintf(o.ErrStream, "ERROR: %s\n", fmt.Sprintf(format, a...))
-	if err != nil {
-		panic(err)
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *CsvOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *CsvOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+// One row per script, with the run's config repeated in each like in result rows
+func (o *CsvOutput) ReportPlan(plan Plan) error {
+	format := o.format()
+	header := strings.Join([]string{"clients", "target_transactions_per_second", "duration_seconds", "transactions", "db", "script", "weight", "share", "readonly",
+		"init"}, format.separator) + "\n"
+	s := strings.Builder{}
+	for _, script := range plan.Scripts {
+		s.WriteString(strings.Join([]string{
+			fmt.Sprintf("%d", plan.Config.Clients),
+			fmtFloat(plan.Config.TargetRate),
+			fmtFloat(plan.Config.Duration.Seconds()),
+			strconv.FormatUint(plan.Config.Transactions, 10),
+			format.text(plan.DatabaseName),
+			format.text(script.Name),
+			fmtFloat(script.Weight),
+			fmtFloat(plan.share(script)),
+			strconv.FormatBool(script.Readonly),
+			strconv.FormatBool(plan.Init),
+		}, format.separator) + "\n")
+	}
+	return o.writeWithHeader(header, s.String())
+}
+
+func (o *CsvOutput) Close() error {
+	return nil
+}
+
+func (o *CsvOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+// True if a progress report should be written; a new section or step always is, otherwise
+// we report at most once per interval
+func progressIsDue(report, last ProgressReport, lastTime, now time.Time, interval time.Duration) bool {
+	if report.Section != last.Section || report.Step != last.Step {
+		return true
+	}
+	return now.Sub(lastTime) >= interval
+}
+
+// Tracks when the current progress step started, to tell how long it's been running and how long it has left
+type progressTimer struct {
+	stepStart             time.Time
+	stepStartCompleteness float64
+	// Leave timings out of progress, see OutputOptions.Deterministic
+	hidden bool
+}
+
+// Call for each progress report that gets written; newStep restarts the timer
+func (t *progressTimer) update(report ProgressReport, newStep bool, now time.Time) {
+	if newStep || t.stepStart.IsZero() {
+		t.stepStart = now
+		t.stepStartCompleteness = report.Completeness
+	}
+}
+
+func (t *progressTimer) elapsed(now time.Time) time.Duration {
+	return now.Sub(t.stepStart)
+}
+
+// Estimated time left in the step, extrapolating from progress since the step started. The estimate
+// is only known once there's been some actual progress, and not before the step started or after it's done
+func (t *progressTimer) eta(report ProgressReport, now time.Time) (time.Duration, bool) {
+	progressed := report.Completeness - t.stepStartCompleteness
+	elapsed := t.elapsed(now)
+	if report.Completeness <= 0 || report.Completeness >= 1 || progressed <= 0 || elapsed <= 0 {
+		return 0, false
+	}
+	perUnit := float64(elapsed) / progressed
+	return time.Duration(perUnit * (1 - report.Completeness)), true
+}
+
+// eg. " (elapsed 1m3s, eta ~1m25s)"; empty until the step has run for a second, to keep fast steps terse
+func (t *progressTimer) describe(report ProgressReport, now time.Time) string {
+	if t.hidden {
+		return ""
 	}
+	elapsed := t.elapsed(now).Round(time.Second)
+	if elapsed <= 0 {
+		return ""
+	}
+	eta, ok := t.eta(report, now)
+	if !ok {
+		return fmt.Sprintf(" (elapsed %s)", elapsed)
+	}
+	return fmt.Sprintf(" (elapsed %s, eta ~%s)", elapsed, eta.Round(time.Second))
+}
+
+// Banner, progress and errors for humans; shared by the output formats that write these to stderr
+
+func writeBenchmarkStart(w io.Writer, databaseName, url, scenario string) error {
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	_, err := fmt.Fprintf(w,
+		"Starting workload on database %s against %s\n"+
+			"Scenario: %s\n", databaseName, url, scenario)
+	return err
+}
+
+// Best-effort, see Output; timing is a progressTimer description
+func writeProgress(w io.Writer, report ProgressReport, timing string) {
+	_, _ = fmt.Fprintf(w, "[%s][%s] %.02f%%%s\n", report.Section, report.Step, report.Completeness*100, timing)
+}
+
+// Best-effort, see Output
+func writeError(w io.Writer, format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(w, "ERROR: %s\n", fmt.Sprintf(format, a...))
+}
+
+// Best-effort, see Output
+func writeWarning(w io.Writer, format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(w, "WARN: %s\n", fmt.Sprintf(format, a...))
 }
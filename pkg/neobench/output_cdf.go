@@ -0,0 +1,103 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"io"
+	"strings"
+)
+
+// Writes the cumulative latency distribution of all scripts combined to stdout as two-column CSV, value_ms and
+// cumulative_fraction, for plotting. Unlike HgrmOutput the rows are evenly spaced by latency. Progress goes to stderr.
+type CdfOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Number of rows to write, evenly spaced between the lowest and highest latency; defaults to DefaultCdfPoints
+	Points int
+	ProgressReporter
+	errorTracker
+}
+
+const DefaultCdfPoints = 100
+
+func (o *CdfOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *CdfOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *CdfOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+// The distribution is for the run as a whole, so samples are left out
+func (o *CdfOutput) ReportInterval(sample IntervalResult) error {
+	return nil
+}
+
+// Latencies are recorded in throughput mode as well, so this writes the same distribution ReportLatency does
+func (o *CdfOutput) ReportThroughput(result Result) error {
+	return o.ReportLatency(result)
+}
+
+func (o *CdfOutput) ReportLatency(result Result) error {
+	points := o.Points
+	if points <= 0 {
+		points = DefaultCdfPoints
+	}
+	_, err := fmt.Fprint(o.OutStream, formatCdf(result.Total().Latencies, points, 1000.0))
+	return err
+}
+
+func (o *CdfOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *CdfOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *CdfOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *CdfOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *CdfOutput) Close() error {
+	return nil
+}
+
+func (o *CdfOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+// Fraction of values in h at or below each of points evenly spaced values from its min to its max, with values
+// divided by scale
+func formatCdf(h *hdrhistogram.Histogram, points int, scale float64) string {
+	s := strings.Builder{}
+	s.WriteString("value_ms,cumulative_fraction\n")
+	total := h.TotalCount()
+	if total == 0 {
+		return s.String()
+	}
+
+	bars := h.Distribution()
+	min, max := float64(h.Min()), float64(h.Max())
+	bar, count := 0, int64(0)
+	for i := 1; i <= points; i++ {
+		value := min + (max-min)*float64(i)/float64(points)
+		for bar < len(bars) && float64(bars[bar].To) <= value {
+			count += bars[bar].Count
+			bar++
+		}
+		s.WriteString(fmt.Sprintf("%.3f,%.6f\n", value/scale, float64(count)/float64(total)))
+	}
+	return s.String()
+}
@@ -0,0 +1,189 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keeps every result until Close, then writes them to stdout as one aligned table with a row per result, for
+// comparing scenarios side by side. Progress and error details go to stderr as results come in.
+type CompareOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Latency percentiles to report, defaults to DefaultComparePercentiles
+	Percentiles []float64
+	// Unit to show latencies in, defaults to milliseconds; with LatencyAuto, the first result picks the unit
+	// for the whole table
+	LatencyUnit LatencyUnit
+	// Decimal places in latencies and rates, defaults to DefaultPrecision
+	Precision *int
+	// Metric to order rows by, see CompareSortMetrics; rows stay in the order results came in if empty
+	SortBy string
+	ProgressReporter
+	results []Result
+	errorTracker
+}
+
+var DefaultComparePercentiles = []float64{50, 95, 99, 99.9}
+
+// What CompareOutput.SortBy can be besides a percentile, written like p99 or p99.9; rate sorts the fastest
+// first, latencies sort the lowest first
+var CompareSortMetrics = []string{"scenario", "rate", "mean"}
+
+// Fails unless CompareOutput can sort by metric
+func ValidateCompareSort(metric string) error {
+	if metric == "" || containsString(CompareSortMetrics, metric) {
+		return nil
+	}
+	if _, err := parseSortPercentile(metric); err == nil {
+		return nil
+	}
+	return fmt.Errorf("unknown metric to sort by: %s, supported metrics are %s and percentiles like p99 or p99.9",
+		metric, strings.Join(CompareSortMetrics, ", "))
+}
+
+func (o *CompareOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *CompareOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *CompareOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+// Samples aren't scenarios of their own, so they'd only crowd the table
+func (o *CompareOutput) ReportInterval(sample IntervalResult) error {
+	return nil
+}
+
+// Latencies are recorded in throughput mode as well, so these rows are the same as ReportLatency's
+func (o *CompareOutput) ReportThroughput(result Result) error {
+	return o.ReportLatency(result)
+}
+
+func (o *CompareOutput) ReportLatency(result Result) error {
+	o.results = append(o.results, result)
+	if result.TotalFailed() == 0 {
+		return nil
+	}
+	s := strings.Builder{}
+	writeErrorReport(result, o.reportedByCategory, &s, false)
+	_, err := fmt.Fprint(o.ErrStream, s.String())
+	return err
+}
+
+func (o *CompareOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *CompareOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *CompareOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *CompareOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *CompareOutput) Close() error {
+	if len(o.results) == 0 {
+		return nil
+	}
+	unit := resolveLatencyUnit(o.LatencyUnit, o.results[0])
+	header := []string{"Scenario", "Database", "Rate (tps)", fmt.Sprintf("Mean (%s)", unit.Name)}
+	for _, q := range o.percentiles() {
+		header = append(header, fmt.Sprintf("P%s (%s)", formatPercentile(q), unit.Name))
+	}
+
+	results := append([]Result{}, o.results...)
+	sort.SliceStable(results, func(i, j int) bool { return o.less(results[i], results[j]) })
+	places := decimalPlaces(o.Precision)
+	rows := [][]string{header}
+	for _, result := range results {
+		histo := result.Total().Latencies
+		databaseName := result.DatabaseName
+		if databaseName == "" {
+			databaseName = "<default>"
+		}
+		row := []string{result.Scenario, databaseName, formatDecimal(result.TotalRate(), places),
+			formatDecimal(histo.Mean()/unit.Micros, places)}
+		for _, q := range o.percentiles() {
+			row = append(row, formatDecimal(float64(valueAtPercentile(histo, q))/unit.Micros, places))
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	s := strings.Builder{}
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			// Text columns left-aligned, numbers right-aligned
+			if i < 2 {
+				cells[i] = fmt.Sprintf("%-*s", widths[i], cell)
+			} else {
+				cells[i] = fmt.Sprintf("%*s", widths[i], cell)
+			}
+		}
+		s.WriteString(strings.TrimRight(strings.Join(cells, "  "), " ") + "\n")
+	}
+	_, err := fmt.Fprint(o.OutStream, s.String())
+	return err
+}
+
+func (o *CompareOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+func (o *CompareOutput) less(a, b Result) bool {
+	switch o.SortBy {
+	case "":
+		return false
+	case "scenario":
+		return a.Scenario < b.Scenario
+	case "rate":
+		return a.TotalRate() > b.TotalRate()
+	case "mean":
+		return a.Total().Latencies.Mean() < b.Total().Latencies.Mean()
+	}
+	// ValidateCompareSort has made sure it's a percentile
+	q, _ := parseSortPercentile(o.SortBy)
+	return valueAtPercentile(a.Total().Latencies, q) < valueAtPercentile(b.Total().Latencies, q)
+}
+
+func parseSortPercentile(metric string) (float64, error) {
+	if !strings.HasPrefix(metric, "p") {
+		return 0, fmt.Errorf("not a percentile: %s", metric)
+	}
+	q, err := strconv.ParseFloat(metric[1:], 64)
+	if err != nil || q < 0 || q > 100 {
+		return 0, fmt.Errorf("not a percentile: %s", metric)
+	}
+	return q, nil
+}
+
+func (o *CompareOutput) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultComparePercentiles
+	}
+	return o.Percentiles
+}
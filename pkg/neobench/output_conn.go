@@ -0,0 +1,40 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// How long NewOutput waits to connect to a destination before giving up
+const DestinationDialTimeout = 10 * time.Second
+
+// Connects to where results are to be sent, given as tcp://host:port or unix:///path/to/socket
+func dialDestination(destination string) (net.Conn, error) {
+	for _, network := range []string{"tcp", "unix"} {
+		if address := strings.TrimPrefix(destination, network+"://"); address != destination {
+			return net.DialTimeout(network, address, DestinationDialTimeout)
+		}
+	}
+	return nil, fmt.Errorf("unknown destination: %s, destinations are tcp://host:port or unix:///path", destination)
+}
+
+// Closes Closer once the wrapped Output is closed, eg. the connection results were streamed over
+type ClosingOutput struct {
+	Output
+	Closer io.Closer
+}
+
+func (o *ClosingOutput) Close() error {
+	err := o.Output.Close()
+	if closeErr := o.Closer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (o *ClosingOutput) Reset() {
+	ResetOutput(o.Output)
+}
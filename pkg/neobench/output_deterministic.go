@@ -0,0 +1,46 @@
+package neobench
+
+import "time"
+
+// Leaves out of the wrapped Output's results everything that changes from one run of the same results to the
+// next: when they were measured, how long that took and the stats of the neobench process, so formatted
+// output can be compared to golden files. Results are copied before they're changed, so what the caller
+// holds keeps its data; see OutputOptions.Deterministic for the rest of what the mode changes.
+type DeterministicOutput struct {
+	Output
+}
+
+func (o *DeterministicOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	return o.Output.ReportWorkloadProgress(completeness, withoutTimings(checkpoint))
+}
+
+func (o *DeterministicOutput) ReportInterval(sample IntervalResult) error {
+	return o.Output.ReportInterval(IntervalResult{Result: withoutTimings(sample.Result)})
+}
+
+func (o *DeterministicOutput) ReportThroughput(result Result) error {
+	return o.Output.ReportThroughput(withoutTimings(result))
+}
+
+func (o *DeterministicOutput) ReportLatency(result Result) error {
+	return o.Output.ReportLatency(withoutTimings(result))
+}
+
+func (o *DeterministicOutput) Reset() {
+	ResetOutput(o.Output)
+}
+
+// Outputs treat zero times and durations as not known, and leave them out
+func withoutTimings(result Result) Result {
+	result.StartTime, result.EndTime, result.Duration = time.Time{}, time.Time{}, 0
+	result.Process, result.Concurrency, result.PoolWait, result.ClientCpu = nil, nil, nil, nil
+	if len(result.Slowest) > 0 {
+		slowest := make([]SlowTransaction, len(result.Slowest))
+		for i, slow := range result.Slowest {
+			slow.Start = time.Time{}
+			slowest[i] = slow
+		}
+		result.Slowest = slowest
+	}
+	return result
+}
@@ -0,0 +1,54 @@
+package neobench
+
+import "sort"
+
+// Combines progress reported by several workers on the same step into one report for the wrapped Output, so it
+// shows how far along the step is as a whole rather than whichever worker reported last. Reports without a
+// Worker are passed on as they are.
+type FanInProgressOutput struct {
+	Output
+	// Step the workers are on, and the latest report of each, by worker
+	section, step string
+	workers       map[string]ProgressReport
+}
+
+func (o *FanInProgressOutput) ReportProgress(report ProgressReport) {
+	if report.Worker == "" {
+		o.Output.ReportProgress(report)
+		return
+	}
+	if o.workers == nil || report.Section != o.section || report.Step != o.step {
+		o.section, o.step = report.Section, report.Step
+		o.workers = make(map[string]ProgressReport)
+	}
+	o.workers[report.Worker] = report
+	o.Output.ReportProgress(combineProgress(o.workers))
+}
+
+func (o *FanInProgressOutput) Reset() {
+	o.section, o.step, o.workers = "", "", nil
+	ResetOutput(o.Output)
+}
+
+// Completeness of the step as a whole, weighing each worker by its share of the work. Workers that haven't
+// reported yet count as not started if shares are given, and aren't known about otherwise.
+func combineProgress(workers map[string]ProgressReport) ProgressReport {
+	combined := ProgressReport{Workers: make([]ProgressReport, 0, len(workers))}
+	var done, total float64
+	for _, report := range workers {
+		share := report.Share
+		if share <= 0 {
+			share = 1 / float64(len(workers))
+		}
+		done += share * report.Completeness
+		total += share
+		combined.Section, combined.Step = report.Section, report.Step
+		combined.Workers = append(combined.Workers, report)
+	}
+	if total < 1 {
+		total = 1
+	}
+	combined.Completeness = done / total
+	sort.Slice(combined.Workers, func(i, j int) bool { return combined.Workers[i].Worker < combined.Workers[j].Worker })
+	return combined
+}
@@ -0,0 +1,63 @@
+package neobench
+
+import (
+	"io"
+	"os"
+)
+
+// Flushes Stream after each result the wrapped Output reports, if it buffers, so results aren't lost when the
+// process exits without flushing it, eg. when a container is stopped. Files are synced to disk; terminals and
+// pipes don't buffer, so they're left alone.
+type FlushingOutput struct {
+	Output
+	Stream io.Writer
+}
+
+func (o *FlushingOutput) ReportInterval(sample IntervalResult) error {
+	if err := o.Output.ReportInterval(sample); err != nil {
+		return err
+	}
+	return flushStream(o.Stream)
+}
+
+func (o *FlushingOutput) ReportThroughput(result Result) error {
+	if err := o.Output.ReportThroughput(result); err != nil {
+		return err
+	}
+	return flushStream(o.Stream)
+}
+
+func (o *FlushingOutput) ReportLatency(result Result) error {
+	if err := o.Output.ReportLatency(result); err != nil {
+		return err
+	}
+	return flushStream(o.Stream)
+}
+
+func (o *FlushingOutput) Close() error {
+	if err := o.Output.Close(); err != nil {
+		return err
+	}
+	return flushStream(o.Stream)
+}
+
+func (o *FlushingOutput) Reset() {
+	ResetOutput(o.Output)
+}
+
+func flushStream(w io.Writer) error {
+	switch w := w.(type) {
+	case interface{ Flush() error }:
+		return w.Flush()
+	case *os.File:
+		// Syncing anything other than a regular file fails, and there's nothing to sync anyway
+		fi, err := w.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return nil
+		}
+		return w.Sync()
+	case interface{ Sync() error }:
+		return w.Sync()
+	}
+	return nil
+}
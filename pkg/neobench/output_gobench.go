@@ -0,0 +1,116 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Writes results to stdout as `go test -bench` result lines, so runs can be compared with benchstat:
+//
+//	BenchmarkNeobench/<script>-<clients>  <succeeded>  <mean> ns/op  <rate> tps  <p50> p50-ns/op  <p99> p99-ns/op  <failed> failed
+//
+// Run the same benchmark several times into one file, eg. with --append, to give benchstat several samples.
+// With several scripts there's a line for each, and one for all of them named "total". A run with an id has a
+// run_id configuration line before its lines. Progress and error details go to stderr.
+type GobenchOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	ProgressReporter
+	errorTracker
+}
+
+func (o *GobenchOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *GobenchOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *GobenchOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+// Samples aren't comparable with the final results, and benchstat would see them as more runs
+func (o *GobenchOutput) ReportInterval(sample IntervalResult) error {
+	return nil
+}
+
+func (o *GobenchOutput) ReportThroughput(result Result) error {
+	return o.writeLine(result)
+}
+
+func (o *GobenchOutput) ReportLatency(result Result) error {
+	return o.writeLine(result)
+}
+
+func (o *GobenchOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *GobenchOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *GobenchOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *GobenchOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *GobenchOutput) Close() error {
+	return nil
+}
+
+func (o *GobenchOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+func (o *GobenchOutput) writeLine(result Result) error {
+	scripts := sortedScripts(result)
+	// With several scripts, add a line for the workload as a whole
+	if len(scripts) > 1 {
+		total := result.Total()
+		total.ScriptName = "total"
+		scripts = append(scripts, total)
+	}
+	s := strings.Builder{}
+	// Configuration lines, which benchstat keeps with the benchmark lines that follow them
+	if result.RunId != "" {
+		s.WriteString(fmt.Sprintf("run_id: %s\n", result.RunId))
+	}
+	for _, script := range scripts {
+		histo := script.Latencies
+		s.WriteString(fmt.Sprintf("%s\t%d\t%.0f ns/op\t%.3f tps\t%d p50-ns/op\t%d p99-ns/op\t%d failed\n",
+			gobenchName(script.ScriptName, result.Config.Clients), script.Succeeded, histo.Mean()*1000, script.Rate,
+			valueAtPercentile(histo, 50)*1000, valueAtPercentile(histo, 99)*1000, script.Failed))
+	}
+	if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
+		return err
+	}
+	if result.TotalFailed() == 0 {
+		return nil
+	}
+	errReport := strings.Builder{}
+	writeErrorReport(result, o.reportedByCategory, &errReport, false)
+	_, err := fmt.Fprint(o.ErrStream, errReport.String())
+	return err
+}
+
+// Benchmark names end at the first space. The -N suffix go test adds is the number of clients here, left out if
+// that's not known, eg. for merged histograms; benchstat only strips it if it's there
+var gobenchNameReplacer = strings.NewReplacer(" ", "_", "\t", "_", "\n", "_")
+
+func gobenchName(scriptName string, clients int) string {
+	name := "BenchmarkNeobench/" + gobenchNameReplacer.Replace(scriptName)
+	if clients > 0 {
+		name += fmt.Sprintf("-%d", clients)
+	}
+	return name
+}
@@ -0,0 +1,135 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Writes the latency distribution of each sample interval to stdout as CSV, one row per interval and one
+// column per percentile, for plotting as a heatmap of how latencies shift over the run, which the final
+// results average away, see CheckRateStability. Rows are for all scripts combined, and intervals without
+// any transactions have empty latency columns. Final results aren't written, they're the same as csv output's. Progress and error details go to stderr.
+type HeatmapOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Latency percentiles to report, defaults to DefaultHeatmapPercentiles
+	Percentiles []float64
+	// Unit latency columns are in, defaults to milliseconds; the header is written with the first row, before
+	// the run is over, so LatencyAuto means milliseconds here
+	LatencyUnit LatencyUnit
+	// Decimal places in latency columns, defaults to DefaultPrecision
+	Precision *int
+	ProgressReporter
+	// Start of the first interval of the first run, which rows give their time relative to; it outlasts Reset
+	// like the header does, so the rows of later runs carry on from those of earlier ones
+	firstStart time.Time
+	// The header is written with the first row, of the first run
+	headerWritten bool
+	errorTracker
+}
+
+// Evenly spread over the body of the distribution, with the tail where spikes show up first
+var DefaultHeatmapPercentiles = []float64{0, 10, 25, 50, 75, 90, 95, 99, 99.9, 100}
+
+func (o *HeatmapOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *HeatmapOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *HeatmapOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+func (o *HeatmapOutput) ReportInterval(sample IntervalResult) error {
+	columns := o.columns(sample)
+	s := strings.Builder{}
+	if o.firstStart.IsZero() {
+		o.firstStart = sample.Start
+	}
+	if !o.headerWritten {
+		o.headerWritten = true
+		s.WriteString(csvHeader(columns, csvCommaFormat))
+	}
+	writeCsvRow(&s, sample.Result, sample.Total(), columns, csvCommaFormat)
+	_, err := fmt.Fprint(o.OutStream, s.String())
+	return err
+}
+
+// The rows are the intervals the run was sampled in, so only failures from the final results are written
+func (o *HeatmapOutput) ReportThroughput(result Result) error {
+	return o.ReportLatency(result)
+}
+
+func (o *HeatmapOutput) ReportLatency(result Result) error {
+	if result.TotalFailed() == 0 {
+		return nil
+	}
+	s := strings.Builder{}
+	writeErrorReport(result, o.reportedByCategory, &s, false)
+	_, err := fmt.Fprint(o.ErrStream, s.String())
+	return err
+}
+
+func (o *HeatmapOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *HeatmapOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *HeatmapOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *HeatmapOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *HeatmapOutput) Close() error {
+	return nil
+}
+
+func (o *HeatmapOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+// When the interval ended, seconds from the start of the first interval to its end, transactions in it and
+// then one column per percentile, named with its unit like p99_ms
+func (o *HeatmapOutput) columns(sample IntervalResult) []csvColumn {
+	unit := LatencyMilliseconds
+	if o.LatencyUnit != LatencyAuto {
+		unit = resolveLatencyUnit(o.LatencyUnit, Result{})
+	}
+	places := decimalPlaces(o.Precision)
+	columns := []csvColumn{
+		csvNumber("time", func(r Result, s *ScriptResult) string { return csvTimestamp(sample.End) }),
+		csvNumber("elapsed_seconds", func(r Result, s *ScriptResult) string { return fmtFloat(sample.End.Sub(o.firstStart).Seconds()) }),
+		csvNumber("transactions", func(r Result, s *ScriptResult) string { return fmt.Sprintf("%d", s.Latencies.TotalCount()) }),
+	}
+	for _, q := range o.percentiles() {
+		q := q
+		columns = append(columns, csvNumber(percentileColumnName(q)+"_"+unit.Name, func(r Result, s *ScriptResult) string {
+			if s.Latencies.TotalCount() == 0 {
+				return ""
+			}
+			return formatDecimal(float64(valueAtPercentile(s.Latencies, q))/unit.Micros, places)
+		}))
+	}
+	return columns
+}
+
+func (o *HeatmapOutput) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultHeatmapPercentiles
+	}
+	return o.Percentiles
+}
@@ -0,0 +1,91 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/codahale/hdrhistogram"
+)
+
+// Writes the latency distribution of all scripts combined to stdout in the HdrHistogram percentile distribution
+// (.hgrm) format, as read by the HdrHistogram plotting tools. Values are in milliseconds. Progress goes to stderr.
+type HgrmOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	ProgressReporter
+	errorTracker
+}
+
+func (o *HgrmOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *HgrmOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *HgrmOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+// The distribution is for the run as a whole, so samples are left out
+func (o *HgrmOutput) ReportInterval(sample IntervalResult) error {
+	return nil
+}
+
+// Latencies are recorded in throughput mode as well, so this writes the same distribution ReportLatency does
+func (o *HgrmOutput) ReportThroughput(result Result) error {
+	return o.ReportLatency(result)
+}
+
+func (o *HgrmOutput) ReportLatency(result Result) error {
+	_, err := fmt.Fprint(o.OutStream, formatHgrm(result.Total().Latencies, 1000.0))
+	return err
+}
+
+func (o *HgrmOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *HgrmOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *HgrmOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *HgrmOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *HgrmOutput) Close() error {
+	return nil
+}
+
+func (o *HgrmOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+// Formats h like HdrHistogram's outputPercentileDistribution does, with values divided by scale
+func formatHgrm(h *hdrhistogram.Histogram, scale float64) string {
+	s := strings.Builder{}
+	s.WriteString(fmt.Sprintf("%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)"))
+	for _, bracket := range h.CumulativeDistribution() {
+		percentile := bracket.Quantile / 100
+		value := float64(bracket.ValueAt) / scale
+		if percentile >= 1 {
+			// The inverted percentile is infinite for the last row, the reference implementation leaves it out
+			s.WriteString(fmt.Sprintf("%12.3f %2.12f %10d\n", value, percentile, bracket.Count))
+			continue
+		}
+		s.WriteString(fmt.Sprintf("%12.3f %2.12f %10d %14.2f\n", value, percentile, bracket.Count, 1/(1-percentile)))
+	}
+	s.WriteString(fmt.Sprintf("#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", h.Mean()/scale, h.StdDev()/scale))
+	s.WriteString(fmt.Sprintf("#[Max     = %12.3f, Total count    = %12d]\n", float64(h.Max())/scale, h.TotalCount()))
+	return s.String()
+}
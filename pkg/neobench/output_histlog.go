@@ -0,0 +1,207 @@
+package neobench
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Writes the latency histogram of each sample interval to stdout in the HdrHistogram interval log format, for
+// replaying the time-resolved distribution offline with HistogramLogProcessor and the other HdrHistogram tools.
+// Each interval is a line with its start relative to the log's BaseTime, its length, its max latency and its
+// histogram, compressed and base64-encoded the way the Java implementation does it. The untagged line of an
+// interval is for all scripts combined; with several scripts, each also gets a line tagged with its name.
+// Latencies are recorded in microseconds, so Interval_Max is in milliseconds and HistogramLogProcessor wants
+// -outputValueUnitRatio 1000 to report milliseconds too. Final results aren't written, they're the sum of the
+// intervals. Progress and error details go to stderr.
+type HistlogOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	ProgressReporter
+	// Start of the run's first interval, the log's BaseTime; the header is written with it
+	baseTime time.Time
+	errorTracker
+}
+
+func (o *HistlogOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *HistlogOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *HistlogOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+func (o *HistlogOutput) ReportInterval(sample IntervalResult) error {
+	s := strings.Builder{}
+	if o.baseTime.IsZero() {
+		o.baseTime = sample.Start
+		writeHistlogHeader(&s, sample.Start, sample.RunId)
+	}
+	if err := o.writeEntry(&s, sample, "", sample.Total().Latencies); err != nil {
+		return err
+	}
+	if scripts := sortedScripts(sample.Result); len(scripts) > 1 {
+		for _, script := range scripts {
+			if err := o.writeEntry(&s, sample, script.ScriptName, script.Latencies); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprint(o.OutStream, s.String())
+	return err
+}
+
+// The log is of the intervals the run was sampled in, so only failures from the final results are written
+func (o *HistlogOutput) ReportThroughput(result Result) error {
+	return o.ReportLatency(result)
+}
+
+func (o *HistlogOutput) ReportLatency(result Result) error {
+	if result.TotalFailed() == 0 {
+		return nil
+	}
+	s := strings.Builder{}
+	writeErrorReport(result, o.reportedByCategory, &s, false)
+	_, err := fmt.Fprint(o.ErrStream, s.String())
+	return err
+}
+
+func (o *HistlogOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *HistlogOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *HistlogOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *HistlogOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *HistlogOutput) Close() error {
+	return nil
+}
+
+// The next run starts a log of its own, with its own header and BaseTime
+func (o *HistlogOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+	o.baseTime = time.Time{}
+}
+
+// Readers skip comment lines they don't know, so the run id goes in one of its own
+func writeHistlogHeader(s *strings.Builder, base time.Time, runId string) {
+	seconds := float64(base.UnixNano()) / 1e9
+	s.WriteString("#[Logged with neobench, latencies in microseconds]\n")
+	s.WriteString("#[Histogram log format version 1.3]\n")
+	if runId != "" {
+		s.WriteString(fmt.Sprintf("#[RunId: %s]\n", runId))
+	}
+	s.WriteString(fmt.Sprintf("#[StartTime: %.3f (seconds since epoch), %s]\n", seconds, base.UTC().Format("Mon Jan 02 15:04:05 MST 2006")))
+	s.WriteString(fmt.Sprintf("#[BaseTime: %.3f (seconds since epoch)]\n", seconds))
+	s.WriteString("\"StartTimestamp\",\"Interval_Length\",\"Interval_Max\",\"Interval_Compressed_Histogram\"\n")
+}
+
+func (o *HistlogOutput) writeEntry(s *strings.Builder, sample IntervalResult, tag string, histo *hdrhistogram.Histogram) error {
+	encoded, err := EncodeHdrHistogram(histo)
+	if err != nil {
+		return err
+	}
+	if tag != "" {
+		s.WriteString("Tag=" + histlogTag(tag) + ",")
+	}
+	s.WriteString(fmt.Sprintf("%.3f,%.3f,%.3f,%s\n", sample.Start.Sub(o.baseTime).Seconds(), sample.End.Sub(sample.Start).Seconds(),
+		float64(histo.Max())/1000.0, base64.StdEncoding.EncodeToString(encoded)))
+	return nil
+}
+
+// Tags end at the first comma and can't hold whitespace
+func histlogTag(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// Cookies of HdrHistogram's V2 encoding, as the Java implementation writes it, and of its compressed form
+const (
+	hdrEncodingCookie           = 0x1c849313
+	hdrCompressedEncodingCookie = 0x1c849314
+)
+
+// Encodes histo in HdrHistogram's compressed V2 encoding, which the interval log holds and the HdrHistogram
+// implementations all decode: a header of the histogram's settings, its counts as ZigZag LEB128 varints with
+// runs of empty buckets written as one negative count, deflated with zlib behind a header of its own.
+func EncodeHdrHistogram(histo *hdrhistogram.Histogram) ([]byte, error) {
+	snapshot := histo.Export()
+	countsLimit := len(snapshot.Counts)
+	for countsLimit > 0 && snapshot.Counts[countsLimit-1] == 0 {
+		countsLimit--
+	}
+	payload := &bytes.Buffer{}
+	varint := make([]byte, binary.MaxVarintLen64)
+	for i := 0; i < countsLimit; i++ {
+		count := snapshot.Counts[i]
+		if count == 0 {
+			zeros := int64(1)
+			for i+1 < countsLimit && snapshot.Counts[i+1] == 0 {
+				zeros++
+				i++
+			}
+			if zeros > 1 {
+				count = -zeros
+			}
+		}
+		payload.Write(varint[:binary.PutVarint(varint, count)])
+	}
+
+	// Our histograms track from zero, the encoding's lowest discernible value is at least one
+	lowest := snapshot.LowestTrackableValue
+	if lowest < 1 {
+		lowest = 1
+	}
+	encoded := &bytes.Buffer{}
+	for _, field := range []interface{}{int32(hdrEncodingCookie), int32(payload.Len()), int32(0), int32(snapshot.SignificantFigures),
+		lowest, snapshot.HighestTrackableValue, math.Float64bits(1.0)} {
+		if err := binary.Write(encoded, binary.BigEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	encoded.Write(payload.Bytes())
+
+	deflated := &bytes.Buffer{}
+	w := zlib.NewWriter(deflated)
+	if _, err := w.Write(encoded.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	compressed := &bytes.Buffer{}
+	for _, field := range []interface{}{int32(hdrCompressedEncodingCookie), int32(deflated.Len())} {
+		if err := binary.Write(compressed, binary.BigEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	compressed.Write(deflated.Bytes())
+	return compressed.Bytes(), nil
+}
@@ -0,0 +1,197 @@
+package neobench
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/codahale/hdrhistogram"
+	"github.com/pkg/errors"
+)
+
+// Writes the raw latency histogram of all scripts combined to stdout, compressed and base64-encoded on a single
+// line, see EncodeHistogram. Histograms from several runs can be merged afterwards, see ReadHistograms.
+// Progress goes to stderr.
+type HistogramOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	ProgressReporter
+	errorTracker
+}
+
+func (o *HistogramOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *HistogramOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *HistogramOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+// The histogram is for the run as a whole, so samples are left out
+func (o *HistogramOutput) ReportInterval(sample IntervalResult) error {
+	return nil
+}
+
+// Latencies are recorded in throughput mode as well, so this writes the same histogram ReportLatency does
+func (o *HistogramOutput) ReportThroughput(result Result) error {
+	return o.ReportLatency(result)
+}
+
+func (o *HistogramOutput) ReportLatency(result Result) error {
+	encoded, err := EncodeHistogram(result.Total().Latencies)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(o.OutStream, encoded)
+	return err
+}
+
+func (o *HistogramOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *HistogramOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *HistogramOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *HistogramOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *HistogramOutput) Close() error {
+	return nil
+}
+
+func (o *HistogramOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+// Version of the EncodeHistogram format, written first so the format can change without breaking old files
+const histogramEncodingVersion = 1
+
+// Encodes the hdrhistogram.Snapshot of h as varints, zlib-compressed and base64-encoded; the counts are
+// mostly zeros, so this compresses well. DecodeHistogram reverses it.
+func EncodeHistogram(h *hdrhistogram.Histogram) (string, error) {
+	snapshot := h.Export()
+	raw := make([]byte, 0, (4+len(snapshot.Counts))*binary.MaxVarintLen64)
+	raw = appendVarint(raw, histogramEncodingVersion)
+	raw = appendVarint(raw, snapshot.LowestTrackableValue)
+	raw = appendVarint(raw, snapshot.HighestTrackableValue)
+	raw = appendVarint(raw, snapshot.SignificantFigures)
+	raw = appendVarint(raw, int64(len(snapshot.Counts)))
+	for _, count := range snapshot.Counts {
+		raw = appendVarint(raw, count)
+	}
+
+	compressed := bytes.Buffer{}
+	w := zlib.NewWriter(&compressed)
+	if _, err := w.Write(raw); err != nil {
+		return "", errors.Wrap(err, "failed to compress histogram")
+	}
+	if err := w.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to compress histogram")
+	}
+	return base64.StdEncoding.EncodeToString(compressed.Bytes()), nil
+}
+
+func DecodeHistogram(encoded string) (*hdrhistogram.Histogram, error) {
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.Wrap(err, "histogram is not valid base64")
+	}
+	r, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress histogram")
+	}
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress histogram")
+	}
+
+	in := bytes.NewReader(raw)
+	var header [5]int64
+	for i := range header {
+		if header[i], err = binary.ReadVarint(in); err != nil {
+			return nil, errors.Wrap(err, "histogram is truncated")
+		}
+	}
+	if header[0] != histogramEncodingVersion {
+		return nil, fmt.Errorf("unsupported histogram encoding version %d, expected %d", header[0], histogramEncodingVersion)
+	}
+	// hdrhistogram takes whatever it's given, and panics or allocates without end on settings it can't work with
+	if header[1] < 0 || header[2] < 0 || header[2] > math.MaxInt64/int64(time.Microsecond) {
+		return nil, fmt.Errorf("histogram has an invalid range of %dµs to %dµs", header[1], header[2])
+	}
+	config := HistogramConfig{
+		Min:                time.Duration(header[1]) * time.Microsecond,
+		Max:                time.Duration(header[2]) * time.Microsecond,
+		SignificantFigures: int(header[3]),
+	}
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "histogram was encoded with invalid settings")
+	}
+	if header[4] < 0 || header[4] > int64(len(raw)) {
+		return nil, fmt.Errorf("histogram claims to have %d counts, which doesn't fit in the encoded data", header[4])
+	}
+	if expected := len(config.newHistogram().Export().Counts); header[4] != int64(expected) {
+		return nil, fmt.Errorf("histogram has %d counts, its settings need %d", header[4], expected)
+	}
+	snapshot := &hdrhistogram.Snapshot{
+		LowestTrackableValue:  header[1],
+		HighestTrackableValue: header[2],
+		SignificantFigures:    header[3],
+		Counts:                make([]int64, header[4]),
+	}
+	for i := range snapshot.Counts {
+		if snapshot.Counts[i], err = binary.ReadVarint(in); err != nil {
+			return nil, errors.Wrap(err, "histogram is truncated")
+		}
+	}
+	return hdrhistogram.Import(snapshot), nil
+}
+
+// Reads histograms written by HistogramOutput, one per line, skipping blank lines
+func ReadHistograms(r io.Reader) ([]*hdrhistogram.Histogram, error) {
+	histograms := make([]*hdrhistogram.Histogram, 0)
+	scanner := bufio.NewScanner(r)
+	// Lines are one encoded histogram each, which can be well over the default token size
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		h, err := DecodeHistogram(scanner.Text())
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", line)
+		}
+		histograms = append(histograms, h)
+	}
+	return histograms, scanner.Err()
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
@@ -0,0 +1,256 @@
+package neobench
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Writes results to stdout as a standalone HTML report, for sharing with people who'd rather not read CSV.
+// Each result is a section with a summary table and a chart of its latency distribution; the chart is drawn
+// by a few lines of inline JavaScript from data embedded in the page, so the file opens without a server or
+// network access. Progress and error details go to stderr.
+type HtmlOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Latency percentiles in the summary table, defaults to DefaultHtmlPercentiles; the chart always shows
+	// the whole distribution
+	Percentiles []float64
+	// Unit to show latencies in, defaults to milliseconds
+	LatencyUnit LatencyUnit
+	// Decimal places in the summary table's latencies and rates, defaults to DefaultPrecision
+	Precision *int
+	ProgressReporter
+	// Sections written so far, used to give each chart its own id; the page header is written with the first
+	sections int
+	errorTracker
+}
+
+var DefaultHtmlPercentiles = []float64{50, 90, 99, 99.9}
+
+// Points the chart is drawn through, denser towards the tail, which is spread out by the chart's log scale
+var htmlChartPercentiles = []float64{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 95, 97.5, 99, 99.5, 99.75, 99.9,
+	99.95, 99.975, 99.99, 99.995, 99.999, 99.9995, 99.9999, 100}
+
+func (o *HtmlOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *HtmlOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *HtmlOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+// The report is of the final results, samples would just bury them
+func (o *HtmlOutput) ReportInterval(sample IntervalResult) error {
+	return nil
+}
+
+// Latencies are recorded in throughput mode as well, so this writes the same section ReportLatency does
+func (o *HtmlOutput) ReportThroughput(result Result) error {
+	return o.ReportLatency(result)
+}
+
+func (o *HtmlOutput) ReportLatency(result Result) error {
+	s := strings.Builder{}
+	if o.sections == 0 {
+		s.WriteString(htmlHeader)
+	}
+	o.sections++
+	if err := o.writeSection(&s, result); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
+		return err
+	}
+	if result.TotalFailed() == 0 {
+		return nil
+	}
+	errReport := strings.Builder{}
+	writeErrorReport(result, o.reportedByCategory, &errReport, false)
+	_, err := fmt.Fprint(o.ErrStream, errReport.String())
+	return err
+}
+
+func (o *HtmlOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *HtmlOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *HtmlOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *HtmlOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+// Ends the page, once every result has its section
+func (o *HtmlOutput) Close() error {
+	if o.sections == 0 {
+		return nil
+	}
+	_, err := fmt.Fprint(o.OutStream, htmlFooter)
+	return err
+}
+
+func (o *HtmlOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+type htmlChart struct {
+	Unit   string       `json:"unit"`
+	Series []htmlSeries `json:"series"`
+}
+
+type htmlSeries struct {
+	Name string `json:"name"`
+	// Pairs of percentile and latency
+	Points [][2]float64 `json:"points"`
+}
+
+func (o *HtmlOutput) writeSection(s *strings.Builder, result Result) error {
+	unit := resolveLatencyUnit(o.LatencyUnit, result)
+	scripts := sortedScripts(result)
+	// With several scripts, add a row for the workload as a whole
+	if len(scripts) > 1 {
+		scripts = append(scripts, result.Total())
+	}
+
+	s.WriteString("<section>\n")
+	s.WriteString(fmt.Sprintf("<h2>%s</h2>\n", html.EscapeString(result.Scenario)))
+	databaseName := result.DatabaseName
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	s.WriteString(fmt.Sprintf("<p>Database: %s", html.EscapeString(databaseName)))
+	if result.RunId != "" {
+		s.WriteString(fmt.Sprintf(", run ID %s", html.EscapeString(result.RunId)))
+	}
+	if result.NeobenchVersion != "" || result.Neo4jVersion != "" {
+		s.WriteString(fmt.Sprintf(", neobench %s, Neo4j %s",
+			html.EscapeString(orUnknown(result.NeobenchVersion)), html.EscapeString(orUnknown(result.Neo4jVersion))))
+	}
+	s.WriteString("</p>\n")
+
+	s.WriteString("<table>\n<tr><th>Script</th><th>Succeeded</th><th>Failed</th><th>Rate (tps)</th>")
+	s.WriteString(fmt.Sprintf("<th>Mean (%s)</th>", unit.Name))
+	for _, q := range o.percentiles() {
+		s.WriteString(fmt.Sprintf("<th>P%s (%s)</th>", formatPercentile(q), unit.Name))
+	}
+	s.WriteString("</tr>\n")
+	chart := htmlChart{Unit: unit.Name, Series: make([]htmlSeries, 0, len(scripts))}
+	places := decimalPlaces(o.Precision)
+	for _, script := range scripts {
+		histo := script.Latencies
+		s.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%d</td><td>%s</td><td>%s</td>",
+			html.EscapeString(script.ScriptName), script.Succeeded, script.Failed, formatDecimal(script.Rate, places),
+			formatDecimal(histo.Mean()/unit.Micros, places)))
+		for _, q := range o.percentiles() {
+			s.WriteString(fmt.Sprintf("<td>%s</td>", formatDecimal(float64(valueAtPercentile(histo, q))/unit.Micros, places)))
+		}
+		s.WriteString("</tr>\n")
+
+		series := htmlSeries{Name: script.ScriptName, Points: make([][2]float64, 0, len(htmlChartPercentiles))}
+		if histo.TotalCount() > 0 {
+			for _, q := range htmlChartPercentiles {
+				series.Points = append(series.Points, [2]float64{q, float64(valueAtPercentile(histo, q)) / unit.Micros})
+			}
+		}
+		chart.Series = append(chart.Series, series)
+	}
+	s.WriteString("</table>\n")
+
+	// Marshal escapes <, > and &, so nothing in the data can end the script element early
+	data, err := json.Marshal(chart)
+	if err != nil {
+		return err
+	}
+	s.WriteString(fmt.Sprintf("<canvas id=\"chart-%d\" width=\"900\" height=\"400\"></canvas>\n", o.sections))
+	s.WriteString(fmt.Sprintf("<script type=\"application/json\" id=\"data-%d\">%s</script>\n", o.sections, data))
+	s.WriteString(fmt.Sprintf("<script>drawChart(document.getElementById(\"chart-%d\"), "+
+		"JSON.parse(document.getElementById(\"data-%d\").textContent));</script>\n", o.sections, o.sections))
+	s.WriteString("</section>\n")
+	return nil
+}
+
+func (o *HtmlOutput) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultHtmlPercentiles
+	}
+	return o.Percentiles
+}
+
+// Written with the first section; each result adds its own, and Close ends the page with htmlFooter
+const htmlHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>neobench results</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; }
+td { text-align: right; }
+td:first-child { text-align: left; }
+section { margin-bottom: 3em; }
+</style>
+<script>
+// Latency by percentile, with percentiles on a log scale so P99, P99.9 and so on are evenly spaced
+function drawChart(canvas, data) {
+  var ctx = canvas.getContext("2d"), w = canvas.width, h = canvas.height, pad = 60, maxNines = 6;
+  var colors = ["#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"];
+  var maxY = 0;
+  data.series.forEach(function(s) { s.points.forEach(function(p) { maxY = Math.max(maxY, p[1]); }); });
+  maxY = maxY || 1;
+  var x = function(q) { return pad + (q >= 100 ? maxNines : Math.min(maxNines, -Math.log10(1 - q / 100))) / maxNines * (w - 2 * pad); };
+  var y = function(v) { return h - pad - v / maxY * (h - 2 * pad); };
+  ctx.font = "12px sans-serif";
+  ctx.lineWidth = 1;
+  ctx.strokeStyle = "#ddd";
+  ctx.fillStyle = "#222";
+  ["0", "90", "99", "99.9", "99.99", "99.999", "99.9999"].forEach(function(label, i) {
+    var px = pad + i / maxNines * (w - 2 * pad);
+    ctx.beginPath(); ctx.moveTo(px, pad); ctx.lineTo(px, h - pad); ctx.stroke();
+    ctx.fillText("P" + label, px - 16, h - pad + 18);
+  });
+  for (var i = 0; i <= 4; i++) {
+    var v = maxY * i / 4, py = y(v);
+    ctx.beginPath(); ctx.moveTo(pad, py); ctx.lineTo(w - pad, py); ctx.stroke();
+    ctx.fillText(v.toFixed(1), 4, py + 4);
+  }
+  ctx.fillText("Latency (" + data.unit + ") by percentile", pad, pad - 24);
+  data.series.forEach(function(s, i) {
+    var color = colors[i % colors.length];
+    ctx.strokeStyle = color;
+    ctx.lineWidth = 2;
+    ctx.beginPath();
+    s.points.forEach(function(p, j) {
+      if (j === 0) { ctx.moveTo(x(p[0]), y(p[1])); } else { ctx.lineTo(x(p[0]), y(p[1])); }
+    });
+    ctx.stroke();
+    ctx.fillStyle = color;
+    ctx.fillText(s.name, pad + 10, pad + 16 * (i + 1));
+  });
+}
+</script>
+</head>
+<body>
+<h1>neobench results</h1>
+`
+
+const htmlFooter = `</body>
+</html>
+`
@@ -0,0 +1,145 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Writes results to stdout as InfluxDB line protocol, for piping into `influx write`. Each script gets a
+// neobench_throughput point, and a neobench_latency point with latencies in milliseconds; samples are
+// written as points too, timestamped when they were taken. Progress goes to stderr.
+type InfluxOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Latency percentiles to report, defaults to DefaultInfluxPercentiles
+	Percentiles []float64
+	ProgressReporter
+	errorTracker
+	// Timestamps final results, defaults to time.Now
+	now func() time.Time
+}
+
+var DefaultInfluxPercentiles = []float64{50, 75, 95, 99, 99.9, 99.999}
+
+func (o *InfluxOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *InfluxOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *InfluxOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+func (o *InfluxOutput) ReportInterval(sample IntervalResult) error {
+	return o.writePoints(sample.Result, sample.End, true)
+}
+
+func (o *InfluxOutput) ReportThroughput(result Result) error {
+	return o.writePoints(result, o.timestamp(), false)
+}
+
+func (o *InfluxOutput) ReportLatency(result Result) error {
+	return o.writePoints(result, o.timestamp(), true)
+}
+
+func (o *InfluxOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *InfluxOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *InfluxOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *InfluxOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *InfluxOutput) Close() error {
+	return nil
+}
+
+func (o *InfluxOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+func (o *InfluxOutput) writePoints(result Result, at time.Time, includeLatency bool) error {
+	s := strings.Builder{}
+	for _, script := range sortedScripts(result) {
+		tags := influxTags(result, script)
+		s.WriteString(fmt.Sprintf("neobench_throughput%s tps=%s,succeeded=%di,failed=%di %d\n",
+			tags, influxFloat(script.Rate), script.Succeeded, script.Failed, at.UnixNano()))
+		if !includeLatency {
+			continue
+		}
+		histo := script.Latencies
+		fields := []string{
+			"mean=" + influxFloat(histo.Mean()/1000.0),
+			"min=" + influxFloat(float64(histo.Min())/1000.0),
+			"max=" + influxFloat(float64(histo.Max())/1000.0),
+			"stddev=" + influxFloat(histo.StdDev()/1000.0),
+		}
+		for _, q := range o.percentiles() {
+			fields = append(fields, percentileColumnName(q)+"="+influxFloat(float64(valueAtPercentile(histo, q))/1000.0))
+		}
+		s.WriteString(fmt.Sprintf("neobench_latency%s %s %d\n", tags, strings.Join(fields, ","), at.UnixNano()))
+	}
+	_, err := fmt.Fprint(o.OutStream, s.String())
+	return err
+}
+
+func (o *InfluxOutput) timestamp() time.Time {
+	if o.now == nil {
+		return time.Now()
+	}
+	return o.now()
+}
+
+func (o *InfluxOutput) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultInfluxPercentiles
+	}
+	return o.Percentiles
+}
+
+// Tag set including the leading comma; line protocol doesn't allow empty tag values, so those are left out
+func influxTags(result Result, script *ScriptResult) string {
+	tags := []struct{ key, value string }{
+		{"scenario", result.Scenario},
+		{"database", result.DatabaseName},
+		{"script", script.ScriptName},
+	}
+	if result.Config.Clients > 0 {
+		tags = append(tags, struct{ key, value string }{"clients", fmt.Sprintf("%d", result.Config.Clients)})
+	}
+	tags = append(tags, struct{ key, value string }{"run_id", result.RunId})
+	for _, key := range sortedTagKeys(result.Tags) {
+		tags = append(tags, struct{ key, value string }{key, result.Tags[key]})
+	}
+	s := strings.Builder{}
+	for _, tag := range tags {
+		if tag.value == "" {
+			continue
+		}
+		s.WriteString("," + tag.key + "=" + influxTagEscaper.Replace(tag.value))
+	}
+	return s.String()
+}
+
+// Tag values escape commas, equals signs and spaces, per the line protocol
+var influxTagEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+
+func influxFloat(v float64) string {
+	return fmt.Sprintf("%.3f", v)
+}
@@ -0,0 +1,518 @@
+package neobench
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"io"
+	"sort"
+	"time"
+)
+
+// Writes progress as newline-delimited JSON events to stderr, and one JSON document per result to stdout.
+// All numbers are emitted as JSON numbers, latencies are in milliseconds.
+type JsonOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Latency percentiles to report, defaults to DefaultJsonPercentiles
+	Percentiles []float64
+	// Percent of the highest and lowest sample rates to leave out of trimmed_rate, see Result.TrimmedSampleRate;
+	// zero leaves it out
+	TrimRates float64
+	ProgressReporter
+	errorTracker
+}
+
+type jsonResult struct {
+	Database string `json:"database"`
+	Scenario string `json:"scenario"`
+	// Scenario made safe to use in a filename, see ScenarioSlug
+	ScenarioSlug string  `json:"scenario_slug"`
+	Succeeded    int64   `json:"succeeded"`
+	Failed       int64   `json:"failed"`
+	Rate         float64 `json:"rate"`
+	// Only set when the run duration is known
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	MeasuredRate    float64 `json:"measured_rate,omitempty"`
+	// What the run was asked to do, see RunConfig; only the one it went by is set, and neither if it isn't known
+	RequestedTransactions    uint64  `json:"requested_transactions,omitempty"`
+	RequestedDurationSeconds float64 `json:"requested_duration_seconds,omitempty"`
+	// Lowest and highest rate of any sample interval, only set when the run was sampled
+	MinRate *float64 `json:"min_rate,omitempty"`
+	MaxRate *float64 `json:"max_rate,omitempty"`
+	// Mean rate of the sample intervals, and the same without the highest and lowest TrimRates percent of them;
+	// only set when the run was sampled and JsonOutput.TrimRates is
+	SampleMeanRate *float64 `json:"sample_mean_rate,omitempty"`
+	TrimmedRate    *float64 `json:"trimmed_rate,omitempty"`
+	TrimmedPercent float64  `json:"trimmed_percent,omitempty"`
+	// Rate at each of DefaultRatePercentiles, see Result.SampleRatePercentile; only set when the run was sampled
+	RatePercentiles map[string]float64 `json:"rate_percentiles,omitempty"`
+	// Transactions in flight at once, see Concurrency; only set when tracked
+	MeanConcurrency *float64 `json:"mean_concurrency,omitempty"`
+	MaxConcurrency  *int     `json:"max_concurrency,omitempty"`
+	// Time to get a connection from the driver's pool, see PoolWait; only set when tracked
+	PoolWait *jsonPoolWait `json:"pool_wait,omitempty"`
+	// CPU the neobench process used, see ClientCpu; only set when it could be read
+	ClientCpu *jsonClientCpu `json:"client_cpu,omitempty"`
+	// Only set when known
+	NeobenchVersion string `json:"neobench_version,omitempty"`
+	Neo4jVersion    string `json:"neo4j_version,omitempty"`
+	// Id of the run, see Result.RunId; left out if it has none
+	RunId string `json:"run_id,omitempty"`
+	// Labels of the run, see TaggingOutput; left out if it has none
+	Tags map[string]string `json:"tags,omitempty"`
+	// Only set for the warmup before the measured run, see Result.Warmup
+	Warmup bool `json:"warmup,omitempty"`
+	// Only set if the run was cut short, see Result.Interrupted
+	Interrupted bool               `json:"interrupted,omitempty"`
+	Scripts     []jsonScriptResult `json:"scripts"`
+	// Only set with --top-slow, see Result.Slowest
+	Slowest []jsonSlowTransaction `json:"slowest_transactions,omitempty"`
+	// Only set with --diagnostics
+	Process *jsonProcessStats `json:"process,omitempty"`
+	Total   jsonScriptResult  `json:"total"`
+	Errors  []jsonErrorGroup  `json:"errors"`
+	// Failed transactions by ErrorCategory; categories without any are left out
+	ErrorsByCategory map[string]int64 `json:"errors_by_category"`
+	// Errors reported during the run, eg. worker crashes, by ErrorCategory; left out if there were none
+	ReportedErrorsByCategory map[string]int64 `json:"reported_errors_by_category,omitempty"`
+	// What latencies were recorded with, see HistogramConfig; they're accurate to no more than this
+	Histogram jsonHistogramConfig `json:"histogram"`
+}
+
+type jsonHistogramConfig struct {
+	SignificantFigures int     `json:"significant_figures"`
+	MinMs              float64 `json:"min_trackable_ms"`
+	MaxMs              float64 `json:"max_trackable_ms"`
+}
+
+type jsonScriptResult struct {
+	Script    string  `json:"script"`
+	Succeeded int64   `json:"succeeded"`
+	Failed    int64   `json:"failed"`
+	Rate      float64 `json:"rate"`
+	// Attempts the driver retried after transient failures; latency includes them, latency_without_retries doesn't
+	Retries int64        `json:"retries"`
+	Latency *jsonLatency `json:"latency"`
+	// See ScriptResult.WithoutRetries; left out unless there were retries
+	LatencyWithoutRetries *jsonLatency `json:"latency_without_retries,omitempty"`
+	// By phase, see Phases; left out if phases weren't measured
+	Phases map[string]*jsonLatency `json:"phases,omitempty"`
+	// By access mode, see AccessModes; left out if transactions weren't recorded by access mode
+	AccessModes map[string]*jsonLatency `json:"access_modes,omitempty"`
+	// Left out if the workload didn't return any records
+	RecordRate float64 `json:"records_per_second,omitempty"`
+	ByteRate   float64 `json:"bytes_per_second,omitempty"`
+}
+
+type jsonLatency struct {
+	Min         float64            `json:"min_ms"`
+	Mean        float64            `json:"mean_ms"`
+	Max         float64            `json:"max_ms"`
+	StdDev      float64            `json:"stddev_ms"`
+	Percentiles map[string]float64 `json:"percentiles_ms"`
+}
+
+type jsonPlan struct {
+	Database        string  `json:"database"`
+	Scenario        string  `json:"scenario"`
+	Clients         int     `json:"clients"`
+	TargetRate      float64 `json:"target_rate"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Zero for runs that go by duration
+	Transactions uint64              `json:"transactions"`
+	Init         bool                `json:"init"`
+	Scripts      []jsonPlannedScript `json:"scripts"`
+}
+
+type jsonPlannedScript struct {
+	Script string  `json:"script"`
+	Weight float64 `json:"weight"`
+	// Fraction of transactions that run the script
+	Share    float64 `json:"share"`
+	Readonly bool    `json:"readonly"`
+}
+
+type jsonProcessStats struct {
+	HeapAllocBytes  uint64  `json:"heap_alloc_bytes"`
+	TotalAllocBytes uint64  `json:"total_alloc_bytes"`
+	NumGC           uint32  `json:"gc_count"`
+	PauseTotal      float64 `json:"gc_pause_total_ms"`
+	MaxPause        float64 `json:"gc_pause_max_ms"`
+}
+
+type jsonPoolWait struct {
+	Size         int     `json:"size"`
+	Transactions int64   `json:"transactions"`
+	MeanMs       float64 `json:"mean_acquisition_ms"`
+	MaxMs        float64 `json:"max_acquisition_ms"`
+}
+
+type jsonClientCpu struct {
+	Cpus            int     `json:"cpus"`
+	CpuSeconds      float64 `json:"cpu_seconds"`
+	MeanUtilization float64 `json:"mean_utilization"`
+	PeakUtilization float64 `json:"peak_utilization"`
+}
+
+type jsonSlowTransaction struct {
+	Script     string  `json:"script"`
+	Start      string  `json:"start,omitempty"`
+	LatencyMs  float64 `json:"latency_ms"`
+	Succeeded  bool    `json:"succeeded"`
+	Parameters string  `json:"parameters,omitempty"`
+}
+
+type jsonErrorGroup struct {
+	Group   string `json:"group"`
+	Count   int64  `json:"count"`
+	Example string `json:"example"`
+}
+
+type jsonEvent struct {
+	Event        string  `json:"event"`
+	Database     string  `json:"database,omitempty"`
+	Url          string  `json:"url,omitempty"`
+	Scenario     string  `json:"scenario,omitempty"`
+	Section      string  `json:"section,omitempty"`
+	Step         string  `json:"step,omitempty"`
+	Completeness float64 `json:"completeness,omitempty"`
+	Rate         float64 `json:"rate,omitempty"`
+	Failed       int64   `json:"failed,omitempty"`
+	Message      string  `json:"message,omitempty"`
+	// Set for errors passed to ReportError
+	Category string `json:"category,omitempty"`
+	// Set for the run event, see TaggingOutput
+	RunId string `json:"run_id,omitempty"`
+	// Set for interval samples, see ReportInterval
+	Timestamp string            `json:"timestamp,omitempty"`
+	Interval  float64           `json:"interval_seconds,omitempty"`
+	Total     *jsonScriptResult `json:"total,omitempty"`
+}
+
+// Progress events have a fixed set of fields, so they are easy to ingest as logs
+type jsonProgressEvent struct {
+	Event        string  `json:"event"`
+	Timestamp    string  `json:"timestamp"`
+	Section      string  `json:"section"`
+	Step         string  `json:"step"`
+	Completeness float64 `json:"completeness"`
+	Elapsed      float64 `json:"elapsed_seconds"`
+	Eta          float64 `json:"eta_seconds,omitempty"`
+}
+
+func (o *JsonOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	return o.writeEvent(jsonEvent{Event: "start", Database: databaseName, Url: url, Scenario: scenario})
+}
+
+func (o *JsonOutput) ReportProgress(report ProgressReport) {
+	o.reportJsonProgress(o.ErrStream, report)
+}
+
+// Like ProgressReporter.reportProgress, writing progress events rather than lines
+func (p *ProgressReporter) reportJsonProgress(w io.Writer, report ProgressReport) {
+	now := time.Now()
+	if due, _ := p.due(report, p.ProgressInterval, now); due {
+		writeJsonProgress(w, report, &p.timer, now)
+	}
+}
+
+// Best-effort, see Output; the fields are fixed, so with the timer hidden the timestamp is empty and timings zero
+func writeJsonProgress(w io.Writer, report ProgressReport, timer *progressTimer, now time.Time) {
+	event := jsonProgressEvent{
+		Event:        "progress",
+		Section:      report.Section,
+		Step:         report.Step,
+		Completeness: report.Completeness,
+	}
+	if !timer.hidden {
+		eta, _ := timer.eta(report, now)
+		event.Timestamp, event.Elapsed, event.Eta = now.UTC().Format(time.RFC3339Nano), timer.elapsed(now).Seconds(), eta.Seconds()
+	}
+	_ = newJsonEncoder(w).Encode(event)
+}
+
+// Writes progress as newline-delimited JSON events to stderr, like JsonOutput does, and leaves everything
+// else to the wrapped Output; this way progress can be ingested as logs whatever format results are in
+type JsonProgressOutput struct {
+	Output
+	ErrStream io.Writer
+	ProgressReporter
+}
+
+func (o *JsonProgressOutput) ReportProgress(report ProgressReport) {
+	o.reportJsonProgress(o.ErrStream, report)
+}
+
+// Workload progress is progress too, so it's an event rather than the wrapped output's text line
+func (o *JsonProgressOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	return writeJsonWorkloadProgress(o.ErrStream, completeness, checkpoint)
+}
+
+func (o *JsonProgressOutput) Reset() {
+	o.resetProgress()
+	ResetOutput(o.Output)
+}
+
+func (o *JsonOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	return writeJsonWorkloadProgress(o.ErrStream, completeness, checkpoint)
+}
+
+func writeJsonWorkloadProgress(w io.Writer, completeness float64, checkpoint Result) error {
+	return newJsonEncoder(w).Encode(jsonEvent{
+		Event:        "workload_progress",
+		Completeness: completeness,
+		Rate:         checkpoint.TotalRate(),
+		Failed:       checkpoint.TotalFailed(),
+	})
+}
+
+func (o *JsonOutput) ReportInterval(sample IntervalResult) error {
+	total := o.scriptResult(sample.Total())
+	event := jsonEvent{Event: "interval", Interval: sample.Duration.Seconds(), Total: &total}
+	// Zero with deterministic output, see DeterministicOutput
+	if !sample.End.IsZero() {
+		event.Timestamp = sample.End.UTC().Format(time.RFC3339Nano)
+	}
+	return o.writeEvent(event)
+}
+
+func (o *JsonOutput) ReportThroughput(result Result) error {
+	return o.writeResult(result)
+}
+
+func (o *JsonOutput) ReportLatency(result Result) error {
+	return o.writeResult(result)
+}
+
+func (o *JsonOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	// Best-effort, see Output
+	_ = o.writeEvent(jsonEvent{Event: "error", Message: fmt.Sprintf(format, a...)})
+}
+
+func (o *JsonOutput) ReportError(category ErrorCategory, err error) {
+	o.trackError(category)
+	// Best-effort, see Output
+	_ = o.writeEvent(jsonEvent{Event: "error", Message: err.Error(), Category: category.String()})
+}
+
+func (o *JsonOutput) Warnf(format string, a ...interface{}) {
+	// Best-effort, see Output
+	_ = o.writeEvent(jsonEvent{Event: "warning", Message: fmt.Sprintf(format, a...)})
+}
+
+// The plan is a document of its own on stdout, in place of the results a run would write there
+func (o *JsonOutput) ReportPlan(plan Plan) error {
+	databaseName := plan.DatabaseName
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	doc := jsonPlan{
+		Database:        databaseName,
+		Scenario:        plan.Scenario,
+		Clients:         plan.Config.Clients,
+		TargetRate:      plan.Config.TargetRate,
+		DurationSeconds: plan.Config.Duration.Seconds(),
+		Transactions:    plan.Config.Transactions,
+		Init:            plan.Init,
+		Scripts:         make([]jsonPlannedScript, 0, len(plan.Scripts)),
+	}
+	for _, script := range plan.Scripts {
+		doc.Scripts = append(doc.Scripts, jsonPlannedScript{
+			Script:   script.Name,
+			Weight:   script.Weight,
+			Share:    plan.share(script),
+			Readonly: script.Readonly,
+		})
+	}
+	return newJsonEncoder(o.OutStream).Encode(doc)
+}
+
+func (o *JsonOutput) Close() error {
+	return nil
+}
+
+func (o *JsonOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+// Throughput and latency documents are the same; latencies are recorded either way, and still useful as
+// ballpark figures in throughput mode
+func (o *JsonOutput) writeResult(result Result) error {
+	doc := jsonResult{
+		Database:        result.DatabaseName,
+		Scenario:        result.Scenario,
+		ScenarioSlug:    result.ScenarioSlug(),
+		Succeeded:       result.TotalSucceeded(),
+		Failed:          result.TotalFailed(),
+		Rate:            result.TotalRate(),
+		DurationSeconds: result.Duration.Seconds(),
+		MeasuredRate:    result.MeasuredRate(),
+		NeobenchVersion: result.NeobenchVersion,
+		Neo4jVersion:    result.Neo4jVersion,
+		RunId:           result.RunId,
+		Tags:            result.Tags,
+		Warmup:          result.Warmup,
+		Interrupted:     result.Interrupted,
+		Scripts:         make([]jsonScriptResult, 0, len(result.Scripts)),
+		Errors:          make([]jsonErrorGroup, 0, len(result.FailedByErrorGroup)),
+	}
+
+	for _, script := range sortedScripts(result) {
+		doc.Scripts = append(doc.Scripts, o.scriptResult(script))
+	}
+	doc.Total = o.scriptResult(result.Total())
+	histogram := result.HistogramConfig()
+	doc.Histogram = jsonHistogramConfig{SignificantFigures: histogram.SignificantFigures,
+		MinMs: float64(histogram.Min.Microseconds()) / 1000.0, MaxMs: float64(histogram.Max.Microseconds()) / 1000.0}
+	if result.Config.Transactions > 0 {
+		doc.RequestedTransactions = result.Config.Transactions
+	} else {
+		doc.RequestedDurationSeconds = result.Config.Duration.Seconds()
+	}
+	if min, max, ok := result.SampleRateRange(); ok {
+		doc.MinRate, doc.MaxRate = &min, &max
+	}
+	if trimmed, ok := result.TrimmedSampleRate(o.TrimRates); ok && o.TrimRates > 0 {
+		mean, _ := result.TrimmedSampleRate(0)
+		doc.SampleMeanRate, doc.TrimmedRate, doc.TrimmedPercent = &mean, &trimmed, o.TrimRates
+	}
+	if len(result.SampleRates) >= 2 {
+		doc.RatePercentiles = make(map[string]float64, len(DefaultRatePercentiles))
+		for _, q := range DefaultRatePercentiles {
+			doc.RatePercentiles[percentileColumnName(q)], _ = result.SampleRatePercentile(q)
+		}
+	}
+	if c := result.Concurrency; c != nil {
+		doc.MeanConcurrency, doc.MaxConcurrency = &c.Mean, &c.Max
+	}
+	if p := result.PoolWait; p != nil {
+		doc.PoolWait = &jsonPoolWait{Size: p.Size, Transactions: p.Transactions,
+			MeanMs: float64(p.Mean.Microseconds()) / 1000.0, MaxMs: float64(p.Max.Microseconds()) / 1000.0}
+	}
+	if c := result.ClientCpu; c != nil {
+		doc.ClientCpu = &jsonClientCpu{Cpus: c.Cpus, CpuSeconds: c.Time.Seconds(), MeanUtilization: c.Mean, PeakUtilization: c.Peak}
+	}
+	for _, slow := range result.Slowest {
+		var start string
+		if !slow.Start.IsZero() {
+			start = slow.Start.UTC().Format(time.RFC3339Nano)
+		}
+		doc.Slowest = append(doc.Slowest, jsonSlowTransaction{Script: slow.ScriptName, Start: start,
+			LatencyMs: float64(slow.Latency.Microseconds()) / 1000.0, Succeeded: slow.Succeeded, Parameters: slow.Parameters})
+	}
+	if stats := result.Process; stats != nil {
+		doc.Process = &jsonProcessStats{
+			HeapAllocBytes:  stats.HeapAlloc,
+			TotalAllocBytes: stats.TotalAlloc,
+			NumGC:           stats.NumGC,
+			PauseTotal:      float64(stats.PauseTotal) / float64(time.Millisecond),
+			MaxPause:        float64(stats.MaxPause) / float64(time.Millisecond),
+		}
+	}
+
+	for name, group := range result.FailedByErrorGroup {
+		doc.Errors = append(doc.Errors, jsonErrorGroup{
+			Group:   name,
+			Count:   group.Count,
+			Example: fmt.Sprintf("%s", group.FirstFailure),
+		})
+	}
+	sort.Slice(doc.Errors, func(i, j int) bool { return doc.Errors[i].Group < doc.Errors[j].Group })
+	doc.ErrorsByCategory = jsonErrorCategories(result.FailedByCategory())
+	if len(o.reportedByCategory) > 0 {
+		doc.ReportedErrorsByCategory = jsonErrorCategories(o.reportedByCategory)
+	}
+
+	return newJsonEncoder(o.OutStream).Encode(doc)
+}
+
+func jsonErrorCategories(counts map[ErrorCategory]int64) map[string]int64 {
+	doc := make(map[string]int64)
+	for category, n := range counts {
+		if n > 0 {
+			doc[category.String()] = n
+		}
+	}
+	return doc
+}
+
+func (o *JsonOutput) scriptResult(script *ScriptResult) jsonScriptResult {
+	doc := jsonScriptResult{
+		Script:     script.ScriptName,
+		Succeeded:  script.Succeeded,
+		Failed:     script.Failed,
+		Rate:       script.Rate,
+		Retries:    script.Retries,
+		RecordRate: script.RecordRate,
+		ByteRate:   script.ByteRate,
+	}
+	doc.Latency = o.latency(script.Latencies)
+	if script.Retries > 0 && script.WithoutRetries != nil {
+		doc.LatencyWithoutRetries = o.latency(script.WithoutRetries)
+	}
+	if len(script.Phases) > 0 {
+		doc.Phases = make(map[string]*jsonLatency, len(script.Phases))
+		for phase, histo := range script.Phases {
+			doc.Phases[phase] = o.latency(histo)
+		}
+	}
+	if len(script.AccessModes) > 0 {
+		doc.AccessModes = make(map[string]*jsonLatency, len(script.AccessModes))
+		for mode, histo := range script.AccessModes {
+			doc.AccessModes[mode] = o.latency(histo)
+		}
+	}
+	return doc
+}
+
+// Nil if there are no latencies, so they're null rather than zeros that look like a measurement
+func (o *JsonOutput) latency(histo *hdrhistogram.Histogram) *jsonLatency {
+	if histo.TotalCount() == 0 {
+		return nil
+	}
+	latency := &jsonLatency{
+		Min:         float64(histo.Min()) / 1000.0,
+		Mean:        histo.Mean() / 1000.0,
+		Max:         float64(histo.Max()) / 1000.0,
+		StdDev:      histo.StdDev() / 1000.0,
+		Percentiles: make(map[string]float64),
+	}
+	for _, q := range o.percentiles() {
+		latency.Percentiles[percentileColumnName(q)] = float64(valueAtPercentile(histo, q)) / 1000.0
+	}
+	return latency
+}
+
+func (o *JsonOutput) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultJsonPercentiles
+	}
+	return o.Percentiles
+}
+
+func (o *JsonOutput) writeEvent(event jsonEvent) error {
+	return newJsonEncoder(o.ErrStream).Encode(event)
+}
+
+// JSON encoder that leaves things like "<default>" alone, we're not writing into HTML
+func newJsonEncoder(w io.Writer) *json.Encoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc
+}
+
+// Scripts in a result ordered by name, so output is stable between runs
+func sortedScripts(result Result) []*ScriptResult {
+	scripts := make([]*ScriptResult, 0, len(result.Scripts))
+	for _, script := range result.Scripts {
+		scripts = append(scripts, script)
+	}
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].ScriptName < scripts[j].ScriptName })
+	return scripts
+}
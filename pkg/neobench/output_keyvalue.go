@@ -0,0 +1,152 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Writes each result to stdout as `key: value` lines, one metric per line, for shell scripts to pick apart
+// with grep, cut and awk:
+//
+//	database: neo4j
+//	scenario: -w builtin:tpcb-like -c 1
+//	warmup: false
+//	succeeded: 1000
+//	failed: 0
+//	tps: 1234.000
+//	mean_ms: 1.300
+//	p50_ms: 1.200
+//	p90_ms: 4.100
+//	p99_ms: 9.800
+//	p99900_ms: 11.900
+//	max_ms: 12.100
+//
+// Keys are lowercase and stable between versions, new ones are only ever added; rates and latencies are across
+// all scripts, latencies in milliseconds, both with Precision decimals, and latencies are empty when there are
+// none. Optional keys, run_id and a tag_<key> per tag, come only when the run has them. Results are separated
+// by a blank line. Progress and error details go to stderr.
+type KeyvalueOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Latency percentiles to report, defaults to DefaultKeyvaluePercentiles
+	Percentiles []float64
+	// Decimal places in the rate and latencies, defaults to DefaultPrecision
+	Precision *int
+	ProgressReporter
+	// Results written so far, each one after the first is led by a blank line
+	results int
+	errorTracker
+}
+
+var DefaultKeyvaluePercentiles = []float64{50, 90, 99, 99.9}
+
+func (o *KeyvalueOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *KeyvalueOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *KeyvalueOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+// Only final results are written, so each key appears once per run
+func (o *KeyvalueOutput) ReportInterval(sample IntervalResult) error {
+	return nil
+}
+
+func (o *KeyvalueOutput) ReportThroughput(result Result) error {
+	return o.writeResult(result)
+}
+
+func (o *KeyvalueOutput) ReportLatency(result Result) error {
+	return o.writeResult(result)
+}
+
+func (o *KeyvalueOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *KeyvalueOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *KeyvalueOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *KeyvalueOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *KeyvalueOutput) Close() error {
+	return nil
+}
+
+func (o *KeyvalueOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+func (o *KeyvalueOutput) writeResult(result Result) error {
+	databaseName := result.DatabaseName
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	total, places := result.Total(), decimalPlaces(o.Precision)
+	latency := func(micros float64) string {
+		if total.Latencies.TotalCount() == 0 {
+			return ""
+		}
+		return formatDecimal(micros/1000.0, places)
+	}
+
+	s := strings.Builder{}
+	if o.results > 0 {
+		s.WriteString("\n")
+	}
+	o.results++
+	line := func(key, value string) {
+		// A value is never more than one line, so a line is always one key
+		s.WriteString(key + ": " + strings.NewReplacer("\r", " ", "\n", " ").Replace(value) + "\n")
+	}
+	line("database", databaseName)
+	line("scenario", result.Scenario)
+	if result.RunId != "" {
+		line("run_id", result.RunId)
+	}
+	for _, key := range sortedTagKeys(result.Tags) {
+		line("tag_"+key, result.Tags[key])
+	}
+	line("warmup", fmt.Sprintf("%t", result.Warmup))
+	line("succeeded", fmt.Sprintf("%d", result.TotalSucceeded()))
+	line("failed", fmt.Sprintf("%d", result.TotalFailed()))
+	line("tps", formatDecimal(result.TotalRate(), places))
+	line("mean_ms", latency(total.Latencies.Mean()))
+	for _, q := range o.percentiles() {
+		line(percentileColumnName(q)+"_ms", latency(float64(valueAtPercentile(total.Latencies, q))))
+	}
+	line("max_ms", latency(float64(total.Latencies.Max())))
+	if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
+		return err
+	}
+	if result.TotalFailed() == 0 {
+		return nil
+	}
+	errReport := strings.Builder{}
+	writeErrorReport(result, o.reportedByCategory, &errReport, false)
+	_, err := fmt.Fprint(o.ErrStream, errReport.String())
+	return err
+}
+
+func (o *KeyvalueOutput) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultKeyvaluePercentiles
+	}
+	return o.Percentiles
+}
@@ -0,0 +1,177 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Writes results to stdout as a GitHub-flavored Markdown table, for pasting into issues and pull requests.
+// The header is written with the first result, and every later result adds rows to the same table.
+// Progress and error details go to stderr.
+type MarkdownOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Latency percentiles to report, defaults to DefaultMarkdownPercentiles
+	Percentiles []float64
+	// Unit to show latencies in, defaults to milliseconds; with LatencyAuto, the first result picks
+	// the unit for the whole table
+	LatencyUnit LatencyUnit
+	// Decimal places in latencies and rates, defaults to DefaultPrecision
+	Precision *int
+	ProgressReporter
+	// Column widths and latency unit of the table written so far, set when the header is written
+	widths []int
+	unit   LatencyUnit
+	errorTracker
+}
+
+var DefaultMarkdownPercentiles = []float64{50, 75, 95, 99, 99.9}
+
+func (o *MarkdownOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *MarkdownOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *MarkdownOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+// Samples would drown out the final results, and a table is no good for a time series anyway
+func (o *MarkdownOutput) ReportInterval(sample IntervalResult) error {
+	return nil
+}
+
+// Latencies are recorded in throughput mode as well, so this writes the same rows ReportLatency does
+func (o *MarkdownOutput) ReportThroughput(result Result) error {
+	return o.ReportLatency(result)
+}
+
+func (o *MarkdownOutput) ReportLatency(result Result) error {
+	if o.widths == nil {
+		o.unit = resolveLatencyUnit(o.LatencyUnit, result)
+	}
+	header := []string{"Scenario", "Script", "Samples", "Rate (tps)", fmt.Sprintf("Mean (%s)", o.unit.Name)}
+	for _, q := range o.percentiles() {
+		header = append(header, fmt.Sprintf("P%s (%s)", strconv.FormatFloat(q, 'f', -1, 64), o.unit.Name))
+	}
+
+	scripts := sortedScripts(result)
+	// With several scripts, add a row for the workload as a whole
+	if len(scripts) > 1 {
+		scripts = append(scripts, result.Total())
+	}
+	places := decimalPlaces(o.Precision)
+	rows := make([][]string, 0, len(scripts))
+	for _, script := range scripts {
+		histo := script.Latencies
+		row := []string{
+			escapeMarkdownCell(result.Scenario),
+			escapeMarkdownCell(script.ScriptName),
+			fmt.Sprintf("%d", histo.TotalCount()),
+			formatDecimal(script.Rate, places),
+			formatDecimal(histo.Mean()/o.unit.Micros, places),
+		}
+		for _, q := range o.percentiles() {
+			row = append(row, formatDecimal(float64(valueAtPercentile(histo, q))/o.unit.Micros, places))
+		}
+		rows = append(rows, row)
+	}
+
+	s := strings.Builder{}
+	if o.widths == nil {
+		o.widths = make([]int, len(header))
+		for i, name := range header {
+			o.widths[i] = len(name)
+		}
+		for _, row := range rows {
+			for i, cell := range row {
+				if len(cell) > o.widths[i] {
+					o.widths[i] = len(cell)
+				}
+			}
+		}
+		o.writeRow(&s, header)
+		separators := make([]string, len(header))
+		for i, width := range o.widths {
+			// Text columns left-aligned, numbers right-aligned
+			if i < 2 {
+				separators[i] = strings.Repeat("-", width)
+			} else {
+				separators[i] = strings.Repeat("-", width-1) + ":"
+			}
+		}
+		s.WriteString("| " + strings.Join(separators, " | ") + " |\n")
+	}
+	for _, row := range rows {
+		o.writeRow(&s, row)
+	}
+
+	if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
+		return err
+	}
+	if result.TotalFailed() == 0 {
+		return nil
+	}
+	errReport := strings.Builder{}
+	writeErrorReport(result, o.reportedByCategory, &errReport, false)
+	_, err := fmt.Fprint(o.ErrStream, errReport.String())
+	return err
+}
+
+func (o *MarkdownOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *MarkdownOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *MarkdownOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *MarkdownOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *MarkdownOutput) Close() error {
+	return nil
+}
+
+func (o *MarkdownOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+// Pads cells to the column widths, text to the left and numbers to the right; cells wider than the
+// column just push the rest of the row along, which still renders fine
+func (o *MarkdownOutput) writeRow(s *strings.Builder, cells []string) {
+	padded := make([]string, len(cells))
+	for i, cell := range cells {
+		if i < 2 {
+			padded[i] = fmt.Sprintf("%-*s", o.widths[i], cell)
+		} else {
+			padded[i] = fmt.Sprintf("%*s", o.widths[i], cell)
+		}
+	}
+	s.WriteString("| " + strings.Join(padded, " | ") + " |\n")
+}
+
+func (o *MarkdownOutput) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultMarkdownPercentiles
+	}
+	return o.Percentiles
+}
+
+// Pipes end cells even inside code spans, so they need escaping
+func escapeMarkdownCell(v string) string {
+	return strings.Replace(v, "|", `\|`, -1)
+}
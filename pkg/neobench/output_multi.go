@@ -0,0 +1,84 @@
+package neobench
+
+// Fans every call out to several outputs, eg. to write CSV results to one file and a JSON summary to another
+// in the same run. Each output is called even if an earlier one failed; methods return the first error.
+type MultiOutput struct {
+	Outputs []Output
+}
+
+func (o *MultiOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return o.each(func(out Output) error { return out.BenchmarkStart(databaseName, url, scenario) })
+}
+
+func (o *MultiOutput) ReportProgress(report ProgressReport) {
+	for _, out := range o.Outputs {
+		out.ReportProgress(report)
+	}
+}
+
+func (o *MultiOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	return o.each(func(out Output) error { return out.ReportWorkloadProgress(completeness, checkpoint) })
+}
+
+func (o *MultiOutput) ReportInterval(sample IntervalResult) error {
+	return o.each(func(out Output) error { return out.ReportInterval(sample) })
+}
+
+func (o *MultiOutput) ReportThroughput(result Result) error {
+	return o.each(func(out Output) error { return out.ReportThroughput(result) })
+}
+
+func (o *MultiOutput) ReportLatency(result Result) error {
+	return o.each(func(out Output) error { return out.ReportLatency(result) })
+}
+
+func (o *MultiOutput) Errorf(format string, a ...interface{}) {
+	for _, out := range o.Outputs {
+		out.Errorf(format, a...)
+	}
+}
+
+func (o *MultiOutput) ReportError(category ErrorCategory, err error) {
+	for _, out := range o.Outputs {
+		out.ReportError(category, err)
+	}
+}
+
+func (o *MultiOutput) Warnf(format string, a ...interface{}) {
+	for _, out := range o.Outputs {
+		out.Warnf(format, a...)
+	}
+}
+
+func (o *MultiOutput) ErrorsReported() bool {
+	for _, out := range o.Outputs {
+		if out.ErrorsReported() {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *MultiOutput) ReportPlan(plan Plan) error {
+	return o.each(func(out Output) error { return out.ReportPlan(plan) })
+}
+
+func (o *MultiOutput) Close() error {
+	return o.each(func(out Output) error { return out.Close() })
+}
+
+func (o *MultiOutput) Reset() {
+	for _, out := range o.Outputs {
+		ResetOutput(out)
+	}
+}
+
+func (o *MultiOutput) each(call func(out Output) error) error {
+	var firstErr error
+	for _, out := range o.Outputs {
+		if err := call(out); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,14 @@
+package neobench
+
+// Drops progress reports and leaves everything else to the wrapped Output, for when progress only clutters
+// logs, eg. on CI. Unlike QuietOutput this works with any format, and keeps the banner on stderr.
+type NoProgressOutput struct {
+	Output
+}
+
+func (o *NoProgressOutput) ReportProgress(report ProgressReport) {
+}
+
+func (o *NoProgressOutput) Reset() {
+	ResetOutput(o.Output)
+}
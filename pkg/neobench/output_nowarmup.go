@@ -0,0 +1,30 @@
+package neobench
+
+// Formats that tell warmup results apart from those of the measured run, see Result.Warmup; auto is
+// interactive or csv
+var WarmupFormats = []string{"auto", "interactive", "csv", "tsv", "quiet", "json", "protobuf", "keyvalue"}
+
+// Drops the warmup results and leaves everything else to the wrapped Output, for formats that would have
+// them pass for results of the measured run, eg. a second set of Prometheus metrics. Samples taken during the
+// warmup are passed on like any others.
+type NoWarmupOutput struct {
+	Output
+}
+
+func (o *NoWarmupOutput) ReportThroughput(result Result) error {
+	if result.Warmup {
+		return nil
+	}
+	return o.Output.ReportThroughput(result)
+}
+
+func (o *NoWarmupOutput) ReportLatency(result Result) error {
+	if result.Warmup {
+		return nil
+	}
+	return o.Output.ReportLatency(result)
+}
+
+func (o *NoWarmupOutput) Reset() {
+	ResetOutput(o.Output)
+}
@@ -0,0 +1,105 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Writes exactly one line per result to stdout, for status boards, `watch` and grepping logs:
+//
+//	<scenario> tps=<rate> p50=<latency>ms p99=<latency>ms err=<failures> db=<database>
+//
+// The fields, their order and their units are part of the format, so parsers can rely on them; new fields
+// only ever go at the end. Lines are keyed by the scenario, as a ScenarioSlug so it's one word, since that is
+// what tells runs against the same database apart. Rate is total transactions per second, latencies are across
+// all scripts in milliseconds, both with Precision decimals, and failures is the number of failed transactions;
+// a run_id=<id> field follows when the run has one. Progress and error details go to stderr.
+type OnelineOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Decimal places in the rate and latencies, defaults to DefaultPrecision
+	Precision *int
+	ProgressReporter
+	errorTracker
+}
+
+func (o *OnelineOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *OnelineOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *OnelineOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+// Only final results get a line, so there's one line per run
+func (o *OnelineOutput) ReportInterval(sample IntervalResult) error {
+	return nil
+}
+
+func (o *OnelineOutput) ReportThroughput(result Result) error {
+	return o.writeLine(result)
+}
+
+func (o *OnelineOutput) ReportLatency(result Result) error {
+	return o.writeLine(result)
+}
+
+func (o *OnelineOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *OnelineOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *OnelineOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *OnelineOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *OnelineOutput) Close() error {
+	return nil
+}
+
+func (o *OnelineOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+func (o *OnelineOutput) writeLine(result Result) error {
+	scenario, databaseName := result.ScenarioSlug(), result.DatabaseName
+	if scenario == "" {
+		scenario = "<unknown>"
+	}
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	latencies, places := result.Total().Latencies, decimalPlaces(o.Precision)
+	var runId string
+	if result.RunId != "" {
+		runId = " run_id=" + result.RunId
+	}
+	_, err := fmt.Fprintf(o.OutStream, "%s tps=%s p50=%sms p99=%sms err=%d db=%s%s\n", scenario,
+		formatDecimal(result.TotalRate(), places), formatDecimal(float64(valueAtPercentile(latencies, 50))/1000.0, places),
+		formatDecimal(float64(valueAtPercentile(latencies, 99))/1000.0, places), result.TotalFailed(), databaseName, runId)
+	if err != nil {
+		return err
+	}
+	if result.TotalFailed() == 0 {
+		return nil
+	}
+	s := strings.Builder{}
+	writeErrorReport(result, o.reportedByCategory, &s, false)
+	_, err = fmt.Fprint(o.ErrStream, s.String())
+	return err
+}
@@ -0,0 +1,415 @@
+package neobench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long OtlpOutput keeps trying to push to the collector, retries included, before giving up on it
+const DefaultOtlpTimeout = 10 * time.Second
+
+// How many times OtlpOutput tries again after the collector failed in a way worth retrying, see otlpRetryable
+const DefaultOtlpRetries = 5
+
+// Most results OtlpOutput posts in one request, so many results don't make for a request the collector turns away
+const DefaultOtlpBatchSize = 100
+
+// Wait before the first retry, doubled for each one after, unless the collector says how long to wait
+const otlpInitialBackoff = 500 * time.Millisecond
+
+// How data points of counters and histograms relate to the ones before them, see OtlpOutput.Temporality
+var OtlpTemporalities = []string{"cumulative", "delta"}
+
+// Fails unless OtlpOutput supports temporality; empty means the default, cumulative
+func ValidateOtlpTemporality(temporality string) error {
+	if temporality == "" || containsString(OtlpTemporalities, temporality) {
+		return nil
+	}
+	return fmt.Errorf("unknown otlp temporality: %s, supported temporalities are %s", temporality, strings.Join(OtlpTemporalities, ", "))
+}
+
+// Upper bounds of the latency histogram's buckets, in milliseconds; the last bucket counts everything above
+var DefaultOtlpBoundaries = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000, 30000, 60000}
+
+// Pushes the final results as OpenTelemetry metrics to an OTLP/HTTP endpoint once the wrapped Output is
+// closed, so they end up in the same backend as the metrics of the services being benchmarked: a
+// neobench.throughput gauge, a neobench.latency histogram in milliseconds and a neobench.errors counter, with
+// a data point per script. Each result is a resource of its own with the scenario as an attribute. Metrics are
+// posted as OTLP JSON, which collectors accept the same as protobuf, in batches of results, each retried with
+// backoff when the collector is unavailable or asks to be given time. Failing to push is warned about rather
+// than failing the run. Warmup results aren't pushed.
+type OtlpOutput struct {
+	Output
+	// Base url of the collector, like http://localhost:4318; /v1/metrics is added unless it's already there
+	Endpoint string
+	// Resource attributes of every result, see TaggingOutput
+	Tags map[string]string
+	// Upper bounds of the latency histogram buckets in milliseconds, defaults to DefaultOtlpBoundaries
+	Boundaries []float64
+	// "cumulative" or "delta", see OtlpTemporalities; defaults to cumulative. Each result covers its run from the
+	// start, so the figures are the same either way, this is for backends that only take one of them
+	Temporality string
+	// How long to keep trying to push, retries included, defaults to DefaultOtlpTimeout
+	Timeout time.Duration
+	// How many times to retry a batch, defaults to DefaultOtlpRetries; negative doesn't retry
+	Retries int
+	// Most results per request, defaults to DefaultOtlpBatchSize
+	BatchSize int
+	// Used to push metrics, defaults to http.DefaultClient
+	Client *http.Client
+	// Time of data points of results that don't say when they ended, defaults to time.Now
+	now func() time.Time
+	// Wait before the first retry, defaults to otlpInitialBackoff
+	backoff time.Duration
+	// Final results, pushed on Close
+	results []Result
+}
+
+// Fails unless endpoint is an http or https url
+func ValidateOtlpEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid otlp endpoint: %s, endpoints are http:// or https:// urls", endpoint)
+	}
+	return nil
+}
+
+// OTLP JSON is the protobuf JSON mapping of ExportMetricsServiceRequest, which writes 64 bit integers as strings
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Unit        string         `json:"unit"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	// One of these is set, by whether the metric counts or measures
+	AsDouble *float64 `json:"asDouble,omitempty"`
+	AsInt    string   `json:"asInt,omitempty"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	Count             string         `json:"count"`
+	Sum               float64        `json:"sum"`
+	BucketCounts      []string       `json:"bucketCounts"`
+	ExplicitBounds    []float64      `json:"explicitBounds"`
+	Min               float64        `json:"min"`
+	Max               float64        `json:"max"`
+}
+
+// AggregationTemporality of the OTLP protocol
+const (
+	otlpAggregationTemporalityDelta      = 1
+	otlpAggregationTemporalityCumulative = 2
+)
+
+func (o *OtlpOutput) ReportThroughput(result Result) error {
+	o.record(result)
+	return o.Output.ReportThroughput(result)
+}
+
+func (o *OtlpOutput) ReportLatency(result Result) error {
+	o.record(result)
+	return o.Output.ReportLatency(result)
+}
+
+func (o *OtlpOutput) record(result Result) {
+	if !result.Warmup {
+		o.results = append(o.results, result)
+	}
+}
+
+func (o *OtlpOutput) Close() error {
+	err := o.Output.Close()
+	if len(o.results) > 0 {
+		if pushErr := o.push(); pushErr != nil {
+			o.Output.Warnf("failed to export metrics to %s: %s", o.Endpoint, pushErr)
+		}
+	}
+	return err
+}
+
+func (o *OtlpOutput) Reset() {
+	ResetOutput(o.Output)
+}
+
+func (o *OtlpOutput) push() error {
+	timeout := o.Timeout
+	if timeout <= 0 {
+		timeout = DefaultOtlpTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	batchSize := o.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultOtlpBatchSize
+	}
+	for start := 0; start < len(o.results); start += batchSize {
+		end := start + batchSize
+		if end > len(o.results) {
+			end = len(o.results)
+		}
+		body, err := json.Marshal(o.request(o.results[start:end]))
+		if err != nil {
+			return err
+		}
+		if err := o.postRetrying(ctx, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Posts body, trying again with exponential backoff while the collector fails in a way that's worth retrying,
+// until the retries or ctx run out
+func (o *OtlpOutput) postRetrying(ctx context.Context, body []byte) error {
+	retries := o.Retries
+	if retries == 0 {
+		retries = DefaultOtlpRetries
+	}
+	backoff := o.backoff
+	if backoff <= 0 {
+		backoff = otlpInitialBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		wait, err := o.post(ctx, body)
+		if err == nil || wait < 0 || attempt >= retries {
+			return err
+		}
+		if wait == 0 {
+			wait = backoff << uint(attempt)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Posts body once; on failure, wait is negative if it isn't worth retrying, otherwise how long the collector
+// asked to be given before the next try, zero if it didn't say
+func (o *OtlpOutput) post(ctx context.Context, body []byte) (wait time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, otlpMetricsUrl(o.Endpoint), bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		// The collector may not be up yet, unless it's time that ran out
+		if ctx.Err() != nil {
+			return -1, err
+		}
+		return 0, err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode >= 200 && res.StatusCode <= 299 {
+		return 0, nil
+	}
+	err = fmt.Errorf("collector answered %s", res.Status)
+	if !otlpRetryable(res.StatusCode) {
+		return -1, err
+	}
+	if seconds, parseErr := strconv.Atoi(res.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, err
+	}
+	return 0, err
+}
+
+// Statuses the OTLP specification says are worth retrying: the collector is overloaded or briefly unavailable
+func otlpRetryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func otlpMetricsUrl(endpoint string) string {
+	if strings.HasSuffix(endpoint, "/v1/metrics") {
+		return endpoint
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/v1/metrics"
+}
+
+func (o *OtlpOutput) request(results []Result) otlpRequest {
+	request := otlpRequest{ResourceMetrics: make([]otlpResourceMetrics, 0, len(results))}
+	for _, result := range results {
+		attributes := []otlpKeyValue{otlpString("service.name", "neobench"), otlpString("neobench.scenario", result.Scenario)}
+		if result.RunId != "" {
+			attributes = append(attributes, otlpString("neobench.run_id", result.RunId))
+		}
+		for _, key := range sortedTagKeys(o.Tags) {
+			attributes = append(attributes, otlpString(key, o.Tags[key]))
+		}
+		request.ResourceMetrics = append(request.ResourceMetrics, otlpResourceMetrics{
+			Resource: otlpResource{Attributes: attributes},
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: "neobench", Version: result.NeobenchVersion},
+				Metrics: o.metrics(result),
+			}},
+		})
+	}
+	return request
+}
+
+func (o *OtlpOutput) metrics(result Result) []otlpMetric {
+	databaseName := result.DatabaseName
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	var start string
+	if !result.StartTime.IsZero() {
+		start = otlpTime(result.StartTime)
+	}
+	end := result.EndTime
+	if end.IsZero() {
+		end = o.currentTime()
+	}
+
+	temporality := otlpAggregationTemporalityCumulative
+	if o.Temporality == "delta" {
+		temporality = otlpAggregationTemporalityDelta
+	}
+	throughput := &otlpGauge{}
+	errorCount := &otlpSum{AggregationTemporality: temporality, IsMonotonic: true}
+	latency := &otlpHistogram{AggregationTemporality: temporality}
+	for _, script := range sortedScripts(result) {
+		attributes := []otlpKeyValue{otlpString("neobench.database", databaseName), otlpString("neobench.script", script.ScriptName)}
+		rate := script.Rate
+		throughput.DataPoints = append(throughput.DataPoints, otlpNumberDataPoint{
+			Attributes: attributes, TimeUnixNano: otlpTime(end), AsDouble: &rate})
+		errorCount.DataPoints = append(errorCount.DataPoints, otlpNumberDataPoint{
+			Attributes: attributes, StartTimeUnixNano: start, TimeUnixNano: otlpTime(end), AsInt: strconv.FormatInt(script.Failed, 10)})
+		if script.Latencies.TotalCount() > 0 {
+			point := o.histogram(script.Latencies)
+			point.Attributes, point.StartTimeUnixNano, point.TimeUnixNano = attributes, start, otlpTime(end)
+			latency.DataPoints = append(latency.DataPoints, point)
+		}
+	}
+	metrics := []otlpMetric{
+		{Name: "neobench.throughput", Description: "Transactions per second, succeeded and failed", Unit: "{transaction}/s", Gauge: throughput},
+		{Name: "neobench.errors", Description: "Failed transactions", Unit: "{transaction}", Sum: errorCount},
+	}
+	if len(latency.DataPoints) > 0 {
+		metrics = append(metrics, otlpMetric{Name: "neobench.latency", Description: "Latency of successful transactions", Unit: "ms", Histogram: latency})
+	}
+	return metrics
+}
+
+// Buckets are counted from the histogram's own, each by the highest latency it holds, so a bucket of ours gets
+// all of one of theirs or none of it
+func (o *OtlpOutput) histogram(histo *hdrhistogram.Histogram) otlpHistogramDataPoint {
+	boundaries := o.boundaries()
+	counts := make([]int64, len(boundaries)+1)
+	for _, bar := range histo.Distribution() {
+		if bar.Count == 0 {
+			continue
+		}
+		i := sort.SearchFloat64s(boundaries, float64(bar.To)/1000.0)
+		counts[i] += bar.Count
+	}
+	bucketCounts := make([]string, len(counts))
+	for i, count := range counts {
+		bucketCounts[i] = strconv.FormatInt(count, 10)
+	}
+	return otlpHistogramDataPoint{
+		Count:          strconv.FormatInt(histo.TotalCount(), 10),
+		Sum:            histo.Mean() * float64(histo.TotalCount()) / 1000.0,
+		BucketCounts:   bucketCounts,
+		ExplicitBounds: boundaries,
+		Min:            float64(histo.Min()) / 1000.0,
+		Max:            float64(histo.Max()) / 1000.0,
+	}
+}
+
+func (o *OtlpOutput) boundaries() []float64 {
+	if len(o.Boundaries) == 0 {
+		return DefaultOtlpBoundaries
+	}
+	return o.Boundaries
+}
+
+func (o *OtlpOutput) currentTime() time.Time {
+	if o.now == nil {
+		return time.Now()
+	}
+	return o.now()
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: value}}
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,95 @@
+package neobench
+
+import (
+	"io"
+	"time"
+)
+
+// Draws progress as a single bar on stderr that is redrawn in place, like interactive output does, and leaves
+// everything else to the wrapped Output; this way a terminal gets a bar whatever format results are in, eg. with
+// `neobench ... > results.csv`. Only makes sense when ErrStream is a terminal. Anything else moves the bar to a
+// line of its own first, so what the wrapped Output writes to stderr isn't appended to it.
+type ProgressBarOutput struct {
+	Output
+	ErrStream io.Writer
+	// Used to rate-limit redrawing, always at progressBarRedrawInterval
+	ProgressReporter
+	// Length of the bar drawn last, zero if it's been moved past
+	drawn int
+}
+
+func (o *ProgressBarOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	o.endProgressBar()
+	return o.Output.BenchmarkStart(databaseName, url, scenario)
+}
+
+func (o *ProgressBarOutput) ReportProgress(report ProgressReport) {
+	now := time.Now()
+	due, newStep := o.due(report, progressBarRedrawInterval, now)
+	if !due {
+		return
+	}
+	if newStep {
+		o.endProgressBar()
+	}
+	o.drawn = drawProgressBar(o.ErrStream, report, o.timer.describe(report, now), o.drawn)
+}
+
+func (o *ProgressBarOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	o.endProgressBar()
+	return o.Output.ReportWorkloadProgress(completeness, checkpoint)
+}
+
+func (o *ProgressBarOutput) ReportInterval(sample IntervalResult) error {
+	o.endProgressBar()
+	return o.Output.ReportInterval(sample)
+}
+
+func (o *ProgressBarOutput) ReportThroughput(result Result) error {
+	o.endProgressBar()
+	return o.Output.ReportThroughput(result)
+}
+
+func (o *ProgressBarOutput) ReportLatency(result Result) error {
+	o.endProgressBar()
+	return o.Output.ReportLatency(result)
+}
+
+func (o *ProgressBarOutput) ReportPlan(plan Plan) error {
+	o.endProgressBar()
+	return o.Output.ReportPlan(plan)
+}
+
+func (o *ProgressBarOutput) Errorf(format string, a ...interface{}) {
+	o.endProgressBar()
+	o.Output.Errorf(format, a...)
+}
+
+func (o *ProgressBarOutput) ReportError(category ErrorCategory, err error) {
+	o.endProgressBar()
+	o.Output.ReportError(category, err)
+}
+
+func (o *ProgressBarOutput) Warnf(format string, a ...interface{}) {
+	o.endProgressBar()
+	o.Output.Warnf(format, a...)
+}
+
+func (o *ProgressBarOutput) Close() error {
+	o.endProgressBar()
+	return o.Output.Close()
+}
+
+func (o *ProgressBarOutput) Reset() {
+	o.endProgressBar()
+	o.resetProgress()
+	ResetOutput(o.Output)
+}
+
+func (o *ProgressBarOutput) endProgressBar() {
+	if o.drawn > 0 {
+		// Best-effort, see Output
+		_, _ = io.WriteString(o.ErrStream, "\n")
+		o.drawn = 0
+	}
+}
@@ -0,0 +1,144 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Writes results in the Prometheus text exposition format to stdout, eg. for node_exporter's textfile collector.
+// Progress goes to stderr, so stdout only ever contains metrics.
+type PrometheusOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Latency percentiles to report, defaults to DefaultPrometheusPercentiles
+	Percentiles []float64
+	ProgressReporter
+	errorTracker
+}
+
+var DefaultPrometheusPercentiles = []float64{50, 75, 95, 99, 99.9, 99.999}
+
+func (o *PrometheusOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *PrometheusOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *PrometheusOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+// The exposition format is a snapshot rather than a time series, so only the final results are written
+func (o *PrometheusOutput) ReportInterval(sample IntervalResult) error {
+	return nil
+}
+
+func (o *PrometheusOutput) ReportThroughput(result Result) error {
+	s := strings.Builder{}
+	o.writeThroughputMetrics(result, &s)
+	_, err := fmt.Fprint(o.OutStream, s.String())
+	return err
+}
+
+func (o *PrometheusOutput) ReportLatency(result Result) error {
+	s := strings.Builder{}
+	o.writeThroughputMetrics(result, &s)
+
+	writeMetricHeader(&s, "neobench_latency_ms", "summary", "Latency of successful transactions in milliseconds.")
+	for _, script := range sortedScripts(result) {
+		labels := prometheusLabels(result, script)
+		histo := script.Latencies
+		for _, q := range o.percentiles() {
+			quantile := strconv.FormatFloat(q/100, 'f', -1, 64)
+			s.WriteString(fmt.Sprintf("neobench_latency_ms{%s,quantile=\"%s\"} %.3f\n",
+				labels, quantile, float64(valueAtPercentile(histo, q))/1000.0))
+		}
+		s.WriteString(fmt.Sprintf("neobench_latency_ms_sum{%s} %.3f\n", labels, histo.Mean()*float64(histo.TotalCount())/1000.0))
+		s.WriteString(fmt.Sprintf("neobench_latency_ms_count{%s} %d\n", labels, histo.TotalCount()))
+	}
+
+	_, err := fmt.Fprint(o.OutStream, s.String())
+	return err
+}
+
+func (o *PrometheusOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *PrometheusOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *PrometheusOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+func (o *PrometheusOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.OutStream, plan, false)
+}
+
+func (o *PrometheusOutput) Close() error {
+	return nil
+}
+
+func (o *PrometheusOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+func (o *PrometheusOutput) writeThroughputMetrics(result Result, s *strings.Builder) {
+	scripts := sortedScripts(result)
+
+	writeMetricHeader(s, "neobench_transactions_per_second", "gauge", "Transactions executed per second, both succeeded and failed.")
+	for _, script := range scripts {
+		s.WriteString(fmt.Sprintf("neobench_transactions_per_second{%s} %.3f\n", prometheusLabels(result, script), script.Rate))
+	}
+	writeMetricHeader(s, "neobench_succeeded_transactions", "gauge", "Transactions that succeeded.")
+	for _, script := range scripts {
+		s.WriteString(fmt.Sprintf("neobench_succeeded_transactions{%s} %d\n", prometheusLabels(result, script), script.Succeeded))
+	}
+	writeMetricHeader(s, "neobench_failed_transactions", "gauge", "Transactions that failed.")
+	for _, script := range scripts {
+		s.WriteString(fmt.Sprintf("neobench_failed_transactions{%s} %d\n", prometheusLabels(result, script), script.Failed))
+	}
+}
+
+func (o *PrometheusOutput) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultPrometheusPercentiles
+	}
+	return o.Percentiles
+}
+
+func writeMetricHeader(s *strings.Builder, name, metricType, help string) {
+	s.WriteString(fmt.Sprintf("# HELP %s %s\n", name, help))
+	s.WriteString(fmt.Sprintf("# TYPE %s %s\n", name, metricType))
+}
+
+func prometheusLabels(result Result, script *ScriptResult) string {
+	labels := fmt.Sprintf("scenario=\"%s\",database=\"%s\",script=\"%s\"",
+		escapePrometheusLabel(result.Scenario),
+		escapePrometheusLabel(result.DatabaseName),
+		escapePrometheusLabel(script.ScriptName))
+	if result.RunId != "" {
+		labels += fmt.Sprintf(",run_id=\"%s\"", escapePrometheusLabel(result.RunId))
+	}
+	// ValidateTags has made sure the keys are label names
+	for _, key := range sortedTagKeys(result.Tags) {
+		labels += fmt.Sprintf(",%s=\"%s\"", key, escapePrometheusLabel(result.Tags[key]))
+	}
+	return labels
+}
+
+// Label values escape backslash, double-quote and line feed, per the exposition format
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapePrometheusLabel(v string) string {
+	return prometheusLabelEscaper.Replace(v)
+}
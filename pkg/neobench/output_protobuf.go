@@ -0,0 +1,161 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"io"
+	"strings"
+)
+
+// Keeps every result until Close, then writes them to stdout as Result messages of neobench.proto, each led by
+// its length as a varint, the way protobuf libraries write delimited messages; for archiving results in a typed,
+// versioned format, eg. streamed to a collector with --output-destination. Latencies are in milliseconds.
+// Progress and error details go to stderr.
+type ProtobufOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// Latency percentiles to report, defaults to DefaultJsonPercentiles
+	Percentiles []float64
+	ProgressReporter
+	results []Result
+	errorTracker
+}
+
+func (o *ProtobufOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return writeBenchmarkStart(o.ErrStream, databaseName, url, scenario)
+}
+
+func (o *ProtobufOutput) ReportProgress(report ProgressReport) {
+	o.reportProgress(o.ErrStream, report)
+}
+
+func (o *ProtobufOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done, %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
+	return err
+}
+
+// Samples aren't part of the archived results
+func (o *ProtobufOutput) ReportInterval(sample IntervalResult) error {
+	return nil
+}
+
+// Latencies are recorded in throughput mode as well, so these messages are the same as ReportLatency's
+func (o *ProtobufOutput) ReportThroughput(result Result) error {
+	return o.ReportLatency(result)
+}
+
+func (o *ProtobufOutput) ReportLatency(result Result) error {
+	o.results = append(o.results, result)
+	if result.TotalFailed() == 0 {
+		return nil
+	}
+	s := strings.Builder{}
+	writeErrorReport(result, o.reportedByCategory, &s, false)
+	_, err := fmt.Fprint(o.ErrStream, s.String())
+	return err
+}
+
+func (o *ProtobufOutput) Errorf(format string, a ...interface{}) {
+	o.errorsReported = true
+	writeError(o.ErrStream, format, a...)
+}
+
+func (o *ProtobufOutput) ReportError(category ErrorCategory, err error) {
+	o.reportError(o.Errorf, category, err)
+}
+
+func (o *ProtobufOutput) Warnf(format string, a ...interface{}) {
+	writeWarning(o.ErrStream, format, a...)
+}
+
+// Stdout only has Result messages, so whatever reads it can take it as a stream of them
+func (o *ProtobufOutput) ReportPlan(plan Plan) error {
+	return writePlan(o.ErrStream, plan, false)
+}
+
+func (o *ProtobufOutput) Close() error {
+	for _, result := range o.results {
+		msg := o.result(result)
+		delimited := protoBuffer{}
+		delimited.varint(uint64(len(msg.b)))
+		delimited.b = append(delimited.b, msg.b...)
+		if _, err := o.OutStream.Write(delimited.b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *ProtobufOutput) Reset() {
+	o.resetProgress()
+	o.errorTracker.reset()
+}
+
+func (o *ProtobufOutput) result(result Result) *protoBuffer {
+	msg := &protoBuffer{}
+	msg.string(1, result.DatabaseName)
+	msg.string(2, result.Scenario)
+	msg.int64(3, int64(result.Config.Clients))
+	msg.double(4, result.Config.TargetRate)
+	msg.double(5, result.Duration.Seconds())
+	if !result.StartTime.IsZero() {
+		msg.int64(6, result.StartTime.UnixNano())
+	}
+	if !result.EndTime.IsZero() {
+		msg.int64(7, result.EndTime.UnixNano())
+	}
+	msg.string(8, result.NeobenchVersion)
+	msg.string(9, result.Neo4jVersion)
+	// Map entries are messages with the key as field 1 and the value as field 2
+	for _, key := range sortedTagKeys(result.Tags) {
+		entry := &protoBuffer{}
+		entry.string(1, key)
+		entry.string(2, result.Tags[key])
+		msg.message(10, entry)
+	}
+	msg.bool(11, result.Warmup)
+	for _, script := range sortedScripts(result) {
+		msg.message(12, o.scriptResult(script))
+	}
+	msg.message(13, o.scriptResult(result.Total()))
+	msg.bool(14, result.Interrupted)
+	msg.string(15, result.RunId)
+	return msg
+}
+
+func (o *ProtobufOutput) scriptResult(script *ScriptResult) *protoBuffer {
+	msg := &protoBuffer{}
+	msg.string(1, script.ScriptName)
+	msg.int64(2, script.Succeeded)
+	msg.int64(3, script.Failed)
+	msg.double(4, script.Rate)
+	msg.int64(5, script.Retries)
+	if script.Latencies.TotalCount() > 0 {
+		msg.message(6, o.latency(script.Latencies))
+	}
+	return msg
+}
+
+func (o *ProtobufOutput) latency(histo *hdrhistogram.Histogram) *protoBuffer {
+	msg := &protoBuffer{}
+	msg.double(1, float64(histo.Min())/1000.0)
+	msg.double(2, histo.Mean()/1000.0)
+	msg.double(3, float64(histo.Max())/1000.0)
+	msg.double(4, histo.StdDev()/1000.0)
+	for _, q := range o.percentiles() {
+		latency := valueAtPercentile(histo, q)
+		percentile := &protoBuffer{}
+		percentile.double(1, q)
+		percentile.double(2, float64(latency)/1000.0)
+		percentile.int64(3, countAtOrAbove(histo, latency))
+		msg.message(5, percentile)
+	}
+	return msg
+}
+
+func (o *ProtobufOutput) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultJsonPercentiles
+	}
+	return o.Percentiles
+}
@@ -0,0 +1,19 @@
+package neobench
+
+// Like CsvOutput, but without the banner and progress chatter on stderr, for automated pipelines.
+// Results are still written as CSV to stdout, and errors are still written to stderr.
+type QuietOutput struct {
+	CsvOutput
+}
+
+// Only the banner is left out, the header still leads the results
+func (o *QuietOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	return o.writeLatencyHeader()
+}
+
+func (o *QuietOutput) ReportProgress(report ProgressReport) {
+}
+
+func (o *QuietOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	return nil
+}
@@ -0,0 +1,122 @@
+package neobench
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Keeps everything reported to it, for when neobench is used as a library and results are wanted as values
+// rather than text. Safe to report to from several goroutines; read the fields once reporting is done.
+type RecordingOutput struct {
+	// As passed to BenchmarkStart
+	DatabaseName string
+	Url          string
+	Scenario     string
+
+	Progress []ProgressReport
+	// Checkpoints reported by ReportWorkloadProgress, along with how complete the workload was at each
+	WorkloadProgress []float64
+	Checkpoints      []Result
+	Intervals        []IntervalResult
+	Throughput       []Result
+	Latency          []Result
+	Plans            []Plan
+	// Formatted messages passed to Errorf, ReportError and Warnf
+	Errors   []string
+	Warnings []string
+	// Errors passed to ReportError, by category
+	ErrorsByCategory map[ErrorCategory]int64
+
+	mut sync.Mutex
+}
+
+func (o *RecordingOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.DatabaseName, o.Url, o.Scenario = databaseName, url, scenario
+	return nil
+}
+
+func (o *RecordingOutput) ReportProgress(report ProgressReport) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Progress = append(o.Progress, report)
+}
+
+func (o *RecordingOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.WorkloadProgress = append(o.WorkloadProgress, completeness)
+	o.Checkpoints = append(o.Checkpoints, checkpoint)
+	return nil
+}
+
+func (o *RecordingOutput) ReportInterval(sample IntervalResult) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Intervals = append(o.Intervals, sample)
+	return nil
+}
+
+func (o *RecordingOutput) ReportThroughput(result Result) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Throughput = append(o.Throughput, result)
+	return nil
+}
+
+func (o *RecordingOutput) ReportLatency(result Result) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Latency = append(o.Latency, result)
+	return nil
+}
+
+func (o *RecordingOutput) ReportPlan(plan Plan) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Plans = append(o.Plans, plan)
+	return nil
+}
+
+func (o *RecordingOutput) Errorf(format string, a ...interface{}) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Errors = append(o.Errors, fmt.Sprintf(format, a...))
+}
+
+func (o *RecordingOutput) ReportError(category ErrorCategory, err error) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Errors = append(o.Errors, err.Error())
+	if o.ErrorsByCategory == nil {
+		o.ErrorsByCategory = make(map[ErrorCategory]int64)
+	}
+	o.ErrorsByCategory[category]++
+}
+
+func (o *RecordingOutput) Warnf(format string, a ...interface{}) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Warnings = append(o.Warnings, fmt.Sprintf(format, a...))
+}
+
+func (o *RecordingOutput) Close() error {
+	return nil
+}
+
+// Forgets everything recorded, unlike other outputs' Reset, so what's recorded next is of the next run only
+func (o *RecordingOutput) Reset() {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.DatabaseName, o.Url, o.Scenario = "", "", ""
+	o.Progress, o.WorkloadProgress, o.Checkpoints, o.Intervals = nil, nil, nil, nil
+	o.Throughput, o.Latency, o.Plans = nil, nil, nil
+	o.Errors, o.Warnings, o.ErrorsByCategory = nil, nil, nil
+}
+
+func (o *RecordingOutput) ErrorsReported() bool {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	return len(o.Errors) > 0
+}
@@ -0,0 +1,88 @@
+package neobench
+
+import "sync"
+
+// Serializes calls to the wrapped Output, which isn't safe to call from several goroutines otherwise; workers
+// report errors while the main goroutine reports progress, and the outputs keep state between calls.
+type SynchronizedOutput struct {
+	Output Output
+	mut    sync.Mutex
+}
+
+func (o *SynchronizedOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	return o.Output.BenchmarkStart(databaseName, url, scenario)
+}
+
+func (o *SynchronizedOutput) ReportProgress(report ProgressReport) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Output.ReportProgress(report)
+}
+
+func (o *SynchronizedOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	return o.Output.ReportWorkloadProgress(completeness, checkpoint)
+}
+
+func (o *SynchronizedOutput) ReportInterval(sample IntervalResult) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	return o.Output.ReportInterval(sample)
+}
+
+func (o *SynchronizedOutput) ReportThroughput(result Result) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	return o.Output.ReportThroughput(result)
+}
+
+func (o *SynchronizedOutput) ReportLatency(result Result) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	return o.Output.ReportLatency(result)
+}
+
+func (o *SynchronizedOutput) ReportPlan(plan Plan) error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	return o.Output.ReportPlan(plan)
+}
+
+func (o *SynchronizedOutput) Errorf(format string, a ...interface{}) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Output.Errorf(format, a...)
+}
+
+func (o *SynchronizedOutput) ReportError(category ErrorCategory, err error) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Output.ReportError(category, err)
+}
+
+func (o *SynchronizedOutput) Warnf(format string, a ...interface{}) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	o.Output.Warnf(format, a...)
+}
+
+func (o *SynchronizedOutput) ErrorsReported() bool {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	return o.Output.ErrorsReported()
+}
+
+func (o *SynchronizedOutput) Close() error {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	return o.Output.Close()
+}
+
+func (o *SynchronizedOutput) Reset() {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	ResetOutput(o.Output)
+}
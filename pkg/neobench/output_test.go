@@ -0,0 +1,2541 @@
+package neobench
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPercentileNames(t *testing.T) {
+	tc := []struct {
+		q      float64
+		label  string
+		column string
+	}{
+		{0, "00.000", "p0"},
+		{25, "25.000", "p25"},
+		{90, "90.000", "p90"},
+		{99.9, "99.900", "p99900"},
+		{99.999, "99.999", "p99999"},
+		{99.9999, "99.9999", "p999999"},
+		{100, "100.000", "p100"},
+	}
+	for _, c := range tc {
+		assert.Equal(t, c.label, percentileLabel(c.q))
+		assert.Equal(t, c.column, percentileColumnName(c.q))
+	}
+}
+
+// Column names are what scripts reading the output key on, and the README documents them
+func TestPercentileColumnsAreNamedAsDocumented(t *testing.T) {
+	buf := &bytes.Buffer{}
+	csvOut := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{99, 99.9}}
+	assert.NoError(t, csvOut.BenchmarkStart("db", "neo4j://localhost", "-c 1"))
+	assert.Contains(t, buf.String(), ",p99_ms,p99900_ms,")
+
+	buf.Reset()
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	heatmap := &HeatmapOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, heatmap.ReportInterval(IntervalResult{Result: newTestResult(t, "db", "a.script"), Start: start, End: start.Add(time.Second)}))
+	assert.True(t, strings.HasPrefix(buf.String(),
+		"time,elapsed_seconds,transactions,p0_ms,p10_ms,p25_ms,p50_ms,p75_ms,p90_ms,p95_ms,p99_ms,p99900_ms,p100_ms\n"), buf.String())
+}
+
+func TestCsvLatencyColumnsMatchValues(t *testing.T) {
+	result := newTestResult(t, "db", "script.cypher")
+	histo := result.Scripts["script.cypher"].Latencies
+	out := &CsvOutput{Percentiles: []float64{0, 25, 50, 75, 95, 99, 99.9, 99.999, 100}}
+	buf := &bytes.Buffer{}
+	out.OutStream, out.ErrStream = buf, &bytes.Buffer{}
+
+	assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost", "-c 1"))
+	assert.NoError(t, out.ReportLatency(result))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	header := strings.Split(lines[0], ",")
+	row := strings.Split(lines[1], ",")
+	assert.Equal(t, len(header), len(row))
+
+	values := make(map[string]string)
+	for i, name := range header {
+		values[name] = row[i]
+	}
+	assert.Equal(t, fmtFloat(histo.Mean()/1000.0), values["mean_ms"])
+	assert.Equal(t, fmtFloat(histo.StdDev()/1000.0), values["stdev_ms"])
+	for _, q := range out.Percentiles {
+		expected := fmtFloat(float64(valueAtPercentile(histo, q)) / 1000.0)
+		assert.Equal(t, expected, values[percentileColumnName(q)+"_ms"], "p%v", q)
+	}
+	assert.Equal(t, fmtFloat(float64(histo.Min())/1000.0), values["p0_ms"])
+	assert.Equal(t, fmtFloat(float64(histo.Max())/1000.0), values["p100_ms"])
+}
+
+func TestCsvLeadsWithRunConfig(t *testing.T) {
+	result := newTestResult(t, "db", "script.cypher")
+	result.Config = RunConfig{Clients: 8, TargetRate: 250, Duration: time.Minute}
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+
+	assert.NoError(t, out.ReportLatency(result))
+	assert.True(t, strings.HasPrefix(buf.String(), `8,250.000,60.000,"db","script.cypher",`))
+
+	buf.Reset()
+	assert.NoError(t, out.ReportThroughput(result))
+	assert.Contains(t, buf.String(), "\n"+`8,250.000,60.000,"script.cypher",`)
+}
+
+// Result with one script, with 1..10000ms latencies so every percentile lands on a distinct value
+func newTestResult(t *testing.T, databaseName, scriptName string) Result {
+	histo := hdrhistogram.New(0, 60*60*1000000, 3)
+	for i := int64(1); i <= 10000; i++ {
+		assert.NoError(t, histo.RecordValue(i*1000))
+	}
+	result := NewResult(databaseName, "-c 1")
+	result.Scripts[scriptName] = &ScriptResult{
+		ScriptName: scriptName,
+		Rate:       100,
+		Succeeded:  histo.TotalCount(),
+		Latencies:  histo,
+	}
+	return result
+}
+
+func TestCsvLatencyAddsTotalRowForSeveralScripts(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	other := newTestResult(t, "db", "b.script")
+	result.Scripts["b.script"] = other.Scripts["b.script"]
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+
+	assert.NoError(t, out.ReportLatency(result))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	total := strings.Split(lines[2], ",")
+	assert.Equal(t, `"<total>"`, total[4])
+	assert.Equal(t, fmtFloat(int64(20000)), total[6])
+}
+
+func TestPrometheusLatencyMetrics(t *testing.T) {
+	result := newTestResult(t, "db", `my "script"`)
+	buf := &bytes.Buffer{}
+	out := &PrometheusOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{99}}
+
+	assert.NoError(t, out.ReportLatency(result))
+
+	labels := `scenario="-c 1",database="db",script="my \"script\""`
+	assert.Contains(t, buf.String(), "# TYPE neobench_latency_ms summary\n")
+	assert.Contains(t, buf.String(), "neobench_transactions_per_second{"+labels+"} 100.000\n")
+	assert.Contains(t, buf.String(), "neobench_latency_ms{"+labels+",quantile=\"0.99\"} 9904.127\n")
+	assert.Contains(t, buf.String(), "neobench_latency_ms_count{"+labels+"} 10000\n")
+}
+
+func TestIsBrokenPipe(t *testing.T) {
+	assert.True(t, IsBrokenPipe(&os.PathError{Op: "write", Path: "/dev/stdout", Err: syscall.EPIPE}))
+	assert.True(t, IsBrokenPipe(errors.Wrap(io.ErrClosedPipe, "failed to write results")))
+	assert.False(t, IsBrokenPipe(errors.New("disk full")))
+}
+
+func TestProgressIsRateLimitedPerStep(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: &bytes.Buffer{}, ErrStream: buf, ProgressReporter: ProgressReporter{ProgressInterval: time.Hour}}
+
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create schema", Completeness: 0})
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create schema", Completeness: 0.5})
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create accounts", Completeness: 0})
+
+	assert.Equal(t, "[init][create schema] 0.00%\n[init][create accounts] 0.00%\n", buf.String())
+}
+
+func TestResetOutputsReportTheNextRunAfresh(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out, err := NewOutput("csv", OutputOptions{OutStream: &bytes.Buffer{}, ErrStream: buf, ProgressInterval: time.Hour})
+	assert.NoError(t, err)
+
+	out.ReportProgress(ProgressReport{Section: "run", Step: "workload", Completeness: 0})
+	out.ReportError(ErrorConnection, errors.New("connection reset"))
+	ResetOutput(out)
+	// The same step of the next run isn't held back by progress of the last one
+	out.ReportProgress(ProgressReport{Section: "run", Step: "workload", Completeness: 0})
+	assert.Equal(t, 2, strings.Count(buf.String(), "[run][workload] 0.00%\n"))
+	// A run that failed still fails the process
+	assert.True(t, out.ErrorsReported())
+
+	for _, name := range OutputFormats {
+		out, err := NewOutput(name, OutputOptions{OutStream: &bytes.Buffer{}, ErrStream: &bytes.Buffer{}})
+		assert.NoError(t, err)
+		assert.Implements(t, (*ResettableOutput)(nil), out, name)
+	}
+	// Outputs written elsewhere needn't be able to, they're left as they are
+	inner := &RecordingOutput{}
+	inner.Errorf("failed")
+	ResetOutput(struct{ Output }{inner})
+	assert.True(t, inner.ErrorsReported())
+
+	recording := &RecordingOutput{}
+	assert.NoError(t, recording.ReportLatency(newTestResult(t, "db", "a.script")))
+	recording.Errorf("failed")
+	recording.Reset()
+	assert.Empty(t, recording.Latency)
+	assert.False(t, recording.ErrorsReported())
+}
+
+func TestProgressIntervalZeroReportsEveryUpdate(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: buf}
+
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create schema", Completeness: 0})
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create schema", Completeness: 0.5})
+
+	assert.Equal(t, "[init][create schema] 0.00%\n[init][create schema] 50.00%\n", buf.String())
+}
+
+func TestProgressBarRedrawsInPlace(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: &bytes.Buffer{}, ErrStream: buf, ProgressBar: true}
+
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create schema", Completeness: 0})
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create accounts", Completeness: 0.5})
+	out.Errorf("oh no")
+
+	assert.Equal(t, "\r[init][create schema] ["+strings.Repeat(" ", 30)+"]   0.00%\n"+
+		"\r[init][create accounts] ["+strings.Repeat("#", 15)+strings.Repeat(" ", 15)+"]  50.00%\n"+
+		"ERROR: oh no\n", buf.String())
+}
+
+func TestProgressBarOutputDrawsABarOverAnyFormat(t *testing.T) {
+	errStream, outStream := &bytes.Buffer{}, &bytes.Buffer{}
+	out := &ProgressBarOutput{Output: &CsvOutput{ErrStream: errStream, OutStream: outStream, Percentiles: []float64{50}}, ErrStream: errStream}
+
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create schema", Completeness: 0})
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create accounts", Completeness: 0.5})
+	out.Warnf("slow")
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+
+	assert.Equal(t, "\r[init][create schema] ["+strings.Repeat(" ", 30)+"]   0.00%\n"+
+		"\r[init][create accounts] ["+strings.Repeat("#", 15)+strings.Repeat(" ", 15)+"]  50.00%\n"+
+		"WARN: slow\n", errStream.String())
+	assert.Contains(t, outStream.String(), `"db","a.script"`)
+}
+
+func TestProgressTimerEstimatesTimeLeft(t *testing.T) {
+	start := time.Now()
+	timer := progressTimer{}
+	timer.update(ProgressReport{Section: "init", Step: "create accounts", Completeness: 0.2}, true, start)
+
+	report := ProgressReport{Section: "init", Step: "create accounts", Completeness: 0.6}
+	timer.update(report, false, start.Add(60*time.Second))
+	assert.Equal(t, " (elapsed 1m0s, eta ~1m0s)", timer.describe(report, start.Add(60*time.Second)))
+
+	// No progress yet, or fully done, means there is nothing to extrapolate from
+	_, ok := timer.eta(ProgressReport{Completeness: 0.2}, start.Add(time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, " (elapsed 1m0s)", timer.describe(ProgressReport{Completeness: 1}, start.Add(60*time.Second)))
+
+	// A new step restarts the clock
+	timer.update(ProgressReport{Section: "init", Step: "create branches", Completeness: 0}, true, start.Add(60*time.Second))
+	assert.Equal(t, "", timer.describe(ProgressReport{Completeness: 0}, start.Add(60*time.Second)))
+}
+
+func TestQuietOutputOnlyWritesErrorsToStderr(t *testing.T) {
+	errStream, outStream := &bytes.Buffer{}, &bytes.Buffer{}
+	out := &QuietOutput{CsvOutput{ErrStream: errStream, OutStream: outStream}}
+
+	assert.NoError(t, out.BenchmarkStart("neo4j", "neo4j://localhost:7687", "-c 1"))
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create schema", Completeness: 0.5})
+	assert.NoError(t, out.ReportWorkloadProgress(0.5, newTestResult(t, "neo4j", "tpcb-like")))
+	assert.Equal(t, "", errStream.String())
+
+	assert.NoError(t, out.ReportThroughput(newTestResult(t, "neo4j", "tpcb-like")))
+	out.Errorf("oh no")
+	assert.Equal(t, "ERROR: oh no\n", errStream.String())
+	// Throughput rows have a header of their own, after the latency one every csv run starts with
+	assert.True(t, strings.HasPrefix(outStream.String(), csvHeader(out.columns(), out.format())))
+	assert.Contains(t, outStream.String(), "\nclients,target_transactions_per_second,duration_seconds,script,succeeded,failed,error_rate_percent,transactions_per_second,mean_latency_ms,p99_latency_ms,neobench_version,neo4j_version,queries_per_second,records_per_second,bytes_per_second,start_time,end_time,retries,p99_latency_transactions,errors\n")
+}
+
+func TestQuietLatencyOutputStartsWithTheHeader(t *testing.T) {
+	errStream, outStream := &bytes.Buffer{}, &bytes.Buffer{}
+	out := &QuietOutput{CsvOutput{ErrStream: errStream, OutStream: outStream}}
+
+	assert.NoError(t, out.BenchmarkStart("neo4j", "neo4j://localhost:7687", "-c 1"))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "neo4j", "tpcb-like")))
+	lines := strings.Split(strings.TrimRight(outStream.String(), "\n"), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, csvHeader(out.columns(), out.format()), lines[0]+"\n")
+	assert.True(t, strings.HasPrefix(lines[0], "clients,target_transactions_per_second,"), lines[0])
+	assert.Equal(t, "", errStream.String())
+}
+
+func TestHgrmOutputWritesPercentileDistribution(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &HgrmOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "neo4j", "tpcb-like")))
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	assert.Equal(t, "       Value     Percentile TotalCount 1/(1-Percentile)", lines[0])
+	assert.Equal(t, "", lines[1])
+	assert.Equal(t, "       1.000 0.000000000000          1           1.00", lines[2])
+	assert.Equal(t, "   10002.431 1.000000000000      10000", lines[len(lines)-3])
+	assert.True(t, strings.HasPrefix(lines[len(lines)-1], "#[Max     =    10002.431, Total count    =        10000]"))
+}
+
+func TestHistogramOutputRoundTrips(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &HistogramOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	result := newTestResult(t, "neo4j", "tpcb-like")
+
+	assert.NoError(t, out.ReportLatency(result))
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+
+	histograms, err := ReadHistograms(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(histograms))
+	assert.True(t, result.Scripts["tpcb-like"].Latencies.Equals(histograms[0]))
+
+	histograms[0].Merge(histograms[1])
+	assert.Equal(t, int64(20000), histograms[0].TotalCount())
+
+	_, err = DecodeHistogram("bm90IGEgaGlzdG9ncmFt")
+	assert.Error(t, err)
+}
+
+func TestDecodeHistogramRejectsSettingsHdrHistogramCantWorkWith(t *testing.T) {
+	encode := func(lowest, highest, figures, counts int64) string {
+		raw := []byte{}
+		for _, v := range []int64{histogramEncodingVersion, lowest, highest, figures, counts} {
+			raw = appendVarint(raw, v)
+		}
+		for i := int64(0); i < counts; i++ {
+			raw = appendVarint(raw, 0)
+		}
+		compressed := bytes.Buffer{}
+		w := zlib.NewWriter(&compressed)
+		_, _ = w.Write(raw)
+		_ = w.Close()
+		return base64.StdEncoding.EncodeToString(compressed.Bytes())
+	}
+	counts := int64(len(hdrhistogram.New(1, 1000, 2).Export().Counts))
+
+	_, err := DecodeHistogram(encode(1, 1000, 2, counts))
+	assert.NoError(t, err)
+	_, err = DecodeHistogram(encode(1, 1000, 9, counts))
+	assert.EqualError(t, err, "histogram was encoded with invalid settings: invalid significant figures: 9, needs to be between 1 and 5")
+	_, err = DecodeHistogram(encode(600, 1000, 2, counts))
+	assert.EqualError(t, err, "histogram was encoded with invalid settings: invalid histogram max: 1ms, needs to be at least 1µs and twice the min of 600µs")
+	_, err = DecodeHistogram(encode(-1, 1000, 2, counts))
+	assert.EqualError(t, err, "histogram has an invalid range of -1µs to 1000µs")
+	_, err = DecodeHistogram(encode(1, 1000, 2, counts-1))
+	assert.EqualError(t, err, fmt.Sprintf("histogram has %d counts, its settings need %d", counts-1, counts))
+}
+
+func TestMeasuredRateUsesRunDuration(t *testing.T) {
+	result := newTestResult(t, "neo4j", "tpcb-like")
+	assert.Equal(t, 0.0, result.MeasuredRate())
+
+	result.Duration = 20 * time.Second
+	assert.Equal(t, 500.0, result.MeasuredRate())
+
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Contains(t, buf.String(), "Measured Rate: 500.000 successful transactions per second over 20s\n")
+}
+
+func TestThroughputReportsIncludeLatencySummary(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, out.ReportThroughput(newTestResult(t, "neo4j", "tpcb-like")))
+	assert.Contains(t, buf.String(), "[tpcb-like]: 100.000 successful transactions per second, 0.000 queries per second, mean latency 5000.")
+	assert.Contains(t, buf.String(), ", P99 9904.127ms\n")
+}
+
+func TestInteractiveColorsOnlyWhenEnabled(t *testing.T) {
+	plain, colored := &bytes.Buffer{}, &bytes.Buffer{}
+	plainErr, coloredErr := &bytes.Buffer{}, &bytes.Buffer{}
+	result := newTestResult(t, "neo4j", "tpcb-like")
+
+	assert.NoError(t, (&InteractiveOutput{OutStream: plain, ErrStream: plainErr}).ReportLatency(result))
+	(&InteractiveOutput{OutStream: plain, ErrStream: plainErr}).Errorf("oh no")
+	assert.NotContains(t, plain.String(), "\x1b[")
+	assert.Equal(t, "ERROR: oh no\n", plainErr.String())
+
+	out := &InteractiveOutput{OutStream: colored, ErrStream: coloredErr, Color: true, ErrColor: true}
+	assert.NoError(t, out.ReportLatency(result))
+	out.Errorf("oh no")
+	assert.Contains(t, colored.String(), "\x1b[36m== Results ==\x1b[0m\n")
+	assert.Contains(t, colored.String(), "P99.000: \x1b[1m9904.127ms\x1b[0m (n=105)\n")
+	assert.Equal(t, "\x1b[31mERROR: oh no\x1b[0m\n", coloredErr.String())
+}
+
+func TestNoColorDisablesColor(t *testing.T) {
+	defer os.Setenv("NO_COLOR", os.Getenv("NO_COLOR"))
+	assert.NoError(t, os.Setenv("NO_COLOR", "1"))
+	assert.False(t, useColor(os.Stdout))
+	// Not a terminal, so never colored regardless
+	assert.False(t, useColor(&bytes.Buffer{}))
+}
+
+func TestCsvStreamsIntervalSamples(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}, Samples: true}
+	end := time.Date(2020, 1, 1, 1, 1, 2, 0, time.UTC)
+	sample := IntervalResult{Result: newTestResult(t, "db", "a.script"), Start: end.Add(-time.Second), End: end}
+	sample.Duration = time.Second
+
+	assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+	assert.NoError(t, out.ReportInterval(sample))
+	assert.NoError(t, out.ReportInterval(sample))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+
+	// Samples and results share the one header
+	assert.Equal(t, "row_kind,timestamp,interval_seconds,clients,target_transactions_per_second,duration_seconds,db,script,transactions_per_second,succeeded,failed,error_rate_percent,mean_ms,stdev_ms,p50_ms,neobench_version,neo4j_version,mean_stderr_ms,mean_ci95_low_ms,mean_ci95_high_ms,significant_figures,max_trackable_ms,start_time,end_time,retries,p50_transactions,errors\n"+
+		`sample,2020-01-01T01:01:02.000Z,1.000,0,0.000,0.000,"db","a.script",100.000,10000.000,0.000,0.000,5000.505,2886.752,5001.215,"","",28.868,4943.924,5057.085,3,3600000.000,,,0,5003,0`+"\n"+
+		`sample,2020-01-01T01:01:02.000Z,1.000,0,0.000,0.000,"db","a.script",100.000,10000.000,0.000,0.000,5000.505,2886.752,5001.215,"","",28.868,4943.924,5057.085,3,3600000.000,,,0,5003,0`+"\n"+
+		`result,,,0,0.000,0.000,"db","a.script",100.000,10000.000,0.000,0.000,5000.505,2886.752,5001.215,"","",28.868,4943.924,5057.085,3,3600000.000,,,0,5003,0`+"\n",
+		buf.String())
+}
+
+func TestThroughputRunsReportLatenciesOnlyWhenAskedTo(t *testing.T) {
+	recording := &RecordingOutput{}
+	assert.NoError(t, ReportThroughputResult(recording, newTestResult(t, "db", "a.script"), false))
+	assert.Len(t, recording.Throughput, 1)
+	assert.Empty(t, recording.Latency)
+
+	recording = &RecordingOutput{}
+	assert.NoError(t, ReportThroughputResult(recording, newTestResult(t, "db", "a.script"), true))
+	assert.Empty(t, recording.Throughput)
+	assert.Len(t, recording.Latency, 1)
+
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50, 99}}
+	assert.NoError(t, ReportThroughputResult(out, newTestResult(t, "db", "a.script"), false))
+	assert.NotContains(t, buf.String(), "p50_ms")
+	buf.Reset()
+	assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+	assert.NoError(t, ReportThroughputResult(out, newTestResult(t, "db", "a.script"), true))
+	assert.Contains(t, buf.String(), ",p50_ms,p99_ms,")
+	assert.Contains(t, buf.String(), ",5001.215,9904.127,")
+}
+
+func TestCsvWorkloadCheckpointsAreToldApartFromResults(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}, Samples: true, OmitHeader: true}
+	assert.NoError(t, out.ReportWorkloadProgress(0.5, newTestResult(t, "db", "a.script")))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], `checkpoint,,,0,0.000,0.000,"db","a.script",`), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], `result,,,0,0.000,0.000,"db","a.script",`), lines[1])
+
+	// Unsampled runs have no row_kind to tell them by
+	buf.Reset()
+	out = &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}, OmitHeader: true}
+	assert.NoError(t, out.ReportWorkloadProgress(0.5, newTestResult(t, "db", "a.script")))
+	assert.True(t, strings.HasPrefix(buf.String(), `0,0.000,0.000,"db","a.script",`), buf.String())
+}
+
+func TestCsvSamplesAppendedToASharedFileLineUpWithItsHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.csv")
+	run := func() {
+		f, err := OpenAppendFile(path)
+		assert.NoError(t, err)
+		defer f.Close()
+		out := &CsvOutput{OutStream: f, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}, Samples: true}
+		assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+		assert.NoError(t, out.ReportInterval(IntervalResult{Result: newTestResult(t, "db", "a.script")}))
+		assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	}
+	run()
+	run()
+	written, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimRight(string(written), "\n"), "\n")
+	assert.Equal(t, 5, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "row_kind,timestamp,interval_seconds,"), lines[0])
+	for i, kind := range []string{"sample", "result", "sample", "result"} {
+		assert.True(t, strings.HasPrefix(lines[i+1], kind+","), lines[i+1])
+		assert.Equal(t, strings.Count(lines[0], ","), strings.Count(lines[i+1], ","))
+	}
+}
+
+func TestInteractiveIntervalSparkline(t *testing.T) {
+	assert.Equal(t, "▁▅█", sparkline([]float64{0, 50, 100}))
+	assert.Equal(t, "▁▁", sparkline([]float64{0, 0}))
+
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: &bytes.Buffer{}, ErrStream: buf}
+	end := time.Date(2020, 1, 1, 1, 1, 2, 0, time.Local)
+	assert.NoError(t, out.ReportInterval(IntervalResult{Result: newTestResult(t, "db", "a.script"), End: end}))
+	assert.Equal(t, "[01:01:02] █                    100.000 tps, P50 5001.215ms, P99 9904.127ms, 0 failures\n", buf.String())
+}
+
+func TestMarkdownAddsRowsToOneTable(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &MarkdownOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{99}}
+	result := newTestResult(t, "db", "a.script")
+
+	assert.NoError(t, out.ReportLatency(result))
+	result.Scenario = "-c 8 | tee"
+	assert.NoError(t, out.ReportLatency(result))
+
+	assert.Equal(t, ""+
+		"| Scenario | Script   | Samples | Rate (tps) | Mean (ms) | P99 (ms) |\n"+
+		"| -------- | -------- | ------: | ---------: | --------: | -------: |\n"+
+		"| -c 1     | a.script |   10000 |    100.000 |  5000.505 | 9904.127 |\n"+
+		"| -c 8 \\| tee | a.script |   10000 |    100.000 |  5000.505 | 9904.127 |\n", buf.String())
+}
+
+func TestOnlyErrorsAreReportedAsFatal(t *testing.T) {
+	buf := &bytes.Buffer{}
+	outputs := []Output{
+		&InteractiveOutput{ErrStream: buf, OutStream: buf},
+		&CsvOutput{ErrStream: buf, OutStream: buf},
+		&QuietOutput{CsvOutput{ErrStream: buf, OutStream: buf}},
+		&JsonOutput{ErrStream: buf, OutStream: buf},
+		&PrometheusOutput{ErrStream: buf, OutStream: buf},
+		&InfluxOutput{ErrStream: buf, OutStream: buf},
+		&MarkdownOutput{ErrStream: buf, OutStream: buf},
+		&HgrmOutput{ErrStream: buf, OutStream: buf},
+		&HistogramOutput{ErrStream: buf, OutStream: buf},
+	}
+	for _, out := range outputs {
+		out.Warnf("retrying in %s", time.Second)
+		assert.False(t, out.ErrorsReported(), "%T", out)
+		out.Errorf("oh no")
+		assert.True(t, out.ErrorsReported(), "%T", out)
+	}
+
+	buf.Reset()
+	(&CsvOutput{ErrStream: buf}).Warnf("retrying in %s", time.Second)
+	assert.Equal(t, "WARN: retrying in 1s\n", buf.String())
+}
+
+func TestLatencyUnits(t *testing.T) {
+	unit, err := ParseLatencyUnit("us")
+	assert.NoError(t, err)
+	assert.Equal(t, LatencyMicroseconds, unit)
+	_, err = ParseLatencyUnit("minutes")
+	assert.Error(t, err)
+
+	assert.Equal(t, LatencyMilliseconds, resolveLatencyUnit(LatencyUnit{}, Result{}))
+	assert.Equal(t, "1.500s", LatencySeconds.format(int64(1500000), DefaultPrecision))
+
+	fast := NewResult("db", "-c 1")
+	fast.Scripts["a"] = &ScriptResult{ScriptName: "a", Latencies: newLatencyHistogram()}
+	assert.NoError(t, fast.Scripts["a"].Latencies.RecordValue(250))
+	assert.Equal(t, LatencyMicroseconds, resolveLatencyUnit(LatencyAuto, fast))
+	assert.Equal(t, LatencyMilliseconds, resolveLatencyUnit(LatencyAuto, newTestResult(t, "db", "a")))
+
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}, LatencyUnit: LatencySeconds}
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a")))
+	assert.True(t, strings.HasSuffix(buf.String(), `,5.001,2.887,5.001,"","",0.029,4.944,5.057,3,3600.000,,,0,5003,0`+"\n"), buf.String())
+}
+
+func TestJsonOutputHasScenarioThroughputAndEveryPercentile(t *testing.T) {
+	report := func(percentiles []float64) map[string]interface{} {
+		buf := &bytes.Buffer{}
+		out := &JsonOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: percentiles}
+		assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+		var doc map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+		return doc
+	}
+	percentiles := func(script interface{}) map[string]interface{} {
+		return script.(map[string]interface{})["latency"].(map[string]interface{})["percentiles_ms"].(map[string]interface{})
+	}
+
+	doc := report(nil)
+	assert.Equal(t, "db", doc["database"])
+	assert.Equal(t, "-c 1", doc["scenario"])
+	assert.Equal(t, 100.0, doc["rate"])
+	scripts := doc["scripts"].([]interface{})
+	assert.Len(t, scripts, 1)
+	assert.Equal(t, "a.script", scripts[0].(map[string]interface{})["script"])
+	assert.Equal(t, 100.0, scripts[0].(map[string]interface{})["rate"])
+	assert.Len(t, percentiles(doc["total"]), len(DefaultJsonPercentiles))
+	for _, q := range DefaultJsonPercentiles {
+		assert.Contains(t, percentiles(doc["total"]), percentileColumnName(q))
+		assert.Contains(t, percentiles(scripts[0]), percentileColumnName(q))
+	}
+
+	doc = report([]float64{50, 99.9})
+	assert.Equal(t, map[string]interface{}{"p50": 5001.215, "p99900": 9994.239}, percentiles(doc["total"]))
+}
+
+func TestJsonProgressWrapsAnyOutput(t *testing.T) {
+	progress, csvErr, csvOut := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	out := &JsonProgressOutput{Output: &CsvOutput{OutStream: csvOut, ErrStream: csvErr}, ErrStream: progress}
+
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create schema", Completeness: 0})
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create schema", Completeness: 0.5})
+	assert.NoError(t, out.ReportWorkloadProgress(0.25, newTestResult(t, "db", "a.script")))
+	assert.NoError(t, out.ReportThroughput(newTestResult(t, "db", "a.script")))
+
+	lines := strings.Split(strings.TrimSpace(progress.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], `{"event":"progress","timestamp":"`), lines[0])
+	assert.Contains(t, lines[0], `"section":"init","step":"create schema","completeness":0,"elapsed_seconds":`)
+	assert.Contains(t, lines[1], `"completeness":0.5`)
+	assert.True(t, strings.HasPrefix(lines[2], `{"event":"workload_progress",`), lines[2])
+	assert.Contains(t, lines[2], `"completeness":0.25`)
+	assert.Equal(t, "", csvErr.String())
+	assert.Contains(t, csvOut.String(), `"a.script"`)
+}
+
+func TestInfluxLineProtocol(t *testing.T) {
+	buf := &bytes.Buffer{}
+	at := time.Unix(1600000000, 0)
+	out := &InfluxOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50, 99}, now: func() time.Time { return at }}
+	result := newTestResult(t, "", "tpcb like")
+	result.Scenario = "-c 8,-l"
+	result.Config.Clients = 8
+
+	assert.NoError(t, out.ReportLatency(result))
+
+	assert.Equal(t, ""+
+		`neobench_throughput,scenario=-c\ 8\,-l,script=tpcb\ like,clients=8 tps=100.000,succeeded=10000i,failed=0i 1600000000000000000`+"\n"+
+		`neobench_latency,scenario=-c\ 8\,-l,script=tpcb\ like,clients=8 mean=5000.505,min=1.000,max=10002.431,stddev=2886.752,p50=5001.215,p99=9904.127 1600000000000000000`+"\n",
+		buf.String())
+}
+
+func TestPhaseLatenciesAreMergedAndReported(t *testing.T) {
+	worker := NewWorkerResult(0)
+	outcome := uowOutcome{succeeded: true, acquireLatency: time.Millisecond, queryLatency: 2 * time.Millisecond}
+	assert.NoError(t, worker.record("a.script", 10*time.Millisecond, outcome))
+	assert.NoError(t, worker.record("a.script", 10*time.Millisecond, outcome))
+
+	result := NewResult("db", "-c 1")
+	result.Add(worker)
+	result.Add(worker)
+	assert.Equal(t, int64(4), result.Scripts["a.script"].Phases[PhaseQuery].TotalCount())
+	assert.Equal(t, int64(2), worker.Scripts["a.script"].Phases[PhaseQuery].TotalCount())
+	assert.Equal(t, int64(4), result.Total().Phases[PhaseConnectionAcquire].TotalCount())
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.Contains(t, buf.String(), "  Latency by phase:\n"+
+		"    connection acquire: mean 1.000ms, P50 1.000ms, P99 1.000ms, max 1.000ms\n"+
+		"    query: mean 2.000ms, P50 2.000ms, P99 2.000ms, max 2.000ms\n")
+}
+
+func TestReadAndWriteLatenciesAreReportedSeparately(t *testing.T) {
+	worker := NewWorkerResult(0)
+	assert.NoError(t, worker.record("a.script", 2*time.Millisecond, uowOutcome{succeeded: true, readonly: true}))
+	assert.NoError(t, worker.record("a.script", 2*time.Millisecond, uowOutcome{succeeded: true, readonly: true}))
+	assert.NoError(t, worker.record("a.script", 4*time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, worker.record("b.script", 30*time.Millisecond, uowOutcome{succeeded: true}))
+
+	result := NewResult("db", "-c 1")
+	result.Add(worker)
+	result.Add(worker)
+	assert.Equal(t, int64(4), result.Scripts["a.script"].AccessModes[AccessModeRead].TotalCount())
+	assert.Equal(t, int64(4), result.Total().AccessModes[AccessModeWrite].TotalCount())
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.Contains(t, buf.String(), "  Read latency: 4 transactions, mean 2.000ms, P50 2.000ms, P99 2.000ms, max 2.000ms\n"+
+		"\n"+
+		"  Write latency: 2 transactions, mean 4.001ms, P50 4.001ms, P99 4.001ms, max 4.001ms\n")
+	// b.script only ran writes, so its latencies say all there is to say
+	assert.Equal(t, 2, strings.Count(buf.String(), "Write latency"))
+
+	buf.Reset()
+	assert.NoError(t, (&JsonOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.Contains(t, buf.String(), `"access_modes":{"read":{"min_ms":2`)
+}
+
+func TestMergeResultsCombinesScriptsAndScenarios(t *testing.T) {
+	a := newTestResult(t, "db", "a.script")
+	b := newTestResult(t, "db", "a.script")
+	b.Scenario = "-c 2"
+	c := newTestResult(t, "db", "b.script")
+
+	merged, err := MergeResults([]Result{a, b, c})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "db", merged.DatabaseName)
+	assert.Equal(t, "-c 1; -c 2", merged.Scenario)
+	assert.Equal(t, int64(20000), merged.Scripts["a.script"].Latencies.TotalCount())
+	// The runs are taken to be one after another, each of them as fast as the other
+	assert.Equal(t, 100.0, merged.Scripts["a.script"].Rate)
+	assert.Equal(t, 100.0, merged.Scripts["b.script"].Rate)
+	assert.Equal(t, int64(10000), merged.Scripts["b.script"].Succeeded)
+	// Inputs are left alone
+	assert.Equal(t, int64(10000), a.Scripts["a.script"].Latencies.TotalCount())
+
+	// A second run twice as fast as the first doesn't make the two of them three times as fast
+	a.Duration, b.Duration = 100*time.Second, 50*time.Second
+	b.Scripts["a.script"].Rate = 200
+	merged, err = MergeResults([]Result{a, b})
+	assert.NoError(t, err)
+	assert.Equal(t, 150*time.Second, merged.Duration)
+	assert.InDelta(t, 20000.0/150, merged.Scripts["a.script"].Rate, 0.001)
+}
+
+func TestMergeResultsRejectsIncompatibleHistograms(t *testing.T) {
+	a := newTestResult(t, "db", "a.script")
+	b := newTestResult(t, "db", "a.script")
+	b.Scripts["a.script"].Latencies = hdrhistogram.New(0, 60*60*1000000, 2)
+
+	_, err := MergeResults([]Result{a, b})
+
+	assert.EqualError(t, err, "cannot merge latencies of a.script: histograms track different ranges, "+
+		"[0, 3600000000] with 3 significant figures vs [0, 3600000000] with 2")
+}
+
+func TestInteractiveFlagsErrorRate(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Color: true}
+
+	assert.NoError(t, out.ReportThroughput(result))
+	assert.Contains(t, buf.String(), "Errors: 0 (0.000% of attempts)\n")
+
+	result.Scripts["a.script"].Failed = 2500
+	buf.Reset()
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Contains(t, buf.String(), colorize(true, ansiRed, "Errors: 2500 (20.000% of attempts)")+"\n")
+	// The error report doesn't count them again
+	assert.NotContains(t, buf.String(), "Failed transactions:")
+
+	buf.Reset()
+	csvOut := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}}
+	assert.NoError(t, csvOut.ReportLatency(result))
+	assert.True(t, strings.HasSuffix(csvHeader(csvOut.columns(), csvCommaFormat), ",p50_transactions,errors\n"))
+	assert.True(t, strings.HasSuffix(buf.String(), ",5003,2500\n"), buf.String())
+}
+
+func TestNoProgressOutputOnlyDropsProgress(t *testing.T) {
+	errStream, outStream := &bytes.Buffer{}, &bytes.Buffer{}
+	out := &NoProgressOutput{&InteractiveOutput{OutStream: outStream, ErrStream: errStream}}
+
+	out.ReportProgress(ProgressReport{Section: "init", Step: "create schema", Completeness: 0.5})
+	assert.Equal(t, "", errStream.String())
+
+	assert.NoError(t, out.ReportThroughput(newTestResult(t, "db", "a.script")))
+	out.Errorf("oh no")
+	assert.Contains(t, outStream.String(), "== Results ==")
+	assert.Contains(t, errStream.String(), "oh no")
+	assert.True(t, out.ErrorsReported())
+}
+
+func TestResultsIncludeVersions(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	result.NeobenchVersion = "1.2.3"
+	result.Neo4jVersion = "4.1.0"
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.Contains(t, buf.String(), "Scenario: -c 1\nVersions: neobench 1.2.3, Neo4j 4.1.0\n")
+
+	buf.Reset()
+	assert.NoError(t, (&CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.True(t, strings.HasSuffix(buf.String(), `,"1.2.3","4.1.0",28.868,4943.924,5057.085,3,3600000.000,,,0,10000,7502,5003,2501,105,6,6,0`+"\n"), buf.String())
+
+	result.Neo4jVersion = ""
+	buf.Reset()
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportThroughput(result))
+	assert.Contains(t, buf.String(), "Versions: neobench 1.2.3, Neo4j unknown\n")
+}
+
+func TestMultiOutputCallsEveryOutputDespiteFailures(t *testing.T) {
+	pipeIn, pipeOut := io.Pipe()
+	assert.NoError(t, pipeIn.Close())
+	csvErr, jsonOut := &bytes.Buffer{}, &bytes.Buffer{}
+	out := &MultiOutput{Outputs: []Output{
+		&CsvOutput{OutStream: pipeOut, ErrStream: csvErr},
+		&JsonOutput{OutStream: jsonOut, ErrStream: &bytes.Buffer{}},
+	}}
+
+	err := out.ReportLatency(newTestResult(t, "db", "a.script"))
+	assert.True(t, IsBrokenPipe(err), err)
+	assert.Contains(t, jsonOut.String(), `"script":"a.script"`)
+
+	assert.False(t, out.ErrorsReported())
+	out.Errorf("oh no")
+	assert.Equal(t, "ERROR: oh no\n", csvErr.String())
+	assert.True(t, out.ErrorsReported())
+}
+
+func TestInteractiveDefaultPercentilesShowTheTail(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+
+	assert.Contains(t, buf.String(), ""+
+		"    P99.000: 9904.127ms (n=105)\n"+
+		"    P99.900: 9994.239ms (n=14)\n"+
+		"    P99.990: 10002.431ms (n=6)\n"+
+		"    P99.999: 10002.431ms (n=6)\n")
+}
+
+func TestCheckLatencyRangeWarns(t *testing.T) {
+	errStream := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: errStream}
+	result := newTestResult(t, "db", "a.script")
+
+	CheckLatencyRange(out, result)
+	assert.Equal(t, "", errStream.String())
+
+	result.Scripts["a.script"].OutOfRange = 3
+	CheckLatencyRange(out, result)
+	assert.Equal(t, "WARN: 3 samples exceeded the histogram's max of 1h0m0s; tail percentiles are unreliable, "+
+		"consider a higher --latency-max\n", errStream.String())
+	assert.False(t, out.ErrorsReported())
+
+	errStream.Reset()
+	result.Scripts["a.script"].Latencies = HistogramConfig{Min: time.Millisecond, Max: time.Second, SignificantFigures: 2}.newHistogram()
+	CheckLatencyRange(out, result)
+	assert.Equal(t, "WARN: 3 samples fell outside the histogram's range of 1ms to 1s; percentiles outside of it are unreliable, "+
+		"consider a wider --latency-min and --latency-max\n", errStream.String())
+}
+
+func TestTsvEscapesTextRatherThanQuoting(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{Tabs: true, OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}}
+	result := newTestResult(t, "db", "a\tscript")
+
+	assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+	assert.NoError(t, out.ReportLatency(result))
+	assert.NoError(t, out.ReportThroughput(result))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Equal(t, []string{
+		"clients\ttarget_transactions_per_second\tduration_seconds\tdb\tscript\ttransactions_per_second\tsucceeded\tfailed\terror_rate_percent\tmean_ms\tstdev_ms\tp50_ms\tneobench_version\tneo4j_version\tmean_stderr_ms\tmean_ci95_low_ms\tmean_ci95_high_ms\tsignificant_figures\tmax_trackable_ms\tstart_time\tend_time\tretries\tp50_transactions\terrors",
+		"0\t0.000\t0.000\tdb\ta\\tscript\t100.000\t10000.000\t0.000\t0.000\t5000.505\t2886.752\t5001.215\t\t\t28.868\t4943.924\t5057.085\t3\t3600000.000\t\t\t0\t5003\t0",
+		"clients\ttarget_transactions_per_second\tduration_seconds\tscript\tsucceeded\tfailed\terror_rate_percent\ttransactions_per_second\tmean_latency_ms\tp99_latency_ms\tneobench_version\tneo4j_version\tqueries_per_second\trecords_per_second\tbytes_per_second\tstart_time\tend_time\tretries\tp99_latency_transactions\terrors",
+		"0\t0.000\t0.000\ta\\tscript\t10000.000\t0.000\t0.000\t100.000\t5000.505\t9904.127\t\t\t0.000\t0.000\t0.000\t\t\t0\t105\t0",
+	}, lines)
+}
+
+func TestCsvOmitHeaderOnlyWritesDataRows(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{OmitHeader: true, OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}}
+	result := newTestResult(t, "db", "a.script")
+
+	assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+	assert.NoError(t, out.ReportLatency(result))
+	assert.NoError(t, out.ReportThroughput(result))
+
+	assert.Equal(t, ""+
+		`0,0.000,0.000,"db","a.script",100.000,10000.000,0.000,0.000,5000.505,2886.752,5001.215,"","",28.868,4943.924,5057.085,3,3600000.000,,,0,5003,0`+"\n"+
+		`0,0.000,0.000,"a.script",10000.000,0.000,0.000,100.000,5000.505,9904.127,"","",0.000,0.000,0.000,,,0,105,0`+"\n",
+		buf.String())
+}
+
+func TestCsvRunsSharingAnAppendFileWriteOneHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.csv")
+
+	var wg sync.WaitGroup
+	for _, script := range []string{"a.script", "b.script", "c.script"} {
+		f, err := OpenAppendFile(path)
+		assert.NoError(t, err)
+		out := &CsvOutput{OutStream: f, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}}
+		result := newTestResult(t, "db", script)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer f.Close()
+			assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+			assert.NoError(t, out.ReportLatency(result))
+		}()
+	}
+	wg.Wait()
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "clients,"))
+	for _, line := range lines[1:] {
+		assert.True(t, strings.HasPrefix(line, `0,0.000,0.000,"db",`), line)
+	}
+}
+
+func TestResultsWrittenToAGzipFileAreCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.csv.gz")
+	assert.True(t, IsGzipPath(path))
+	assert.False(t, IsGzipPath(filepath.Join(dir, "results.csv")))
+
+	f, err := CreateGzipFile(path)
+	assert.NoError(t, err)
+	out := &FlushingOutput{Output: &CsvOutput{OutStream: f, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}}, Stream: f}
+	assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	// Flushed results can be read back before the file is closed, up to where they were flushed
+	flushed, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, flushed)
+	assert.NoError(t, out.Close())
+	assert.NoError(t, f.Close())
+
+	compressed, err := os.Open(path)
+	assert.NoError(t, err)
+	defer compressed.Close()
+	gz, err := gzip.NewReader(compressed)
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(gz)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "clients,"))
+	assert.True(t, strings.HasPrefix(lines[1], `0,0.000,0.000,"db",`), lines[1])
+}
+
+func TestInteractiveReportsConfidenceInTheMean(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+
+	assert.Contains(t, buf.String(), ""+
+		"  Max: 10002.431ms, Min: 1.000ms, Mean: 5000.505ms, Stddev: 2886.752ms\n"+
+		"  Standard error of the mean: 28.868ms, 95% confidence interval of the mean: 4943.924ms - 5057.085ms\n\n")
+}
+
+func TestRecordingOutputKeepsEverythingReported(t *testing.T) {
+	out := &RecordingOutput{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out.ReportProgress(ProgressReport{Section: "benchmark", Step: "run"})
+			out.Errorf("worker %d crashed", 1)
+		}()
+	}
+	wg.Wait()
+	assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	out.Warnf("careful")
+
+	assert.Len(t, out.Progress, 10)
+	assert.Len(t, out.Errors, 10)
+	assert.Equal(t, "worker 1 crashed", out.Errors[0])
+	assert.True(t, out.ErrorsReported())
+	assert.Equal(t, []string{"careful"}, out.Warnings)
+	assert.Equal(t, "-c 1", out.Scenario)
+	assert.Equal(t, int64(10000), out.Latency[0].TotalSucceeded())
+	assert.Len(t, out.Throughput, 0)
+}
+
+func TestCdfOutputIsEvenlySpacedByLatency(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &CdfOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Points: 4}
+
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+
+	assert.Equal(t, "value_ms,cumulative_fraction\n"+
+		"2501.358,0.250000\n"+
+		"5001.716,0.500100\n"+
+		"7502.073,0.749900\n"+
+		"10002.431,1.000000\n", buf.String())
+}
+
+func TestSynchronizedOutputSerializesConcurrentReports(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &SynchronizedOutput{Output: &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: buf}}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				out.ReportProgress(ProgressReport{Section: "benchmark", Step: fmt.Sprintf("step %d", i), Completeness: float64(j) / 50})
+				if j == 0 {
+					out.Errorf("worker %d crashed", i)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 20*50+20)
+	for _, line := range lines {
+		assert.True(t, strings.HasPrefix(line, "[benchmark]") || strings.HasPrefix(line, "ERROR: worker "), line)
+	}
+	assert.True(t, out.ErrorsReported())
+}
+
+func TestCheckRateWarnsWhenBehindTarget(t *testing.T) {
+	errStream := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: errStream}
+	result := newTestResult(t, "db", "a.script")
+
+	CheckRate(out, result)
+	result.Config.TargetRate = 104
+	CheckRate(out, result)
+	assert.Equal(t, "", errStream.String())
+
+	result.Config.TargetRate = 125
+	CheckRate(out, result)
+	assert.Equal(t, "WARN: benchmark fell behind the target rate, 100.000 of 125.000 transactions per second (20.0% short); "+
+		"latencies include time transactions waited to start, add clients or lower the rate to measure the database alone\n", errStream.String())
+}
+
+func TestPercentileTargetsAreMarkedMetOrMissed(t *testing.T) {
+	targets, err := ParsePercentileTargets(map[string]string{"50": "6s", "99": "9.9s"})
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50, 75, 99}, Targets: targets}
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.Contains(t, buf.String(), "  P50.000: 5001.215ms ✓ (target 6000.000ms)\n")
+	assert.Contains(t, buf.String(), "  P75.000: 7503.871ms\n")
+	assert.Contains(t, buf.String(), "  P99.000: 9904.127ms (n=105) ✗ (target 9900.000ms)\n")
+
+	buf = &bytes.Buffer{}
+	csvOut := &CsvOutput{OmitHeader: true, OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}, Targets: targets}
+	assert.Equal(t, "p50_target_met,p99_target_met,retries,p50_transactions,errors\n", csvHeader(csvOut.columns()[len(csvOut.columns())-5:], csvCommaFormat))
+	assert.NoError(t, csvOut.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.True(t, strings.HasSuffix(buf.String(), ",3600000.000,,,true,false,0,5003,0\n"), buf.String())
+}
+
+func TestParsePercentileTargetsRejectsInvalidTargets(t *testing.T) {
+	for _, spec := range []map[string]string{{"p99": "20ms"}, {"101": "20ms"}, {"99": "20"}, {"99": "-1ms"}} {
+		_, err := ParsePercentileTargets(spec)
+		assert.Error(t, err, "%v", spec)
+	}
+}
+
+// Text is always quoted, with quotes doubled, so names with commas, quotes and line breaks read back unchanged
+func TestCsvRoundTripsAwkwardNames(t *testing.T) {
+	db, script := `my "read", db`, "read,\n\"heavy\".script"
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}}
+
+	assert.NoError(t, out.BenchmarkStart(db, "neo4j://localhost", "-c 1"))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, db, script)))
+	assert.NoError(t, out.ReportThroughput(newTestResult(t, db, script)))
+
+	// Throughput rows have fewer columns than latency rows
+	reader := csv.NewReader(buf)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 4)
+	assert.Equal(t, []string{db, script}, records[1][3:5])
+	assert.Equal(t, "script", records[2][3])
+	assert.Equal(t, script, records[3][3])
+}
+
+func TestInteractiveThroughputReportsRecordsOnlyIfAny(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, out.ReportThroughput(result))
+	assert.NotContains(t, buf.String(), "Records:")
+
+	script := result.Scripts["a.script"]
+	script.Records, script.RecordRate, script.Bytes, script.ByteRate = 500, 50, 4000, 400
+	buf.Reset()
+	assert.NoError(t, out.ReportThroughput(result))
+	assert.Contains(t, buf.String(), "Records: 500 (50.000 per second, about 400.000 bytes per second)\n")
+}
+
+func TestHtmlOutputStacksSectionsInOneDocument(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &HtmlOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{99}}
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "<a>.script")))
+	assert.NoError(t, out.ReportThroughput(newTestResult(t, "db", "b.script")))
+
+	doc := buf.String()
+	assert.Equal(t, 1, strings.Count(doc, "<!DOCTYPE html>"))
+	assert.Equal(t, 2, strings.Count(doc, "<section>"))
+	assert.NotContains(t, doc, "src=")
+	assert.Contains(t, doc, "<tr><td>&lt;a&gt;.script</td><td>10000</td><td>0</td><td>100.000</td><td>5000.505</td><td>9904.127</td></tr>")
+
+	start := strings.Index(doc, `<script type="application/json" id="data-1">`) + len(`<script type="application/json" id="data-1">`)
+	var chart htmlChart
+	assert.NoError(t, json.Unmarshal([]byte(doc[start:start+strings.Index(doc[start:], "</script>")]), &chart))
+	assert.Equal(t, "<a>.script", chart.Series[0].Name)
+	assert.Equal(t, [2]float64{100, 10002.431}, chart.Series[0].Points[len(chart.Series[0].Points)-1])
+
+	assert.NotContains(t, doc, "</html>")
+	assert.NoError(t, out.Close())
+	assert.True(t, strings.HasSuffix(buf.String(), "</section>\n</body>\n</html>\n"))
+}
+
+func TestOnelineOutputWritesOneLinePerResult(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &OnelineOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+	assert.NoError(t, out.ReportInterval(IntervalResult{Result: newTestResult(t, "db", "a.script")}))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.NoError(t, out.ReportThroughput(newTestResult(t, "", "a.script")))
+	assert.Equal(t, "-c_1 tps=100.000 p50=5001.215ms p99=9904.127ms err=0 db=db\n"+
+		"-c_1 tps=100.000 p50=5001.215ms p99=9904.127ms err=0 db=<default>\n", buf.String())
+
+	// Runs against the same database are told apart by their scenario
+	buf.Reset()
+	other := newTestResult(t, "db", "a.script")
+	other.Scenario = "-c 8 -w builtin:tpcb-like"
+	assert.NoError(t, out.ReportLatency(other))
+	other.Scenario = ""
+	assert.NoError(t, out.ReportLatency(other))
+	assert.Equal(t, "-c_8_-w_builtintpcb-like tps=100.000 p50=5001.215ms p99=9904.127ms err=0 db=db\n"+
+		"<unknown> tps=100.000 p50=5001.215ms p99=9904.127ms err=0 db=db\n", buf.String())
+}
+
+func TestNewOutputWritesToGivenStreams(t *testing.T) {
+	outStream, errStream := &bytes.Buffer{}, &bytes.Buffer{}
+	out, err := NewOutput("oneline", OutputOptions{OutStream: outStream, ErrStream: errStream})
+	assert.NoError(t, err)
+
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	out.Errorf("oh no")
+	assert.Equal(t, "-c_1 tps=100.000 p50=5001.215ms p99=9904.127ms err=0 db=db\n", outStream.String())
+	assert.Equal(t, "ERROR: oh no\n", errStream.String())
+}
+
+func TestInteractiveLatencyNotesHistogramPrecision(t *testing.T) {
+	assert.Equal(t, "3 significant figures, between 1µs and 1h0m0s", describePrecision(newLatencyHistogram()))
+
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.Contains(t, buf.String(), "\nLatencies are accurate to 3 significant figures, between 1µs and 1h0m0s\n")
+}
+
+func TestGobenchOutputWritesBenchmarkLines(t *testing.T) {
+	result := newTestResult(t, "db", "builtin:tpcb-like")
+	result.Scripts["my script"] = newTestResult(t, "db", "my script").Scripts["my script"]
+	result.Config.Clients = 4
+	buf := &bytes.Buffer{}
+	out := &GobenchOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Equal(t, "BenchmarkNeobench/builtin:tpcb-like-4\t10000\t5000504758 ns/op\t100.000 tps\t5001215000 p50-ns/op\t9904127000 p99-ns/op\t0 failed\n"+
+		"BenchmarkNeobench/my_script-4\t10000\t5000504758 ns/op\t100.000 tps\t5001215000 p50-ns/op\t9904127000 p99-ns/op\t0 failed\n"+
+		"BenchmarkNeobench/total-4\t20000\t5000504758 ns/op\t200.000 tps\t5001215000 p50-ns/op\t9904127000 p99-ns/op\t0 failed\n", buf.String())
+}
+
+func TestFanInCombinesWorkersProgress(t *testing.T) {
+	rec := &RecordingOutput{}
+	out := &FanInProgressOutput{Output: rec}
+
+	out.ReportProgress(ProgressReport{Section: "init", Step: "load", Completeness: 0.5, Worker: "0"})
+	out.ReportProgress(ProgressReport{Section: "init", Step: "load", Completeness: 1, Worker: "1"})
+	out.ReportProgress(ProgressReport{Section: "init", Step: "load", Completeness: 0.1, Worker: "0"})
+	// Shares given, so workers not heard from yet count as not started
+	out.ReportProgress(ProgressReport{Section: "init", Step: "index", Completeness: 1, Worker: "0", Share: 0.25})
+	out.ReportProgress(ProgressReport{Section: "run", Step: "warmup", Completeness: 0.3})
+
+	completeness := make([]float64, 0)
+	for _, report := range rec.Progress {
+		completeness = append(completeness, report.Completeness)
+	}
+	assert.InDeltaSlice(t, []float64{0.5, 0.75, 0.55, 0.25, 0.3}, completeness, 0.0001)
+	assert.Equal(t, []string{"0", "1"}, []string{rec.Progress[2].Workers[0].Worker, rec.Progress[2].Workers[1].Worker})
+	assert.Equal(t, "", rec.Progress[2].Worker)
+}
+
+func TestInteractiveListsProgressByWorker(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &FanInProgressOutput{Output: &InteractiveOutput{ProgressBar: true, ProgressByWorker: true, OutStream: &bytes.Buffer{}, ErrStream: buf}}
+
+	out.ReportProgress(ProgressReport{Section: "init", Step: "load", Completeness: 0.5, Worker: "a"})
+	assert.True(t, strings.HasPrefix(buf.String(), "[init][load] 50.00%"), buf.String())
+	assert.True(t, strings.HasSuffix(buf.String(), "\n  [a] 50.00%\n"), buf.String())
+}
+
+func TestProgressByWorkerListsEachClientOfARunByCount(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out, err := NewOutput("interactive", OutputOptions{OutStream: &bytes.Buffer{}, ErrStream: buf, ProgressInterval: 5 * time.Millisecond, ProgressByWorker: true})
+	assert.NoError(t, err)
+	shares := TotalTransactionsToTransactionsPerClient(2, 5)
+	recorders := []*ResultRecorder{NewResultRecorder(0, HistogramConfig{}), NewResultRecorder(1, HistogramConfig{})}
+	assert.NoError(t, recorders[0].record("a.script", time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, recorders[1].record("a.script", time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, recorders[1].record("a.script", time.Millisecond, uowOutcome{failureGroup: "oops"}))
+
+	ReportClientProgress(out, recorders, shares)
+
+	// The first client is written as soon as it reports, being a new step, and the second comes with the next line
+	assert.Equal(t, "[run][transactions] 20.00%\n  [client 0] 33.33%\n", buf.String())
+	buf.Reset()
+	time.Sleep(10 * time.Millisecond)
+	ReportClientProgress(out, recorders, shares)
+	assert.Equal(t, "[run][transactions] 60.00%\n  [client 0] 33.33%\n  [client 1] 100.00%\n", buf.String())
+}
+
+func TestFlushingOutputFlushesAfterEachResult(t *testing.T) {
+	buffered := &flushCountingWriter{}
+	out := &FlushingOutput{Output: &OnelineOutput{OutStream: buffered, ErrStream: &bytes.Buffer{}}, Stream: buffered}
+
+	out.ReportProgress(ProgressReport{Section: "init", Step: "load"})
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.NoError(t, out.ReportThroughput(newTestResult(t, "db", "a.script")))
+	assert.Equal(t, 2, buffered.flushes)
+
+	// Syncing is skipped for terminals and pipes, and works for files, so whatever stdout is this passes
+	assert.NoError(t, flushStream(os.Stdout))
+	assert.NoError(t, flushStream(&bytes.Buffer{}))
+}
+
+type flushCountingWriter struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (w *flushCountingWriter) Flush() error {
+	w.flushes++
+	return nil
+}
+
+func TestResultsIncludeMeasurementWindow(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	result.StartTime = time.Date(2020, 1, 1, 1, 1, 1, 0, time.FixedZone("CET", 3600))
+	result.EndTime = result.StartTime.Add(90 * time.Second)
+
+	buf := &bytes.Buffer{}
+	interactive := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, interactive.ReportLatency(result))
+	assert.Contains(t, buf.String(), "Measured: 2020-01-01T00:01:01.000Z to 2020-01-01T00:02:31.000Z\n")
+
+	buf = &bytes.Buffer{}
+	out := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}}
+	assert.NoError(t, out.ReportLatency(result))
+	assert.True(t, strings.HasSuffix(buf.String(), ",2020-01-01T00:01:01.000Z,2020-01-01T00:02:31.000Z,0,5003,0\n"), buf.String())
+
+	later := newTestResult(t, "db", "a.script")
+	later.StartTime, later.EndTime = result.EndTime, result.EndTime.Add(time.Minute)
+	merged, err := MergeResults([]Result{later, result})
+	assert.NoError(t, err)
+	assert.Equal(t, result.StartTime, merged.StartTime)
+	assert.Equal(t, later.EndTime, merged.EndTime)
+}
+
+func TestCompareOutputWritesOneTableOnClose(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &CompareOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{99}, SortBy: "rate"}
+	slow := newTestResult(t, "db", "a.script")
+	slow.Scenario = "-c 1"
+	fast := newTestResult(t, "", "a.script")
+	fast.Scenario = "-c 16"
+	fast.Scripts["a.script"].Rate = 1600
+
+	assert.NoError(t, out.ReportLatency(slow))
+	assert.NoError(t, out.ReportThroughput(fast))
+	assert.Equal(t, "", buf.String())
+
+	assert.NoError(t, out.Close())
+	assert.Equal(t, ""+
+		"Scenario  Database   Rate (tps)  Mean (ms)  P99.000 (ms)\n"+
+		"-c 16     <default>    1600.000   5000.505      9904.127\n"+
+		"-c 1      db            100.000   5000.505      9904.127\n", buf.String())
+}
+
+func TestValidateCompareSort(t *testing.T) {
+	for _, metric := range []string{"", "scenario", "rate", "mean", "p99", "p99.9", "p0"} {
+		assert.NoError(t, ValidateCompareSort(metric), metric)
+	}
+	for _, metric := range []string{"tps", "p", "p101", "99"} {
+		assert.Error(t, ValidateCompareSort(metric), metric)
+	}
+}
+
+func TestPrecisionSetsDecimalPlacesOfLatenciesAndRates(t *testing.T) {
+	whole, tooMany := 0, 10
+	_, err := NewOutput("oneline", OutputOptions{Precision: &tooMany})
+	assert.Error(t, err)
+
+	outStream := &bytes.Buffer{}
+	out, err := NewOutput("oneline", OutputOptions{OutStream: outStream, ErrStream: &bytes.Buffer{}, Precision: &whole})
+	assert.NoError(t, err)
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.Equal(t, "-c_1 tps=100 p50=5001ms p99=9904ms err=0 db=db\n", outStream.String())
+
+	places := 1
+	buf := &bytes.Buffer{}
+	csvOut := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}, Precision: &places}
+	assert.NoError(t, csvOut.ReportLatency(newTestResult(t, "db", "a.script")))
+	// Counts aren't latencies or rates, so they keep their decimals
+	assert.True(t, strings.HasPrefix(buf.String(), `0,0.0,0.000,"db","a.script",100.0,10000.000,0.000,0.000,5000.5,2886.8,5001.2,`), buf.String())
+
+	places = 5
+	buf.Reset()
+	interactive := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{99}, Precision: &places}
+	assert.NoError(t, interactive.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.Contains(t, buf.String(), "Successful Transactions: 10000 (100.00000 per second)")
+	assert.Contains(t, buf.String(), "P99.000: 9904.12700ms")
+}
+
+func TestHeatmapOutputWritesRowPerInterval(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &HeatmapOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50, 99}}
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	busy := IntervalResult{Result: newTestResult(t, "db", "a.script"), Start: start, End: start.Add(time.Second)}
+	idle := IntervalResult{Result: NewResult("db", "-c 1"), Start: busy.End, End: busy.End.Add(time.Second)}
+	idle.Scripts["a.script"] = &ScriptResult{ScriptName: "a.script", Latencies: newLatencyHistogram()}
+
+	assert.NoError(t, out.ReportInterval(busy))
+	assert.NoError(t, out.ReportInterval(idle))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.Equal(t, ""+
+		"time,elapsed_seconds,transactions,p50_ms,p99_ms\n"+
+		"2020-06-01T12:00:01.000Z,1.000,10000,5001.215,9904.127\n"+
+		"2020-06-01T12:00:02.000Z,2.000,0,,\n", buf.String())
+
+	// A later run carries on under the same header, rather than starting over at zero
+	buf.Reset()
+	out.Reset()
+	later := IntervalResult{Result: newTestResult(t, "db", "a.script"), Start: start.Add(time.Minute), End: start.Add(time.Minute + time.Second)}
+	assert.NoError(t, out.ReportInterval(later))
+	assert.Equal(t, "2020-06-01T12:01:01.000Z,61.000,10000,5001.215,9904.127\n", buf.String())
+}
+
+func TestPoolWaitIsReportedWhenTracked(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	report := func() (string, string) {
+		buf, doc := &bytes.Buffer{}, &bytes.Buffer{}
+		assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+		assert.NoError(t, (&JsonOutput{OutStream: doc, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+		return buf.String(), doc.String()
+	}
+	text, doc := report()
+	assert.NotContains(t, text, "Connection pool")
+	assert.NotContains(t, doc, "pool_wait")
+
+	// Nothing to say if no transaction got far enough to measure
+	result.PoolWait = &PoolWait{Size: 100}
+	text, _ = report()
+	assert.NotContains(t, text, "Connection")
+
+	result.PoolWait = &PoolWait{Size: 2, Transactions: 10000, Mean: 1500 * time.Microsecond, Max: 4 * time.Millisecond}
+	text, doc = report()
+	assert.Contains(t, text, "Connection acquisition: mean 1.5ms, max 4ms over 10000 transactions, with 2 connections per server; "+
+		"this includes waiting for a free connection, if it's a large part of latency consider a larger --pool-size\n")
+	assert.Contains(t, doc, `"pool_wait":{"size":2,"transactions":10000,"mean_acquisition_ms":1.5,"max_acquisition_ms":4}`)
+}
+
+func TestSlowestTransactionsAreListed(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	result.StartTime = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	result.Slowest = []SlowTransaction{
+		{ScriptName: "a.script", Start: result.StartTime.Add(12 * time.Second), Latency: 4 * time.Millisecond, Parameters: "id=2"},
+		{ScriptName: "a.script", Start: result.StartTime.Add(1500 * time.Millisecond), Latency: 2 * time.Millisecond, Succeeded: true},
+	}
+	buf, doc := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.NoError(t, (&JsonOutput{OutStream: doc, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.Contains(t, buf.String(), "\nSlowest transactions:\n"+
+		"  1. 4.000ms a.script, failed, started at +12s, id=2\n"+
+		"  2. 2.000ms a.script, succeeded, started at +1.5s\n\n")
+	assert.Contains(t, doc.String(), `"slowest_transactions":[`+
+		`{"script":"a.script","start":"2020-06-01T12:00:12Z","latency_ms":4,"succeeded":false,"parameters":"id=2"},`+
+		`{"script":"a.script","start":"2020-06-01T12:00:01.5Z","latency_ms":2,"succeeded":true}]`)
+
+	result.Slowest = nil
+	buf.Reset()
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.NotContains(t, buf.String(), "Slowest")
+}
+
+func TestThroughputDistributionIsReportedForSampledRuns(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	result.SampleRates = []float64{100}
+	_, ok := result.SampleRatePercentile(50)
+	assert.False(t, ok)
+	buf := &bytes.Buffer{}
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportThroughput(result))
+	assert.NotContains(t, buf.String(), "Throughput distribution")
+
+	// Ten samples, one of them a stall
+	result.SampleRates = []float64{1000, 950, 0, 1010, 990, 1020, 980, 1000, 1005, 995}
+	p10, ok := result.SampleRatePercentile(10)
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, p10)
+	p25, _ := result.SampleRatePercentile(25)
+	assert.Equal(t, 980.0, p25)
+	buf.Reset()
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportThroughput(result))
+	assert.Contains(t, buf.String(), "\nThroughput distribution over 10 samples, Pn is the rate n% of them were at or below:\n"+
+		"  P01.000: 0.000 per second\n"+
+		"  P10.000: 0.000 per second\n"+
+		"  P25.000: 980.000 per second\n"+
+		"  P50.000: 995.000 per second\n"+
+		"  P75.000: 1005.000 per second\n"+
+		"  P90.000: 1010.000 per second\n"+
+		"  P99.000: 1020.000 per second\n\n")
+
+	doc := &bytes.Buffer{}
+	assert.NoError(t, (&JsonOutput{OutStream: doc, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.Contains(t, doc.String(), `"rate_percentiles":{"p1":0,"p10":0,"p25":980,"p50":995,"p75":1005,"p90":1010,"p99":1020}`)
+}
+
+func TestKeyvalueOutputWritesOneMetricPerLine(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out, err := NewOutput("keyvalue", OutputOptions{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50, 99}, RunId: "run-1"})
+	assert.NoError(t, err)
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	empty := NewResult("", "-c 1\n-w x")
+	empty.Scripts["a.script"] = &ScriptResult{ScriptName: "a.script", Failed: 2, Latencies: newLatencyHistogram()}
+	assert.NoError(t, out.ReportThroughput(empty))
+	assert.Equal(t, ""+
+		"database: db\n"+
+		"scenario: -c 1\n"+
+		"run_id: run-1\n"+
+		"warmup: false\n"+
+		"succeeded: 10000\n"+
+		"failed: 0\n"+
+		"tps: 100.000\n"+
+		"mean_ms: 5000.505\n"+
+		"p50_ms: 5001.215\n"+
+		"p99_ms: 9904.127\n"+
+		"max_ms: 10002.431\n"+
+		"\n"+
+		"database: <default>\n"+
+		"scenario: -c 1 -w x\n"+
+		"run_id: run-1\n"+
+		"warmup: false\n"+
+		"succeeded: 0\n"+
+		"failed: 2\n"+
+		"tps: 0.000\n"+
+		"mean_ms: \n"+
+		"p50_ms: \n"+
+		"p99_ms: \n"+
+		"max_ms: \n", buf.String())
+}
+
+func TestKeyvalueOutputKeysDefaultPercentilesAndTellsWarmupApart(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out, err := NewOutput("keyvalue", OutputOptions{OutStream: buf, ErrStream: &bytes.Buffer{}, Warmup: true})
+	assert.NoError(t, err)
+	warmup := newTestResult(t, "db", "a.script")
+	warmup.Warmup = true
+	assert.NoError(t, out.ReportLatency(warmup))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+
+	results := strings.Split(buf.String(), "\n\n")
+	assert.Len(t, results, 2)
+	assert.Contains(t, results[0], "warmup: true\n")
+	assert.Contains(t, results[1], "warmup: false\n")
+	// As in the README, so `awk '/^p99_ms:/'` and friends find them
+	assert.Contains(t, results[1], "p50_ms: 5001.215\np90_ms: 9003.007\np99_ms: 9904.127\np99900_ms: 9994.239\n")
+}
+
+func TestTextFormatsCarryTheRunId(t *testing.T) {
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	for format, expected := range map[string]string{
+		"oneline": "err=0 db=db run_id=run-1\n",
+		"html":    "<p>Database: db, run ID run-1",
+		"gobench": "run_id: run-1\nBenchmarkNeobench/",
+		"hlog":    "#[Histogram log format version 1.3]\n#[RunId: run-1]\n",
+	} {
+		buf := &bytes.Buffer{}
+		out, err := NewOutput(format, OutputOptions{OutStream: buf, ErrStream: &bytes.Buffer{}, RunId: "run-1"})
+		assert.NoError(t, err)
+		assert.NoError(t, out.ReportInterval(IntervalResult{Result: newTestResult(t, "db", "a.script"), Start: start, End: start.Add(time.Second)}))
+		assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+		assert.NoError(t, out.Close())
+		assert.Contains(t, buf.String(), expected, format)
+	}
+}
+
+func TestHistlogOutputWritesIntervalLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &HistlogOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	busy := IntervalResult{Result: newTestResult(t, "db", "a.script"), Start: start, End: start.Add(time.Second)}
+	two := IntervalResult{Result: newTestResult(t, "db", "a.script"), Start: busy.End, End: busy.End.Add(time.Second)}
+	two.Scripts["b,c.script"] = &ScriptResult{ScriptName: "b,c.script", Latencies: newLatencyHistogram()}
+
+	assert.NoError(t, out.ReportInterval(busy))
+	assert.NoError(t, out.ReportInterval(two))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Equal(t, []string{
+		"#[Logged with neobench, latencies in microseconds]",
+		"#[Histogram log format version 1.3]",
+		"#[StartTime: 1591012800.000 (seconds since epoch), Mon Jun 01 12:00:00 UTC 2020]",
+		"#[BaseTime: 1591012800.000 (seconds since epoch)]",
+		`"StartTimestamp","Interval_Length","Interval_Max","Interval_Compressed_Histogram"`,
+	}, lines[:5])
+	assert.Len(t, lines, 9)
+	assert.True(t, strings.HasPrefix(lines[5], "0.000,1.000,10002.431,"), lines[5])
+	assert.True(t, strings.HasPrefix(lines[6], "1.000,1.000,10002.431,"), lines[6])
+	assert.True(t, strings.HasPrefix(lines[7], "Tag=a.script,1.000,1.000,10002.431,"), lines[7])
+	assert.True(t, strings.HasPrefix(lines[8], "Tag=b_c.script,1.000,1.000,0.000,"), lines[8])
+
+	// Decoded, the line has every latency the interval recorded, bucket for bucket
+	fields := strings.Split(lines[5], ",")
+	compressed, err := base64.StdEncoding.DecodeString(fields[3])
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0x1c849314), binary.BigEndian.Uint32(compressed))
+	assert.Equal(t, len(compressed)-8, int(binary.BigEndian.Uint32(compressed[4:])))
+	r, err := zlib.NewReader(bytes.NewReader(compressed[8:]))
+	assert.NoError(t, err)
+	encoded, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0x1c849313), binary.BigEndian.Uint32(encoded))
+	assert.Equal(t, len(encoded)-40, int(binary.BigEndian.Uint32(encoded[4:])))
+	assert.Equal(t, uint32(3), binary.BigEndian.Uint32(encoded[12:]))
+	assert.Equal(t, uint64(1), binary.BigEndian.Uint64(encoded[16:]))
+	assert.Equal(t, uint64(60*60*1000000), binary.BigEndian.Uint64(encoded[24:]))
+
+	expected := busy.Total().Latencies.Export().Counts
+	var counts []int64
+	payload := bytes.NewReader(encoded[40:])
+	for payload.Len() > 0 {
+		count, err := binary.ReadVarint(payload)
+		assert.NoError(t, err)
+		if count < 0 {
+			counts = append(counts, make([]int64, -count)...)
+		} else {
+			counts = append(counts, count)
+		}
+	}
+	assert.Equal(t, expected[:len(counts)], counts)
+	for _, count := range expected[len(counts):] {
+		assert.Equal(t, int64(0), count)
+	}
+}
+
+func TestCheckRateStabilityWarnsWhenRateSwings(t *testing.T) {
+	errStream := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: errStream}
+	result := newTestResult(t, "db", "a.script")
+
+	CheckRateStability(out, result)
+	result.SampleRates = []float64{0}
+	CheckRateStability(out, result)
+	result.SampleRates = []float64{950, 1050, 1000}
+	CheckRateStability(out, result)
+	assert.Equal(t, "", errStream.String())
+
+	result.SampleRates = []float64{500, 1500, 500, 1500}
+	cv, ok := result.RateVariation()
+	assert.True(t, ok)
+	assert.InDelta(t, 0.5, cv, 0.0001)
+	CheckRateStability(out, result)
+	assert.Equal(t, "WARN: transactions per second varied by 50.0% between samples (coefficient of variation); "+
+		"the mean rate may not be representative\n", errStream.String())
+
+	buf := &bytes.Buffer{}
+	interactive := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, interactive.ReportThroughput(result))
+	assert.Contains(t, buf.String(), "Rate stability: 50.0% coefficient of variation over 4 samples\n")
+}
+
+func TestReportPlanDescribesRunWithoutResults(t *testing.T) {
+	plan := Plan{
+		Scenario: "-c 4 -l",
+		Config:   RunConfig{Clients: 4, TargetRate: 100, Duration: time.Minute},
+		Scripts: []PlannedScript{
+			{Name: "builtin:tpcb-like", Weight: 3},
+			{Name: "reads.script", Weight: 1, Readonly: true},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	interactive := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, interactive.ReportPlan(plan))
+	assert.Equal(t, "== Plan ==\n"+
+		"Scenario: -c 4 -l\n"+
+		"Database: <default>\n"+
+		"Clients: 4\n"+
+		"Duration: 1m0s\n"+
+		"Mode: latency, at 100.000 transactions per second in total\n"+
+		"Scripts:\n"+
+		"  [builtin:tpcb-like]: weight 3, 75.0% of transactions\n"+
+		"  [reads.script]: weight 1, 25.0% of transactions, read-only\n", buf.String())
+
+	buf.Reset()
+	csvOut := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, csvOut.ReportPlan(plan))
+	assert.Equal(t, "clients,target_transactions_per_second,duration_seconds,transactions,db,script,weight,share,readonly,init\n"+
+		`4,100.000,60.000,0,"","builtin:tpcb-like",3.000,0.750,false,false`+"\n"+
+		`4,100.000,60.000,0,"","reads.script",1.000,0.250,true,false`+"\n", buf.String())
+
+	buf.Reset()
+	jsonOut := &JsonOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, jsonOut.ReportPlan(plan))
+	assert.Equal(t, `{"database":"<default>","scenario":"-c 4 -l","clients":4,"target_rate":100,"duration_seconds":60,"transactions":0,`+
+		`"init":false,"scripts":[{"script":"builtin:tpcb-like","weight":3,"share":0.75,"readonly":false},`+
+		`{"script":"reads.script","weight":1,"share":0.25,"readonly":true}]}`+"\n", buf.String())
+}
+
+func TestReportPlanSaysHowManyTransactionsACountedRunGoesFor(t *testing.T) {
+	plan := Plan{
+		Scenario: "-c 4 --transactions 100",
+		Config:   RunConfig{Clients: 4, Transactions: 100},
+		Scripts:  []PlannedScript{{Name: "builtin:tpcb-like", Weight: 1}},
+	}
+
+	buf := &bytes.Buffer{}
+	interactive := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, interactive.ReportPlan(plan))
+	assert.Contains(t, buf.String(), "Clients: 4\nTransactions: 100\nMode: throughput")
+	assert.NotContains(t, buf.String(), "Duration")
+
+	buf.Reset()
+	csvOut := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, csvOut.ReportPlan(plan))
+	assert.Contains(t, buf.String(), "\n4,0.000,0.000,100,\"\",")
+
+	buf.Reset()
+	jsonOut := &JsonOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, jsonOut.ReportPlan(plan))
+	assert.Contains(t, buf.String(), `"duration_seconds":0,"transactions":100,`)
+}
+
+func TestResultsWithoutLatenciesSayNoneWereRecorded(t *testing.T) {
+	result := NewResult("db", "-c 1")
+	result.Scripts["a.script"] = &ScriptResult{ScriptName: "a.script", Failed: 10, Latencies: newLatencyHistogram()}
+
+	buf := &bytes.Buffer{}
+	interactive := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, interactive.ReportLatency(result))
+	assert.Contains(t, buf.String(), "\nNo successful transactions recorded, so there are no latencies to report\n")
+	assert.NotContains(t, buf.String(), "Latency distribution")
+
+	buf.Reset()
+	csvOut := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}}
+	assert.NoError(t, csvOut.ReportLatency(result))
+	assert.True(t, strings.HasPrefix(buf.String(), `0,0.000,0.000,"db","a.script",0.000,0.000,10.000,100.000,,,,`), buf.String())
+
+	buf.Reset()
+	jsonOut := &JsonOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, jsonOut.ReportLatency(result))
+	assert.Contains(t, buf.String(), `"latency":null`)
+
+	errStream := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: errStream}
+	CheckSucceeded(out, newTestResult(t, "db", "a.script"))
+	assert.False(t, out.ErrorsReported())
+	CheckSucceeded(out, result)
+	assert.True(t, out.ErrorsReported())
+	assert.Equal(t, "ERROR: no successful transactions recorded, so there are no latencies to report\n", errStream.String())
+}
+
+func TestNewOutputStreamsToDestination(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer tcp.Close()
+	unix, err := net.Listen("unix", filepath.Join(dir, "results.sock"))
+	assert.NoError(t, err)
+	defer unix.Close()
+
+	for destination, listener := range map[string]net.Listener{
+		"tcp://" + tcp.Addr().String():   tcp,
+		"unix://" + unix.Addr().String(): unix,
+	} {
+		received := make(chan string)
+		go func(listener net.Listener) {
+			conn, err := listener.Accept()
+			if err != nil {
+				received <- err.Error()
+				return
+			}
+			data, _ := ioutil.ReadAll(conn)
+			received <- string(data)
+		}(listener)
+
+		errStream := &bytes.Buffer{}
+		out, err := NewOutput("oneline", OutputOptions{Destination: destination, ErrStream: errStream})
+		assert.NoError(t, err, destination)
+		assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+		// Reading only ends once the connection is closed
+		assert.NoError(t, out.Close())
+		assert.Equal(t, "-c_1 tps=100.000 p50=5001.215ms p99=9904.127ms err=0 db=db\n", <-received, destination)
+		assert.Equal(t, "", errStream.String())
+	}
+
+	_, err = NewOutput("csv", OutputOptions{Destination: "unix://" + filepath.Join(dir, "nobody-listening.sock")})
+	assert.Error(t, err)
+	_, err = NewOutput("csv", OutputOptions{Destination: "http://localhost:8080"})
+	assert.Error(t, err)
+}
+
+func TestInteractiveSummariesFormatWithoutWriting(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+
+	summary := out.FormatLatencySummary(result)
+	assert.Empty(t, buf.String())
+	assert.Contains(t, summary, "P50.000: 5001.215ms")
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Equal(t, summary, buf.String())
+
+	buf.Reset()
+	summary = out.FormatThroughputSummary(result)
+	assert.Empty(t, buf.String())
+	assert.NoError(t, out.ReportThroughput(result))
+	assert.Equal(t, summary, buf.String())
+}
+
+func TestInterpolatedPercentilesTellTheTailOfShortRunsApart(t *testing.T) {
+	histo := newLatencyHistogram()
+	for i := int64(1); i <= 100; i++ {
+		assert.NoError(t, histo.RecordValue(i*1000))
+	}
+	assert.Equal(t, valueAtPercentile(histo, 99.5), valueAtPercentile(histo, 99.9))
+
+	assert.InDelta(t, 99500, interpolatedValueAtPercentile(histo, 99.5), 100)
+	assert.InDelta(t, 99900, interpolatedValueAtPercentile(histo, 99.9), 100)
+	assert.InDelta(t, 50500, interpolatedValueAtPercentile(histo, 50), 100)
+	assert.Equal(t, float64(histo.Min()), interpolatedValueAtPercentile(histo, 0))
+	assert.Equal(t, float64(histo.Max()), interpolatedValueAtPercentile(histo, 100))
+
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, InterpolatePercentiles: true, Percentiles: []float64{50}}
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.Contains(t, buf.String(), "Latency distribution (interpolated):\n    P50.000: ")
+}
+
+func TestInteractiveTablesAlignToTheWidestValue(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Tables: true, Percentiles: []float64{50, 99},
+		Targets: PercentileTargets{99: 20 * time.Millisecond}}
+
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+
+	assert.Contains(t, buf.String(), ""+
+		"  +----------------------------+----------------------------------------+\n"+
+		"  | Successful Transactions    | 10000 (100.000 per second)             |\n"+
+		"  | Max                        | 10002.431ms                            |\n"+
+		"  | Min                        | 1.000ms                                |\n"+
+		"  | Mean                       | 5000.505ms                             |\n"+
+		"  | Stddev                     | 2886.752ms                             |\n"+
+		"  | Standard error of the mean | 28.868ms                               |\n"+
+		"  | 95% confidence interval    | 4943.924ms - 5057.085ms                |\n"+
+		"  +----------------------------+----------------------------------------+\n"+
+		"  | P50.000                    | 5001.215ms                             |\n"+
+		"  | P99.000                    | 9904.127ms (n=105) ✗ (target 20.000ms) |\n"+
+		"  +----------------------------+----------------------------------------+\n")
+}
+
+func TestSummaryOnlyLeavesTheDistributionOut(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, SummaryOnly: true, Sparklines: true}
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.Contains(t, buf.String(), ""+
+		"  Max: 10002.431ms, Min: 1.000ms, Mean: 5000.505ms, Stddev: 2886.752ms\n"+
+		"  Standard error of the mean: 28.868ms, 95% confidence interval of the mean: 4943.924ms - 5057.085ms\n"+
+		"\n"+
+		"Latencies are accurate")
+	assert.NotContains(t, buf.String(), "Latency distribution")
+
+	buf.Reset()
+	out.Tables = true
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.Contains(t, buf.String(), ""+
+		"  | 95% confidence interval    | 4943.924ms - 5057.085ms    |\n"+
+		"  +----------------------------+----------------------------+\n")
+	assert.NotContains(t, buf.String(), "P50")
+}
+
+func TestInteractiveSparklineShowsShapeOfLatencies(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Sparklines: true}
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	// Latencies are spread evenly from 1 to 10000ms, so every bucket is about as full as the next
+	assert.Contains(t, buf.String(), "Latency distribution: ████████████████████ (1.000ms to 10002.431ms)\n")
+
+	// Most transactions fast and a few slow ones make a spike at the start and a blip at the end
+	skewed := hdrhistogram.New(1, 60*60*1000000, 3)
+	for i := 0; i < 90; i++ {
+		assert.NoError(t, skewed.RecordValue(1000))
+	}
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, skewed.RecordValue(20000))
+	}
+	assert.Equal(t, "█▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▂", histogramSparkline(skewed, 20))
+
+	// Not on a terminal, so there's nothing to draw it on
+	buf.Reset()
+	out = &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.Contains(t, buf.String(), "Latency distribution:\n")
+	assert.NotContains(t, buf.String(), "█")
+}
+
+func TestScenarioSlugIsSafeForFilenames(t *testing.T) {
+	assert.Equal(t, "-c_4_-w_builtintpcb-like", ScenarioSlug("-c 4 -w builtin:tpcb-like"))
+	assert.Equal(t, "-w_.scriptsread.script_-s_10", ScenarioSlug("-w ./scripts/Read.script  -s\t10"))
+	assert.Equal(t, "", ScenarioSlug(""))
+
+	result := newTestResult(t, "db", "a.script")
+	result.Scenario = "-c 4 -w ../Write Path.script"
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, ScenarioSlug: true}
+	assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost", result.Scenario))
+	assert.NoError(t, out.ReportLatency(result))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.True(t, strings.HasSuffix(lines[0], ",p100_transactions,errors,scenario_slug"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], `,"-c_4_-w_..write_path.script"`), lines[1])
+
+	// The name people read is left as it was
+	assert.Equal(t, "-c 4 -w ../Write Path.script", result.Scenario)
+}
+
+func TestDiagnosticsReportGCOfTheRun(t *testing.T) {
+	start := ReadMemStats()
+	garbage := make([][]byte, 0, 100)
+	for i := 0; i < 100; i++ {
+		garbage = append(garbage, make([]byte, 1024))
+	}
+	runtime.GC()
+	stats := ProcessStatsSince(start)
+	assert.True(t, stats.NumGC >= 1, stats.NumGC)
+	assert.True(t, stats.TotalAlloc >= uint64(100*1024), stats.TotalAlloc)
+	assert.True(t, stats.PauseTotal >= stats.MaxPause, stats)
+	assert.Equal(t, 100, len(garbage))
+
+	result := newTestResult(t, "db", "a.script")
+	buf := &bytes.Buffer{}
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.NotContains(t, buf.String(), "Client diagnostics")
+
+	result.Process = &ProcessStats{HeapAlloc: 3 * 1024 * 1024 / 2, TotalAlloc: 512, NumGC: 4,
+		PauseTotal: 1500 * time.Microsecond, MaxPause: 900 * time.Microsecond}
+	buf.Reset()
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.Contains(t, buf.String(), "Client diagnostics:\n"+
+		"  Heap: 1.5MiB in use, 512B allocated during the run\n"+
+		"  GC: 4 collections, 1.5ms paused in total, longest pause 900µs\n")
+}
+
+func TestErrorsAreBrokenDownByCategory(t *testing.T) {
+	deadlock := errors.New("Server error: [Neo.TransientError.Transaction.DeadlockDetected] waiting for a lock")
+	constraint := errors.New("Server error: [Neo.ClientError.Schema.ConstraintValidationFailed] already exists")
+	syntax := errors.New("Server error: [Neo.ClientError.Statement.SyntaxError] invalid input")
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	assert.Equal(t, ErrorTransient, ClassifyError(deadlock))
+	assert.Equal(t, ErrorConstraint, ClassifyError(constraint))
+	assert.Equal(t, ErrorSyntax, ClassifyError(syntax))
+	assert.Equal(t, ErrorConnection, ClassifyError(errors.Wrap(refused, "worker 1 crashed")))
+	assert.Equal(t, ErrorOther, ClassifyError(errors.New("oh no")))
+
+	result := newTestResult(t, "db", "a.script")
+	result.Scripts["a.script"].Failed = 5
+	result.FailedByErrorGroup = map[string]FailureGroup{
+		"Neo.TransientError.Transaction.DeadlockDetected":   {Count: 3, FirstFailure: deadlock},
+		"Neo.ClientError.Schema.ConstraintValidationFailed": {Count: 1, FirstFailure: constraint},
+		"unknown": {Count: 1, FirstFailure: refused},
+	}
+	buf, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: errBuf}
+	out.ReportError(ErrorConnection, errors.Wrap(refused, "worker 1 crashed"))
+	assert.True(t, out.ErrorsReported())
+	assert.Equal(t, "ERROR: connection error: worker 1 crashed: dial tcp: connection refused\n", errBuf.String())
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Contains(t, buf.String(), "  Failed transactions by category:\n"+
+		"    connection: 1\n"+
+		"    transient: 3\n"+
+		"    constraint: 1\n"+
+		"  Errors reported by category:\n"+
+		"    connection: 1\n")
+	assert.NotContains(t, buf.String(), "syntax:")
+
+	buf.Reset()
+	jsonOut := &JsonOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, jsonOut.ReportLatency(result))
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, map[string]interface{}{"connection": 1.0, "transient": 3.0, "constraint": 1.0}, doc["errors_by_category"])
+	assert.Nil(t, doc["reported_errors_by_category"])
+
+	buf.Reset()
+	jsonOut.ReportError(ErrorConnection, errors.Wrap(refused, "worker 1 crashed"))
+	assert.NoError(t, jsonOut.ReportLatency(result))
+	assert.NoError(t, json.Unmarshal(buf.Bytes()[bytes.LastIndexByte(buf.Bytes()[:buf.Len()-1], '\n')+1:], &doc))
+	assert.Equal(t, map[string]interface{}{"connection": 1.0}, doc["reported_errors_by_category"])
+}
+
+func TestDeterministicOutputIsTheSameForTheSameResults(t *testing.T) {
+	run := func(name string, start time.Time) string {
+		buf := &bytes.Buffer{}
+		out, err := NewOutput(name, OutputOptions{OutStream: buf, ErrStream: buf, Deterministic: true, ProgressInterval: time.Hour})
+		assert.NoError(t, err)
+		result := newTestResult(t, "db", "a.script")
+		result.StartTime, result.EndTime, result.Duration = start, start.Add(time.Minute), time.Minute
+		result.Process = &ProcessStats{NumGC: uint32(start.Second())}
+		assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost", "-c 1"))
+		out.ReportProgress(ProgressReport{Section: "benchmark", Step: "run", Completeness: 0.5})
+		out.ReportProgress(ProgressReport{Section: "benchmark", Step: "run", Completeness: 0.6})
+		assert.NoError(t, out.ReportInterval(IntervalResult{Result: result, Start: start, End: start.Add(time.Second)}))
+		assert.NoError(t, out.ReportLatency(result))
+		assert.NoError(t, out.Close())
+		// The caller's result keeps its data
+		assert.Equal(t, time.Minute, result.Duration)
+		return buf.String()
+	}
+	first, second := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC), time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, name := range []string{"interactive", "csv", "json", "heatmap", "influx"} {
+		output := run(name, first)
+		assert.Equal(t, output, run(name, second), name)
+		assert.NotContains(t, output, "2020", name)
+	}
+	// Progress isn't rate-limited, so both reports are written
+	assert.Contains(t, run("csv", first), "[benchmark][run] 50.00%\n[benchmark][run] 60.00%\n")
+}
+
+func TestThroughputShowsLowestAndHighestSampleRate(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	_, _, ok := result.SampleRateRange()
+	assert.False(t, ok)
+	buf := &bytes.Buffer{}
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportThroughput(result))
+	assert.Contains(t, buf.String(), "Successful Transactions: 10000 (100.000 per second)\n")
+
+	// A stall shows up as a sample without any transactions
+	result.SampleRates = []float64{120, 0, 180}
+	buf.Reset()
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportThroughput(result))
+	assert.Contains(t, buf.String(), "Successful Transactions: 10000 (100.000 per second, min 0.000 and max 180.000 in a sample)\n")
+
+	buf.Reset()
+	assert.NoError(t, (&JsonOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportThroughput(result))
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, 0.0, doc["min_rate"])
+	assert.Equal(t, 180.0, doc["max_rate"])
+}
+
+func TestWebhookGetsSummaryOnceResultsAreWritten(t *testing.T) {
+	posted := make(chan webhookSummary, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var summary webhookSummary
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&summary))
+		posted <- summary
+	}))
+	defer server.Close()
+	errStream := &bytes.Buffer{}
+	out := &WebhookOutput{Output: &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: errStream}, URL: server.URL,
+		Percentiles: []float64{50, 99.9}}
+
+	warmup := newTestResult(t, "db", "a.script")
+	warmup.Warmup = true
+	assert.NoError(t, out.ReportLatency(warmup))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.Len(t, posted, 0)
+	assert.NoError(t, out.Close())
+
+	summary := <-posted
+	assert.Equal(t, "neobench -c 1 on db: 100.000 tps, P50 5001.215ms, P99.9 9994.239ms, 0 failed", summary.Text)
+	assert.Equal(t, []webhookResult{{Database: "db", Scenario: "-c 1", Rate: 100,
+		Percentiles: map[string]float64{"p50": 5001.215, "p99900": 9994.239}}}, summary.Results)
+	assert.Empty(t, errStream.String())
+}
+
+func TestWebhookThatDoesntAnswerIsWarnedAbout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	errStream := &bytes.Buffer{}
+	out := &WebhookOutput{Output: &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: errStream}, URL: server.URL,
+		Timeout: 10 * time.Millisecond}
+
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.NoError(t, out.Close())
+	assert.Contains(t, errStream.String(), "failed to post results to webhook")
+	assert.False(t, out.ErrorsReported())
+
+	assert.NoError(t, ValidateWebhook("https://hooks.slack.com/services/x"))
+	assert.Error(t, ValidateWebhook("hooks.slack.com/services/x"))
+}
+
+func TestOtlpOutputPushesFinalResultsAsMetrics(t *testing.T) {
+	posted := make(chan otlpRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request otlpRequest
+		assert.Equal(t, "/v1/metrics", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		posted <- request
+	}))
+	defer server.Close()
+	errStream := &bytes.Buffer{}
+	out := &OtlpOutput{Output: &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: errStream}, Endpoint: server.URL,
+		Tags: map[string]string{"pool_size": "50"}, Boundaries: []float64{5000, 9000}}
+
+	warmup := newTestResult(t, "db", "a.script")
+	warmup.Warmup = true
+	assert.NoError(t, out.ReportLatency(warmup))
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.NoError(t, out.Close())
+
+	request := <-posted
+	assert.Empty(t, errStream.String())
+	assert.Len(t, request.ResourceMetrics, 1)
+	resource := request.ResourceMetrics[0]
+	assert.Equal(t, []otlpKeyValue{otlpString("service.name", "neobench"), otlpString("neobench.scenario", "-c 1"),
+		otlpString("pool_size", "50")}, resource.Resource.Attributes)
+	metrics := resource.ScopeMetrics[0].Metrics
+	assert.Equal(t, "neobench.throughput", metrics[0].Name)
+	assert.Equal(t, 100.0, *metrics[0].Gauge.DataPoints[0].AsDouble)
+	assert.Equal(t, []otlpKeyValue{otlpString("neobench.database", "db"), otlpString("neobench.script", "a.script")},
+		metrics[0].Gauge.DataPoints[0].Attributes)
+	assert.Equal(t, "neobench.errors", metrics[1].Name)
+	assert.Equal(t, "0", metrics[1].Sum.DataPoints[0].AsInt)
+	assert.Equal(t, "neobench.latency", metrics[2].Name)
+	latency := metrics[2].Histogram.DataPoints[0]
+	// Buckets go by the histogram's own, whose bounds are only accurate to three significant figures
+	assert.Equal(t, "10000", latency.Count)
+	assert.Equal(t, []string{"4997", "3997", "1006"}, latency.BucketCounts)
+	assert.Equal(t, []float64{5000, 9000}, latency.ExplicitBounds)
+}
+
+func TestOtlpEndpointThatCantBeReachedIsWarnedAbout(t *testing.T) {
+	errStream := &bytes.Buffer{}
+	out, err := NewOutput("csv", OutputOptions{OutStream: &bytes.Buffer{}, ErrStream: errStream, OtlpEndpoint: "localhost:4318"})
+	assert.NoError(t, err)
+	assert.Contains(t, errStream.String(), "not exporting metrics: invalid otlp endpoint: localhost:4318")
+	assert.NoError(t, out.Close())
+
+	var posts int
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	errStream.Reset()
+	otlp := &OtlpOutput{Output: &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: errStream}, Endpoint: server.URL + "/v1/metrics",
+		Retries: 2, backoff: time.Millisecond}
+	assert.NoError(t, otlp.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.NoError(t, otlp.Close())
+	assert.Contains(t, errStream.String(), "collector answered 503 Service Unavailable")
+	assert.False(t, otlp.ErrorsReported())
+	assert.Equal(t, 3, posts, "the first try and two retries")
+
+	// Retrying won't make a bad request good
+	posts, status = 0, http.StatusBadRequest
+	otlp = &OtlpOutput{Output: &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: errStream}, Endpoint: server.URL, backoff: time.Millisecond}
+	assert.NoError(t, otlp.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.NoError(t, otlp.Close())
+	assert.Equal(t, 1, posts)
+}
+
+func TestOtlpOutputRetriesAndBatchesResults(t *testing.T) {
+	var requests []otlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(requests) == 0 {
+			// Turned away once, so the first batch is posted twice
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			requests = append(requests, otlpRequest{})
+			return
+		}
+		var request otlpRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests = append(requests, request)
+	}))
+	defer server.Close()
+	errStream := &bytes.Buffer{}
+	out := &OtlpOutput{Output: &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: errStream}, Endpoint: server.URL,
+		BatchSize: 2, Temporality: "delta", backoff: time.Millisecond}
+	for _, db := range []string{"a", "b", "c"} {
+		assert.NoError(t, out.ReportLatency(newTestResult(t, db, "a.script")))
+	}
+	assert.NoError(t, out.Close())
+
+	assert.Empty(t, errStream.String())
+	assert.Len(t, requests, 3)
+	assert.Len(t, requests[1].ResourceMetrics, 2)
+	assert.Len(t, requests[2].ResourceMetrics, 1)
+	metrics := requests[1].ResourceMetrics[0].ScopeMetrics[0].Metrics
+	assert.Equal(t, otlpAggregationTemporalityDelta, metrics[1].Sum.AggregationTemporality)
+	assert.Equal(t, otlpAggregationTemporalityDelta, metrics[2].Histogram.AggregationTemporality)
+
+	_, err := NewOutput("csv", OutputOptions{OutStream: &bytes.Buffer{}, ErrStream: errStream, OtlpTemporality: "monthly"})
+	assert.EqualError(t, err, "unknown otlp temporality: monthly, supported temporalities are cumulative, delta")
+}
+
+func TestBaselineComparisonShowsChangeFromAnEarlierRun(t *testing.T) {
+	earlier := &bytes.Buffer{}
+	jsonOut := &JsonOutput{OutStream: earlier, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50, 99}}
+	assert.NoError(t, jsonOut.ReportLatency(newTestResult(t, "db", "a.script")))
+	baseline, err := ReadBaseline(earlier)
+	assert.NoError(t, err)
+
+	result := newTestResult(t, "db", "a.script")
+	result.Scripts["a.script"].Rate = 110
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50, 99.9}, Baseline: baseline}
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Contains(t, buf.String(), "Compared to baseline: tps +10.0% (+10.000), mean +0.0% (+0.000ms), P50 +0.0% (+0.000ms)\n")
+
+	buf.Reset()
+	csvOut := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, OmitHeader: true, Percentiles: []float64{50, 99.9}, Baseline: baseline}
+	assert.NoError(t, csvOut.ReportLatency(result))
+	assert.True(t, strings.HasSuffix(buf.String(), ",0,10.000,10.000,0.000,0.000,0.000,0.000,,,5003,14,0\n"), buf.String())
+
+	_, err = ReadBaseline(strings.NewReader(""))
+	assert.Error(t, err)
+}
+
+func TestInteractiveGroupsDigitsOfHighRates(t *testing.T) {
+	assert.Equal(t, "9999.500", formatRate(9999.5, 3))
+	assert.Equal(t, "10,000.000", formatRate(10000, 3))
+	assert.Equal(t, "523,481.000", formatRate(523481, 3))
+	assert.Equal(t, "1,234,567", formatRate(1234567, 0))
+	assert.Equal(t, "-12,345.6", formatRate(-12345.6, 1))
+
+	result := newTestResult(t, "db", "a.script")
+	result.Scripts["a.script"].Rate = 523481
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Contains(t, buf.String(), "Successful Transactions: 10000 (523,481.000 per second)\n")
+
+	buf.Reset()
+	csvOut := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, OmitHeader: true}
+	assert.NoError(t, csvOut.ReportLatency(result))
+	assert.Contains(t, buf.String(), ",523481.000,")
+}
+
+func TestInteractiveDistributionCoversTheFastEndInOrder(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, out.ReportLatency(result))
+	p0, p10, p25 := strings.Index(buf.String(), "P00.000:"), strings.Index(buf.String(), "P10.000:"), strings.Index(buf.String(), "P25.000:")
+	assert.True(t, p0 != -1 && p0 < p10 && p10 < p25, buf.String())
+
+	buf.Reset()
+	out = &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{99, 10, 50}}
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Contains(t, buf.String(), "    P10.000: 1000.447ms\n    P50.000: 5001.215ms\n    P99.000: 9904.127ms (n=105)\n")
+	assert.Equal(t, []float64{99, 10, 50}, out.Percentiles)
+}
+
+func TestTagsLabelEveryResultInEachFormat(t *testing.T) {
+	tags := map[string]string{"pool_size": "50", "driver": "go 4.4"}
+	report := func(format string) string {
+		buf := &bytes.Buffer{}
+		out, err := NewOutput(format, OutputOptions{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}, Tags: tags})
+		assert.NoError(t, err)
+		assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+		assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+		assert.NoError(t, out.Close())
+		return buf.String()
+	}
+
+	lines := strings.Split(report("csv"), "\n")
+	assert.True(t, strings.HasSuffix(lines[0], ",retries,p50_transactions,errors,tag_driver,tag_pool_size"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], `,"go 4.4","50"`), lines[1])
+	assert.Contains(t, report("json"), `"tags":{"driver":"go 4.4","pool_size":"50"}`)
+	assert.Contains(t, report("prometheus"), `script="a.script",driver="go 4.4",pool_size="50"}`)
+	assert.Contains(t, report("influx"), `,script=a.script,driver=go\ 4.4,pool_size=50 `)
+
+	_, err := NewOutput("csv", OutputOptions{Tags: map[string]string{"pool-size": "50"}})
+	assert.Error(t, err)
+	_, err = NewOutput("csv", OutputOptions{Tags: map[string]string{"database": "other"}})
+	assert.Error(t, err)
+}
+
+func TestRunIdIsOnEveryResultInEachFormat(t *testing.T) {
+	runId := "5f0c7a2e-1b3d-4c5e-8f9a-0b1c2d3e4f50"
+	report := func(format string) string {
+		buf := &bytes.Buffer{}
+		out, err := NewOutput(format, OutputOptions{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50},
+			Tags: map[string]string{"pool_size": "50"}, RunId: runId})
+		assert.NoError(t, err)
+		assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+		assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+		assert.NoError(t, out.Close())
+		return buf.String()
+	}
+
+	lines := strings.Split(report("csv"), "\n")
+	assert.True(t, strings.HasSuffix(lines[0], ",p50_transactions,errors,run_id,tag_pool_size"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], `,"`+runId+`","50"`), lines[1])
+	assert.Contains(t, report("json"), `"run_id":"`+runId+`","tags":{"pool_size":"50"}`)
+	assert.Contains(t, report("prometheus"), `script="a.script",run_id="`+runId+`",pool_size="50"}`)
+	assert.Contains(t, report("influx"), `,script=a.script,run_id=`+runId+`,pool_size=50 `)
+	assert.Contains(t, report("interactive"), "\nRun ID: "+runId+"\nTags: pool_size=50\n")
+	assert.Contains(t, report("protobuf"), runId)
+
+	// Webhook and otlp output send what they're given, after the format output has had its turn
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+	out, err := NewOutput("csv", OutputOptions{OutStream: &bytes.Buffer{}, ErrStream: &bytes.Buffer{}, RunId: runId,
+		Webhook: server.URL, OtlpEndpoint: server.URL + "/v1/metrics"})
+	assert.NoError(t, err)
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.NoError(t, out.Close())
+	assert.Len(t, bodies, 2)
+	for _, body := range bodies {
+		assert.Contains(t, body, runId)
+	}
+	assert.Contains(t, strings.Join(bodies, "\n"), `"run_id":"`+runId+`"`)
+	assert.Contains(t, strings.Join(bodies, "\n"), `"key":"neobench.run_id"`)
+
+	// Without one, results carry none
+	buf := &bytes.Buffer{}
+	out, err = NewOutput("json", OutputOptions{OutStream: buf, ErrStream: &bytes.Buffer{}})
+	assert.NoError(t, err)
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.NotContains(t, buf.String(), "run_id")
+
+	_, err = NewOutput("csv", OutputOptions{RunId: "run 1"})
+	assert.Error(t, err)
+	_, err = NewOutput("csv", OutputOptions{Tags: map[string]string{"run_id": "1"}})
+	assert.Error(t, err)
+
+	generated, err := NewRunId()
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, generated)
+	other, _ := NewRunId()
+	assert.NotEqual(t, generated, other)
+	assert.NoError(t, ValidateRunId(generated))
+}
+
+func TestRunIdIsSaidAtTheStartUnlessStderrIsNoPlaceForIt(t *testing.T) {
+	runId := "5f0c7a2e-1b3d-4c5e-8f9a-0b1c2d3e4f50"
+	start := func(format string, options OutputOptions) string {
+		errStream := &bytes.Buffer{}
+		options.OutStream, options.ErrStream, options.RunId = &bytes.Buffer{}, errStream, runId
+		out, err := NewOutput(format, options)
+		assert.NoError(t, err)
+		assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+		return errStream.String()
+	}
+
+	assert.Equal(t, "Starting workload on database db against neo4j://localhost:7687\nScenario: -c 1\nRun ID: "+runId+"\n",
+		start("csv", OutputOptions{}))
+	assert.Contains(t, start("json", OutputOptions{}), `{"event":"run","run_id":"`+runId+`"}`+"\n")
+	assert.Contains(t, start("csv", OutputOptions{ProgressFormat: "json"}), `{"event":"run","run_id":"`+runId+`"}`+"\n")
+	assert.Empty(t, start("quiet", OutputOptions{}))
+	assert.NotContains(t, start("interactive", OutputOptions{}), runId)
+	assert.NotContains(t, start("csv", OutputOptions{NoProgress: true}), runId)
+}
+
+func TestTailPercentilesSayHowManyTransactionsTheyAreBasedOn(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	histo := hdrhistogram.New(0, 60*60*1000000, 3)
+	for i := int64(1); i <= 300; i++ {
+		assert.NoError(t, histo.RecordValue(i*1000))
+	}
+	result.Scripts["a.script"].Latencies = histo
+
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50, 99, 99.9}}
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Contains(t, buf.String(), "    P50.000: 150.015ms\n    P99.000: 297.215ms (n=4)\n    P99.900: 300.031ms (n=1)\n")
+
+	buf.Reset()
+	csvOut := &CsvOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, OmitHeader: true, Percentiles: []float64{50, 99, 99.9}}
+	assert.NoError(t, csvOut.ReportLatency(result))
+	assert.True(t, strings.HasSuffix(buf.String(), ",0,151,4,1,0\n"), buf.String())
+}
+
+func TestWarmupResultsAreToldApartFromTheMeasuredRun(t *testing.T) {
+	warmup := newTestResult(t, "db", "a.script")
+	warmup.Warmup = true
+	report := func(format string) string {
+		buf := &bytes.Buffer{}
+		out, err := NewOutput(format, OutputOptions{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}, Warmup: true})
+		assert.NoError(t, err)
+		assert.NoError(t, out.BenchmarkStart("db", "neo4j://localhost:7687", "-c 1"))
+		assert.NoError(t, out.ReportLatency(warmup))
+		assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+		assert.NoError(t, out.Close())
+		return buf.String()
+	}
+
+	interactive := report("interactive")
+	assert.True(t, strings.Index(interactive, "== Warmup ==\n") < strings.Index(interactive, "== Results ==\n"), interactive)
+	lines := strings.Split(strings.TrimSpace(report("csv")), "\n")
+	assert.True(t, strings.HasSuffix(lines[0], ",retries,p50_transactions,errors,warmup"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], ",5003,0,true"), lines[1])
+	assert.True(t, strings.HasSuffix(lines[2], ",5003,0,false"), lines[2])
+	assert.Contains(t, report("json"), `"warmup":true`)
+	assert.Equal(t, 1, strings.Count(report("oneline"), "\n"))
+}
+
+func TestProtobufOutputWritesDelimitedResultMessages(t *testing.T) {
+	percentile := &protoBuffer{}
+	percentile.double(1, 99)
+	percentile.double(2, 0.5)
+	percentile.int64(3, 150)
+	// Fields 1 and 2 are fixed64 doubles, field 3 a varint; 150 takes two bytes
+	assert.Equal(t, []byte{0x09, 0, 0, 0, 0, 0, 0xc0, 0x58, 0x40, 0x11, 0, 0, 0, 0, 0, 0, 0xe0, 0x3f, 0x18, 0x96, 0x01}, percentile.b)
+
+	buf := &bytes.Buffer{}
+	out := &ProtobufOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, out.ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.NoError(t, out.ReportThroughput(newTestResult(t, "other", "a.script")))
+	assert.Equal(t, 0, buf.Len(), "results are written on Close")
+	assert.NoError(t, out.Close())
+
+	data := buf.Bytes()
+	var messages [][]byte
+	for len(data) > 0 {
+		size, n := binary.Uvarint(data)
+		assert.True(t, n > 0)
+		messages = append(messages, data[n:n+int(size)])
+		data = data[n+int(size):]
+	}
+	assert.Len(t, messages, 2)
+
+	// Decoded by the field numbers and types of neobench.proto
+	first := decodeProto(t, messages[0])
+	assert.Equal(t, "db", string(first[1][0].([]byte)))
+	assert.Equal(t, "-c 1", string(first[2][0].([]byte)))
+	assert.Equal(t, "other", string(decodeProto(t, messages[1])[1][0].([]byte)))
+	assert.Len(t, first[12], 1)
+	script := decodeProto(t, first[12][0].([]byte))
+	assert.Equal(t, "a.script", string(script[1][0].([]byte)))
+	assert.Equal(t, uint64(10000), script[2][0])
+	assert.Nil(t, script[3], "zero failures are left out")
+	assert.Equal(t, 100.0, script[4][0])
+	latency := decodeProto(t, script[6][0].([]byte))
+	assert.Equal(t, 1.0, latency[1][0])
+	assert.InDelta(t, 10000, latency[3][0], 10)
+	assert.Len(t, latency[5], len(DefaultJsonPercentiles))
+	// Latencies run 1 to 10000ms, so each percentile is around a hundred times it in milliseconds
+	lowest := decodeProto(t, latency[5][0].([]byte))
+	assert.Equal(t, DefaultJsonPercentiles[0], lowest[1][0])
+	assert.InDelta(t, DefaultJsonPercentiles[0]*100, lowest[2][0], 5)
+	assert.Equal(t, "<total>", string(decodeProto(t, first[13][0].([]byte))[1][0].([]byte)))
+}
+
+// Values of each field of a protobuf message, by field number: uint64 for varints, float64 for 64-bit fields,
+// which neobench.proto only uses for doubles, and []byte for strings and messages
+func decodeProto(t *testing.T, data []byte) map[int][]interface{} {
+	fields := make(map[int][]interface{})
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		assert.True(t, n > 0)
+		data = data[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case protoVarint:
+			v, n := binary.Uvarint(data)
+			assert.True(t, n > 0)
+			fields[field] = append(fields[field], v)
+			data = data[n:]
+		case protoFixed64:
+			fields[field] = append(fields[field], math.Float64frombits(binary.LittleEndian.Uint64(data)))
+			data = data[8:]
+		case protoLengthDelimited:
+			size, n := binary.Uvarint(data)
+			assert.True(t, n > 0)
+			fields[field] = append(fields[field], data[n:n+int(size)])
+			data = data[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d for field %d", key&7, field)
+		}
+	}
+	return fields
+}
+
+func TestThrottledProgressKeysOnWhatItIsToldTo(t *testing.T) {
+	steps := []ProgressReport{
+		{Section: "init", Step: "create schema", Completeness: 0},
+		{Section: "init", Step: "create accounts", Completeness: 0},
+		{Section: "init", Step: "create accounts", Completeness: 0.02},
+		{Section: "init", Step: "create accounts", Completeness: 0.1},
+		{Section: "run", Step: "create accounts", Completeness: 0.1},
+	}
+	written := func(key string, jump float64) []float64 {
+		recorded := &RecordingOutput{}
+		out := &ThrottledProgressOutput{Output: recorded, Interval: time.Hour, Key: key, Jump: jump}
+		for _, report := range steps {
+			out.ReportProgress(report)
+		}
+		var completeness []float64
+		for _, report := range recorded.Progress {
+			completeness = append(completeness, report.Completeness)
+		}
+		return completeness
+	}
+
+	assert.Equal(t, []float64{0, 0, 0.1}, written("both", 0))
+	assert.Equal(t, []float64{0, 0.1}, written("section", 0))
+	assert.Equal(t, []float64{0, 0}, written("step", 0))
+	assert.Equal(t, []float64{0, 0.1, 0.1}, written("section", 5))
+
+	assert.Error(t, ValidateProgressKey("worker"))
+	_, err := NewOutput("csv", OutputOptions{ProgressJump: 101})
+	assert.Error(t, err)
+}
+
+func TestInterruptedRunsSayTheyWereCutShort(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	result.Interrupted = true
+	result.Config.Duration = time.Minute
+	result.Duration = 12500 * time.Millisecond
+
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Contains(t, buf.String(), "Scenario: -c 1\nInterrupted after 12.5s of 1m0s: these are the results of the run so far\n")
+
+	buf.Reset()
+	jsonOut := &JsonOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, jsonOut.ReportLatency(result))
+	assert.Contains(t, buf.String(), `"interrupted":true`)
+	assert.True(t, strings.HasSuffix(buf.String(), "}\n"))
+}
+
+func TestResultsSayHowManyTransactionsRanAgainstWhatWasRequested(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	result.Config.Duration = time.Minute
+
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}
+	assert.NoError(t, out.ReportThroughput(result))
+	assert.Contains(t, buf.String(), "Scenario: -c 1\nTransactions: 10000 (requested 1m0s)\n")
+
+	// At 100 per second, 10000 transactions should take 100s
+	result.Config = RunConfig{Transactions: 10000, TargetRate: 100}
+	result.Duration = 120 * time.Second
+	buf.Reset()
+	assert.NoError(t, out.ReportLatency(result))
+	assert.Contains(t, buf.String(), "Transactions: 10000 (requested 10000)\n"+
+		"Took 2m0s, where 100.000 transactions per second allows for 1m40s: the rate wasn't kept up\n")
+
+	result.Duration = 101 * time.Second
+	buf.Reset()
+	assert.NoError(t, out.ReportLatency(result))
+	assert.NotContains(t, buf.String(), "Took")
+
+	buf.Reset()
+	assert.NoError(t, (&JsonOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.Contains(t, buf.String(), `"requested_transactions":10000,`)
+	assert.NotContains(t, buf.String(), "requested_duration_seconds")
+}
+
+func TestTrimmedRateLeavesOutTheHighestAndLowestSamples(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	result.SampleRates = []float64{10, 100, 100, 100, 100, 100, 100, 100, 100, 300}
+
+	trimmed, ok := result.TrimmedSampleRate(10)
+	assert.True(t, ok)
+	assert.Equal(t, 100.0, trimmed)
+	mean, _ := result.TrimmedSampleRate(0)
+	assert.Equal(t, 111.0, mean)
+	// However much is asked to be trimmed, the middle sample is kept
+	trimmed, _ = result.TrimmedSampleRate(49)
+	assert.Equal(t, 100.0, trimmed)
+	_, ok = (&Result{}).TrimmedSampleRate(10)
+	assert.False(t, ok)
+	assert.Error(t, ValidateTrimRates(50))
+
+	buf := &bytes.Buffer{}
+	out := &InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, TrimRates: 10}
+	assert.NoError(t, out.ReportThroughput(result))
+	assert.Contains(t, buf.String(), "Trimmed mean rate: 100.000 per second (-9.9%) without the top and bottom 10% of 10 samples, 111.000 per second with all of them\n")
+
+	buf.Reset()
+	assert.NoError(t, (&JsonOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, TrimRates: 10}).ReportThroughput(result))
+	assert.Contains(t, buf.String(), `"sample_mean_rate":111,"trimmed_rate":100,"trimmed_percent":10,`)
+}
+
+func TestClientCpuIsReportedAndWarnedAboutWhenSaturated(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	report := func() (string, string) {
+		buf, doc := &bytes.Buffer{}, &bytes.Buffer{}
+		assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportThroughput(result))
+		assert.NoError(t, (&JsonOutput{OutStream: doc, ErrStream: &bytes.Buffer{}}).ReportThroughput(result))
+		return buf.String(), doc.String()
+	}
+	errStream := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: errStream}
+	text, doc := report()
+	assert.NotContains(t, text, "Client CPU")
+	assert.NotContains(t, doc, "client_cpu")
+	CheckClientCpu(out, result)
+	assert.Equal(t, "", errStream.String())
+
+	result.ClientCpu = &ClientCpu{Cpus: 4, Time: 6 * time.Second, Mean: 0.15, Peak: 0.5}
+	text, doc = report()
+	assert.Contains(t, text, "Client CPU: 15.0% of 4 CPUs on average, 50.0% at most, 6s of CPU time\n")
+	assert.Contains(t, doc, `"client_cpu":{"cpus":4,"cpu_seconds":6,"mean_utilization":0.15,"peak_utilization":0.5}`)
+	CheckClientCpu(out, result)
+	assert.Equal(t, "", errStream.String())
+
+	result.ClientCpu = &ClientCpu{Cpus: 4, Time: 38 * time.Second, Mean: 0.95, Peak: 1}
+	CheckClientCpu(out, result)
+	assert.Equal(t, "WARN: neobench used 95.0% of its 4 CPUs on average; the client was saturated, so the rate may be "+
+		"its own limit rather than the server's, consider fewer clients per neobench process or a bigger client machine\n", errStream.String())
+}
+
+func TestReportsShowTheHistogramConfigLatenciesWereRecordedWith(t *testing.T) {
+	config := HistogramConfig{Min: 100 * time.Microsecond, Max: 10 * time.Second, SignificantFigures: 4}
+	result := NewResult("db", "")
+	script := &ScriptResult{ScriptName: "a.script", Succeeded: 1, Latencies: config.newHistogram()}
+	assert.NoError(t, script.Latencies.RecordValue(1500))
+	result.Scripts["a.script"] = script
+
+	buf, doc := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.NoError(t, (&JsonOutput{OutStream: doc, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.Contains(t, buf.String(), "Latencies are accurate to 4 significant figures, between 100µs and 10s\n")
+	assert.Contains(t, doc.String(), `"histogram":{"significant_figures":4,"min_trackable_ms":0.1,"max_trackable_ms":10000}`)
+
+	doc.Reset()
+	assert.NoError(t, (&JsonOutput{OutStream: doc, ErrStream: &bytes.Buffer{}}).ReportLatency(newTestResult(t, "db", "a.script")))
+	assert.Contains(t, doc.String(), `"histogram":{"significant_figures":3,"min_trackable_ms":0,"max_trackable_ms":3600000}`)
+}
+
+func TestValidateOutputOptionsRejectsWhatNewOutputWould(t *testing.T) {
+	assert.NoError(t, ValidateOutputOptions("csv", OutputOptions{}))
+	for name, options := range map[string]OutputOptions{
+		"cvs":         {},
+		"csv":         {Percentiles: []float64{101}},
+		"interactive": {ProgressFormat: "xml"},
+	} {
+		validateErr := ValidateOutputOptions(name, options)
+		assert.Error(t, validateErr)
+		_, err := NewOutput(name, options)
+		assert.Equal(t, validateErr, err)
+	}
+}
@@ -0,0 +1,73 @@
+package neobench
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// What a progress report has to change to be written right away, see ThrottledProgressOutput.Key
+var ProgressKeys = []string{"both", "section", "step"}
+
+// Fails unless ThrottledProgressOutput can key on key; empty means the default, both
+func ValidateProgressKey(key string) error {
+	if key == "" || containsString(ProgressKeys, key) {
+		return nil
+	}
+	return fmt.Errorf("unknown progress key: %s, supported keys are %s", key, strings.Join(ProgressKeys, ", "))
+}
+
+// Rate-limits progress for the wrapped Output, which is then given every report that gets through, for control
+// over how chatty progress is: runs with many short steps can key on the section alone, so each step doesn't
+// get written, and runs stuck on one long step can have big jumps in completeness written without waiting for
+// the interval. Progress bars are redrawn in place rather than written line by line, so NewOutput leaves them be.
+type ThrottledProgressOutput struct {
+	Output
+	// Minimum time between reports with the same key
+	Interval time.Duration
+	// Written right away if this changed since the last report written: "section", "step" or, by default, "both"
+	Key string
+	// Written right away if completeness moved by at least this many percentage points since the last report
+	// written; zero waits for the interval
+	Jump float64
+	// The last report written, and when
+	last     ProgressReport
+	lastTime time.Time
+	written  bool
+}
+
+func (o *ThrottledProgressOutput) ReportProgress(report ProgressReport) {
+	now := time.Now()
+	if o.written && !o.isDue(report, now) {
+		return
+	}
+	o.last, o.lastTime, o.written = report, now, true
+	o.Output.ReportProgress(report)
+}
+
+func (o *ThrottledProgressOutput) Reset() {
+	o.last, o.lastTime, o.written = ProgressReport{}, time.Time{}, false
+	ResetOutput(o.Output)
+}
+
+func (o *ThrottledProgressOutput) isDue(report ProgressReport, now time.Time) bool {
+	switch o.Key {
+	case "section":
+		if report.Section != o.last.Section {
+			return true
+		}
+	case "step":
+		if report.Step != o.last.Step {
+			return true
+		}
+	default:
+		if report.Section != o.last.Section || report.Step != o.last.Step {
+			return true
+		}
+	}
+	if o.Jump > 0 && math.Abs(report.Completeness-o.last.Completeness)*100 >= o.Jump {
+		return true
+	}
+	return now.Sub(o.lastTime) >= o.Interval
+}
@@ -0,0 +1,156 @@
+package neobench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long WebhookOutput waits for the webhook to answer before giving up on it
+const DefaultWebhookTimeout = 10 * time.Second
+
+var DefaultWebhookPercentiles = []float64{50, 95, 99, 99.9}
+
+// Posts a JSON summary of the results to a webhook once the wrapped Output is closed, eg. a Slack incoming
+// webhook, to hear when an unattended run is done. The summary has a text field Slack shows as the message, and
+// the same figures as fields for other receivers. The run is over by then, so a webhook that's down or slow to
+// answer is warned about rather than failing it. Warmup results aren't posted.
+type WebhookOutput struct {
+	Output
+	URL string
+	// Latency percentiles in the summary, defaults to DefaultWebhookPercentiles
+	Percentiles []float64
+	// How long to wait for the webhook, defaults to DefaultWebhookTimeout
+	Timeout time.Duration
+	// Used to post the summary, defaults to http.DefaultClient
+	Client *http.Client
+	// Final results, posted on Close
+	results []Result
+}
+
+// Fails unless webhook is an http or https url
+func ValidateWebhook(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook: %s, webhooks are http:// or https:// urls", webhook)
+	}
+	return nil
+}
+
+type webhookSummary struct {
+	Text    string          `json:"text"`
+	Results []webhookResult `json:"results"`
+}
+
+type webhookResult struct {
+	Database string `json:"database"`
+	Scenario string `json:"scenario"`
+	// Left out if the run has no id
+	RunId  string  `json:"run_id,omitempty"`
+	Rate   float64 `json:"rate"`
+	Failed int64   `json:"failed"`
+	// By percentileColumnName, left out if there are no latencies
+	Percentiles map[string]float64 `json:"percentiles_ms,omitempty"`
+}
+
+func (o *WebhookOutput) ReportThroughput(result Result) error {
+	o.record(result)
+	return o.Output.ReportThroughput(result)
+}
+
+func (o *WebhookOutput) ReportLatency(result Result) error {
+	o.record(result)
+	return o.Output.ReportLatency(result)
+}
+
+// The summary is of the measured run, warmup results would read as runs of their own
+func (o *WebhookOutput) record(result Result) {
+	if !result.Warmup {
+		o.results = append(o.results, result)
+	}
+}
+
+func (o *WebhookOutput) Close() error {
+	err := o.Output.Close()
+	if len(o.results) > 0 {
+		if postErr := o.post(); postErr != nil {
+			o.Output.Warnf("failed to post results to webhook: %s", postErr)
+		}
+	}
+	return err
+}
+
+func (o *WebhookOutput) Reset() {
+	ResetOutput(o.Output)
+}
+
+func (o *WebhookOutput) post() error {
+	body, err := json.Marshal(o.summary())
+	if err != nil {
+		return err
+	}
+	timeout := o.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", res.Status)
+	}
+	return nil
+}
+
+// One line of text per result, like "neobench -c 4 on neo4j: 1234.000 tps, P50 1.200ms, P99.9 9.800ms, 0 failed"
+func (o *WebhookOutput) summary() webhookSummary {
+	summary := webhookSummary{Results: make([]webhookResult, 0, len(o.results))}
+	lines := make([]string, 0, len(o.results))
+	for _, result := range o.results {
+		total := result.Total()
+		databaseName := result.DatabaseName
+		if databaseName == "" {
+			databaseName = "<default>"
+		}
+		doc := webhookResult{Database: databaseName, Scenario: result.Scenario, RunId: result.RunId, Rate: result.TotalRate(), Failed: total.Failed}
+		line := fmt.Sprintf("neobench %s on %s: %.3f tps", result.Scenario, databaseName, doc.Rate)
+		if total.Latencies.TotalCount() > 0 {
+			doc.Percentiles = make(map[string]float64)
+			for _, q := range o.percentiles() {
+				latency := valueAtPercentile(total.Latencies, q)
+				doc.Percentiles[percentileColumnName(q)] = float64(latency) / 1000.0
+				line += fmt.Sprintf(", P%s %s", strconv.FormatFloat(q, 'f', -1, 64), LatencyMilliseconds.format(latency, 3))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s, %d failed", line, total.Failed))
+		summary.Results = append(summary.Results, doc)
+	}
+	summary.Text = strings.Join(lines, "\n")
+	return summary
+}
+
+func (o *WebhookOutput) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultWebhookPercentiles
+	}
+	return o.Percentiles
+}
@@ -0,0 +1,86 @@
+package neobench
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Connections the driver keeps per server by default, the same as the driver's own default
+const DefaultPoolSize = 100
+
+// Times how long successful transactions took to get a connection from the driver's pool, as measured by the
+// workers: from asking the driver for a transaction until it runs the transaction function, see
+// PhaseConnectionAcquire. The driver keeps a pool per server and doesn't say when one is exhausted, so this
+// can't tell waiting for a free connection apart from opening one and beginning the transaction; it only
+// ever measures, it doesn't hold transactions back. Shared by the workers' ResultRecorders, like a
+// ConcurrencyTracker.
+type ConnectionPool struct {
+	// Connections the driver keeps per server, for reference in the summary
+	Size int
+
+	mut          sync.Mutex
+	transactions int64
+	acquiring    time.Duration
+	max          time.Duration
+}
+
+func NewConnectionPool(size int) *ConnectionPool {
+	return &ConnectionPool{Size: size}
+}
+
+func (p *ConnectionPool) track(acquisition time.Duration) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.transactions++
+	p.acquiring += acquisition
+	if acquisition > p.max {
+		p.max = acquisition
+	}
+}
+
+// Acquisitions so far
+func (p *ConnectionPool) Summary() *PoolWait {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	summary := &PoolWait{Size: p.Size, Transactions: p.transactions, Max: p.max}
+	if p.transactions > 0 {
+		summary.Mean = p.acquiring / time.Duration(p.transactions)
+	}
+	return summary
+}
+
+// Acquisitions so far, like Summary, and starts counting over, eg. once the warmup of a run is over
+func (p *ConnectionPool) Restart() *PoolWait {
+	summary := p.Summary()
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.transactions, p.acquiring, p.max = 0, 0, 0
+	return summary
+}
+
+// Time transactions took to get a connection from the driver's pool, see ConnectionPool; it counts towards
+// latency, and grows with waits for a free connection once clients outnumber the connections to a server
+type PoolWait struct {
+	// Connections the driver keeps per server
+	Size int
+	// Successful transactions the acquisition was measured for
+	Transactions int64
+	Mean         time.Duration
+	Max          time.Duration
+}
+
+// Left out if the run didn't track the pool
+func writePoolWait(result Result, s *strings.Builder) {
+	p := result.PoolWait
+	if p == nil {
+		return
+	}
+	if p.Transactions == 0 {
+		return
+	}
+	s.WriteString(fmt.Sprintf("Connection acquisition: mean %s, max %s over %d transactions, with %d connections per server; "+
+		"this includes waiting for a free connection, if it's a large part of latency consider a larger --pool-size\n",
+		p.Mean.Round(time.Microsecond), p.Max.Round(time.Microsecond), p.Transactions, p.Size))
+}
@@ -0,0 +1,77 @@
+package neobench
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// How the neobench process itself fared over a run, to tell client-side GC pauses and allocation pressure
+// apart from server latency; only gathered with --diagnostics, see ProcessStatsSince
+type ProcessStats struct {
+	// Heap in use at the end of the run
+	HeapAlloc uint64
+	// Bytes allocated over the run, including what has since been collected
+	TotalAlloc uint64
+	// Garbage collections over the run, and the total and longest stop-the-world pause among them; the Go
+	// runtime only keeps the last 256 pauses, so MaxPause is of those on runs with more collections
+	NumGC      uint32
+	PauseTotal time.Duration
+	MaxPause   time.Duration
+}
+
+// Memory statistics of the process right now, to measure a run from with ProcessStatsSince. This stops the
+// world briefly, so it's meant for the start and end of a run rather than while transactions are timed
+func ReadMemStats() runtime.MemStats {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats
+}
+
+func ProcessStatsSince(start runtime.MemStats) *ProcessStats {
+	end := ReadMemStats()
+	stats := &ProcessStats{
+		HeapAlloc:  end.HeapAlloc,
+		TotalAlloc: end.TotalAlloc - start.TotalAlloc,
+		NumGC:      end.NumGC - start.NumGC,
+		PauseTotal: time.Duration(end.PauseTotalNs - start.PauseTotalNs),
+	}
+	// PauseNs is a circular buffer, the pause of the nth collection is at (n-1) % 256
+	first := start.NumGC
+	if end.NumGC-first > uint32(len(end.PauseNs)) {
+		first = end.NumGC - uint32(len(end.PauseNs))
+	}
+	for n := first; n < end.NumGC; n++ {
+		if pause := time.Duration(end.PauseNs[n%uint32(len(end.PauseNs))]); pause > stats.MaxPause {
+			stats.MaxPause = pause
+		}
+	}
+	return stats
+}
+
+func writeProcessStats(result Result, s *strings.Builder) {
+	stats := result.Process
+	if stats == nil {
+		return
+	}
+	s.WriteString("Client diagnostics:\n")
+	s.WriteString(fmt.Sprintf("  Heap: %s in use, %s allocated during the run\n", formatBytes(stats.HeapAlloc), formatBytes(stats.TotalAlloc)))
+	s.WriteString(fmt.Sprintf("  GC: %d collections, %s paused in total, longest pause %s\n",
+		stats.NumGC, stats.PauseTotal.Round(time.Microsecond), stats.MaxPause.Round(time.Microsecond)))
+	s.WriteString("\n")
+}
+
+// In binary units, eg. 1.5MiB
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, prefix := float64(n)/unit, 0
+	for value >= unit && prefix < len("KMGTPE")-1 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f%ciB", value, "KMGTPE"[prefix])
+}
@@ -0,0 +1,70 @@
+package neobench
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Protocol buffer wire format, just enough of it to encode the messages in neobench.proto. Fields with zero
+// values are left out, as proto3 does; messages are always written, so an empty one still says it's there.
+type protoBuffer struct {
+	b []byte
+}
+
+const (
+	protoVarint          = 0
+	protoFixed64         = 1
+	protoLengthDelimited = 2
+)
+
+func (p *protoBuffer) varint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	p.b = append(p.b, buf[:n]...)
+}
+
+func (p *protoBuffer) tag(field int, wireType int) {
+	p.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (p *protoBuffer) int64(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	p.tag(field, protoVarint)
+	p.varint(uint64(v))
+}
+
+func (p *protoBuffer) bool(field int, v bool) {
+	if v {
+		p.tag(field, protoVarint)
+		p.varint(1)
+	}
+}
+
+func (p *protoBuffer) double(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	p.tag(field, protoFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+	p.b = append(p.b, buf[:]...)
+}
+
+func (p *protoBuffer) string(field int, v string) {
+	if v == "" {
+		return
+	}
+	p.bytes(field, []byte(v))
+}
+
+func (p *protoBuffer) message(field int, m *protoBuffer) {
+	p.bytes(field, m.b)
+}
+
+func (p *protoBuffer) bytes(field int, v []byte) {
+	p.tag(field, protoLengthDelimited)
+	p.varint(uint64(len(v)))
+	p.b = append(p.b, v...)
+}
@@ -0,0 +1,40 @@
+package neobench
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Run ids go in csv columns, label values and tags, so they're kept to characters none of those need escaped
+var runIdPattern = regexp.MustCompile(`^[a-zA-Z0-9._:-]+$`)
+
+// Fails unless id can be used as a run id, see runIdPattern; an empty id means the run has none
+func ValidateRunId(id string) error {
+	if id != "" && !runIdPattern.MatchString(id) {
+		return fmt.Errorf("invalid run id: %s, run ids contain only letters, digits, '.', '_', ':' and '-'", id)
+	}
+	return nil
+}
+
+// A random version 4 UUID, to tell the results of one run apart from every other's when they're stored
+// together, eg. so uploading the same results twice doesn't count them twice
+func NewRunId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return strings.Join([]string{
+		fmt.Sprintf("%x", b[0:4]), fmt.Sprintf("%x", b[4:6]), fmt.Sprintf("%x", b[6:8]), fmt.Sprintf("%x", b[8:10]), fmt.Sprintf("%x", b[10:16]),
+	}, "-"), nil
+}
+
+// Left out if the run has no id
+func writeRunId(result Result, s *strings.Builder) {
+	if result.RunId != "" {
+		s.WriteString(fmt.Sprintf("Run ID: %s\n", result.RunId))
+	}
+}
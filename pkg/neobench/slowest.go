@@ -0,0 +1,83 @@
+package neobench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Most transactions --top-slow keeps; each worker keeps its own, and sorts them on every one it adds
+const MaxTopSlow = 100
+
+// A transaction kept for being among the slowest of a run, to tell one pathological query apart from a tail
+// that's spread over all of them
+type SlowTransaction struct {
+	ScriptName string
+	// When the transaction was meant to start; like latencies, it's corrected for coordinated omission
+	Start     time.Time
+	Latency   time.Duration
+	Succeeded bool
+	// Parameters the script's statements ran with, as name=value in order of name, see formatParameters
+	Parameters string
+}
+
+// Sorts slowest first and drops all but the n slowest
+func keepSlowest(slowest []SlowTransaction, n int) []SlowTransaction {
+	sort.SliceStable(slowest, func(i, j int) bool { return slowest[i].Latency > slowest[j].Latency })
+	if len(slowest) > n {
+		slowest = slowest[:n]
+	}
+	return slowest
+}
+
+// Longest a parameter value is written, longer ones are cut short, so a list parameter doesn't swamp the rest
+const maxParameterLength = 40
+
+// Statements of a script share its variables, so each parameter is written once
+func formatParameters(statements []Statement) string {
+	values := make(map[string]interface{})
+	for _, statement := range statements {
+		for name, value := range statement.Params {
+			values[name] = value
+		}
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parameters := make([]string, 0, len(names))
+	for _, name := range names {
+		value := fmt.Sprintf("%v", values[name])
+		if len(value) > maxParameterLength {
+			value = value[:maxParameterLength-3] + "..."
+		}
+		parameters = append(parameters, name+"="+value)
+	}
+	return strings.Join(parameters, ", ")
+}
+
+// Left out unless the run kept its slowest transactions; start times are relative to the start of the run where
+// it's known
+func writeSlowest(result Result, unit LatencyUnit, places int, s *strings.Builder) {
+	if len(result.Slowest) == 0 {
+		return
+	}
+	s.WriteString("Slowest transactions:\n")
+	for i, slow := range result.Slowest {
+		outcome := "succeeded"
+		if !slow.Succeeded {
+			outcome = "failed"
+		}
+		line := fmt.Sprintf("  %d. %s %s, %s", i+1, unit.format(slow.Latency.Microseconds(), places), slow.ScriptName, outcome)
+		if !result.StartTime.IsZero() && !slow.Start.IsZero() {
+			line += fmt.Sprintf(", started at +%s", slow.Start.Sub(result.StartTime).Round(time.Millisecond))
+		}
+		if slow.Parameters != "" {
+			line += ", " + slow.Parameters
+		}
+		s.WriteString(line + "\n")
+	}
+	s.WriteString("\n")
+}
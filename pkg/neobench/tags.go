@@ -0,0 +1,92 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+)
+
+// Labels every result passing through with Tags, eg. pool_size=50 for each run of a parameter sweep, so the
+// runs can be told apart when their results are put side by side later, and with RunId, so results stored
+// twice can be told to be the same. Results are copied before they're changed; the map is shared between them
+// and must not be changed once the run has started.
+type TaggingOutput struct {
+	Output
+	Tags  map[string]string
+	RunId string
+	// Where BenchmarkStart says what RunId is; nil leaves it unsaid, see NewOutput for the outputs that do
+	ErrStream io.Writer
+	// Say it as a json event rather than a line of text, for when the rest of ErrStream is json too
+	JsonEvents bool
+}
+
+// Says what RunId is as the benchmark starts, so a generated one is known before there are results to read it
+// from
+func (o *TaggingOutput) BenchmarkStart(databaseName, url, scenario string) error {
+	if err := o.Output.BenchmarkStart(databaseName, url, scenario); err != nil {
+		return err
+	}
+	if o.RunId == "" || o.ErrStream == nil {
+		return nil
+	}
+	if o.JsonEvents {
+		return newJsonEncoder(o.ErrStream).Encode(jsonEvent{Event: "run", RunId: o.RunId})
+	}
+	_, err := fmt.Fprintf(o.ErrStream, "Run ID: %s\n", o.RunId)
+	return err
+}
+
+func (o *TaggingOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) error {
+	checkpoint.Tags, checkpoint.RunId = o.Tags, o.RunId
+	return o.Output.ReportWorkloadProgress(completeness, checkpoint)
+}
+
+func (o *TaggingOutput) ReportInterval(sample IntervalResult) error {
+	sample.Tags, sample.RunId = o.Tags, o.RunId
+	return o.Output.ReportInterval(sample)
+}
+
+func (o *TaggingOutput) ReportThroughput(result Result) error {
+	result.Tags, result.RunId = o.Tags, o.RunId
+	return o.Output.ReportThroughput(result)
+}
+
+func (o *TaggingOutput) ReportLatency(result Result) error {
+	result.Tags, result.RunId = o.Tags, o.RunId
+	return o.Output.ReportLatency(result)
+}
+
+func (o *TaggingOutput) Reset() {
+	ResetOutput(o.Output)
+}
+
+// Tag keys become Prometheus label names and CSV column names as they are, so they're held to the stricter
+// rules of the two
+var tagKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Labels outputs already give results, which tags can't take the place of
+var reservedTagKeys = []string{"scenario", "database", "script", "clients", "quantile", "run_id"}
+
+// Fails unless every key can be used as a tag, see tagKeyPattern
+func ValidateTags(tags map[string]string) error {
+	for _, key := range sortedTagKeys(tags) {
+		if !tagKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid tag: %s, tags must start with a letter or underscore and contain only letters, digits and underscores", key)
+		}
+		if containsString(reservedTagKeys, key) {
+			return fmt.Errorf("invalid tag: %s, the name is already used for a label of its own", key)
+		}
+	}
+	return nil
+}
+
+// Tags are written in order of their keys, so the same tags always come out the same way
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
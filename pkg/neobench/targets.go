@@ -0,0 +1,44 @@
+package neobench
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Latency each percentile should stay at or below, eg. {99: 20ms} for "P99 under 20ms". Unlike Thresholds
+// these only annotate the results, missing a target doesn't fail the run
+type PercentileTargets map[float64]time.Duration
+
+// Parses targets given as percentile → duration, eg. {"99": "20ms", "99.9": "50ms"}
+func ParsePercentileTargets(specs map[string]string) (PercentileTargets, error) {
+	targets := make(PercentileTargets, len(specs))
+	for q, target := range specs {
+		percentile, err := strconv.ParseFloat(q, 64)
+		if err != nil || percentile < 0 || percentile > 100 {
+			return nil, fmt.Errorf("invalid percentile target: %s=%s, percentile must be a number between 0 and 100", q, target)
+		}
+		d, err := time.ParseDuration(target)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid percentile target: %s=%s, target must be a positive duration, ex: 20ms", q, target)
+		}
+		targets[percentile] = d
+	}
+	return targets, nil
+}
+
+// Percentiles with targets, in ascending order, so columns are stable between runs
+func (t PercentileTargets) percentiles() []float64 {
+	percentiles := make([]float64, 0, len(t))
+	for q := range t {
+		percentiles = append(percentiles, q)
+	}
+	sort.Float64s(percentiles)
+	return percentiles
+}
+
+// Whether the latency at the given percentile, in microseconds, is within its target; being exactly at it counts
+func (t PercentileTargets) met(q float64, latencyMicros int64) bool {
+	return time.Duration(latencyMicros)*time.Microsecond <= t[q]
+}
@@ -0,0 +1,42 @@
+package neobench
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits a run has to stay within to pass, eg. for failing CI jobs on performance regressions. Zero values
+// mean no limit. Being exactly at a limit passes.
+type Thresholds struct {
+	// Highest P99 latency allowed, across all scripts combined
+	MaxP99 time.Duration
+	// Lowest total transactions per second allowed
+	MinRate float64
+}
+
+// Describes each threshold result breaches, empty if it passes
+func (t Thresholds) Breaches(result Result) []string {
+	breaches := make([]string, 0)
+	if t.MaxP99 > 0 {
+		p99 := time.Duration(valueAtPercentile(result.Total().Latencies, 99)) * time.Microsecond
+		if p99 > t.MaxP99 {
+			breaches = append(breaches, fmt.Sprintf("P99 latency was %s, above the limit of %s", p99, t.MaxP99))
+		}
+	}
+	if t.MinRate > 0 {
+		if rate := result.TotalRate(); rate < t.MinRate {
+			breaches = append(breaches, fmt.Sprintf("throughput was %.3f transactions per second, below the limit of %.3f", rate, t.MinRate))
+		}
+	}
+	return breaches
+}
+
+// Reports each breached threshold with Errorf, which fails the run whatever the output format is;
+// returns true if all thresholds passed
+func CheckThresholds(out Output, result Result, t Thresholds) bool {
+	breaches := t.Breaches(result)
+	for _, breach := range breaches {
+		out.Errorf("threshold breached: %s", breach)
+	}
+	return len(breaches) == 0
+}
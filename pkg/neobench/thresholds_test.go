@@ -0,0 +1,33 @@
+package neobench
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestThresholdsPassExactlyAtTheLimit(t *testing.T) {
+	result := newTestResult(t, "db", "a.script")
+	p99 := time.Duration(valueAtPercentile(result.Scripts["a.script"].Latencies, 99)) * time.Microsecond
+
+	assert.Empty(t, Thresholds{}.Breaches(result))
+	assert.Empty(t, Thresholds{MaxP99: p99, MinRate: 100}.Breaches(result))
+	assert.Equal(t, []string{
+		"P99 latency was 9.904127s, above the limit of 9.904126s",
+		"throughput was 100.000 transactions per second, below the limit of 100.001",
+	}, Thresholds{MaxP99: p99 - time.Microsecond, MinRate: 100.001}.Breaches(result))
+}
+
+func TestCheckThresholdsFailsTheRun(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &CsvOutput{OutStream: &bytes.Buffer{}, ErrStream: buf}
+	result := newTestResult(t, "db", "a.script")
+
+	assert.True(t, CheckThresholds(out, result, Thresholds{MinRate: 50}))
+	assert.False(t, out.ErrorsReported())
+
+	assert.False(t, CheckThresholds(out, result, Thresholds{MinRate: 150}))
+	assert.True(t, out.ErrorsReported())
+	assert.Equal(t, "ERROR: threshold breached: throughput was 100.000 transactions per second, below the limit of 150.000\n", buf.String())
+}
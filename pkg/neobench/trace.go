@@ -0,0 +1,59 @@
+package neobench
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rough size of a trace row, for warning about how large traces get
+const TraceBytesPerTransaction = 40
+
+// Writes every transaction as a CSV row of when it started, in nanoseconds since the Unix epoch, its latency
+// in microseconds, its script and whether it succeeded; the raw material for lining up tail latencies with
+// GC logs, checkpoints and the like, which aggregated results can't be. This is a row per transaction, so
+// it's only written when asked for. In latency mode transactions start when they were scheduled to, like
+// their latencies are measured from. Safe to record to from several workers at once.
+type TraceWriter struct {
+	mut sync.Mutex
+	w   *bufio.Writer
+	// First error writing, after which rows are dropped; returned by Flush
+	err error
+}
+
+func NewTraceWriter(w io.Writer) *TraceWriter {
+	t := &TraceWriter{w: bufio.NewWriter(w)}
+	_, t.err = t.w.WriteString("timestamp_ns,latency_us,script,succeeded\n")
+	return t
+}
+
+func (t *TraceWriter) Record(start time.Time, latency time.Duration, scriptName string, succeeded bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.err != nil {
+		return
+	}
+	row := make([]byte, 0, TraceBytesPerTransaction+len(scriptName))
+	row = strconv.AppendInt(row, start.UnixNano(), 10)
+	row = append(row, ',')
+	row = strconv.AppendInt(row, latency.Microseconds(), 10)
+	row = append(row, ',')
+	row = append(row, csvCommaFormat.text(scriptName)...)
+	row = append(row, ',')
+	row = strconv.AppendBool(row, succeeded)
+	row = append(row, '\n')
+	_, t.err = t.w.Write(row)
+}
+
+// Writes out buffered rows, returning the first error writing any row if there was one
+func (t *TraceWriter) Flush() error {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	t.err = t.w.Flush()
+	return t.err
+}
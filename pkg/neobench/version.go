@@ -0,0 +1,4 @@
+package neobench
+
+// Version of this neobench build, set at build time with -ldflags "-X neobench/pkg/neobench.Version=..."
+var Version = "dev"
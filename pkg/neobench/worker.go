@@ -1,9 +1,11 @@
 package neobench
 
 import (
+	"fmt"
 	"github.com/codahale/hdrhistogram"
 	"github.com/neo4j/neo4j-go-driver/neo4j"
 	"github.com/pkg/errors"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -37,6 +39,7 @@ func (w *Worker) RunBenchmark(wrk ClientWorkload, databaseName string, transacti
 	workStartTime := w.now()
 	recorder.totalStart = workStartTime
 	recorder.currentStart = workStartTime
+	recorder.sampleStart = workStartTime
 
 	nextStart := workStartTime
 
@@ -54,13 +57,29 @@ func (w *Worker) RunBenchmark(wrk ClientWorkload, databaseName string, transacti
 			return WorkerResult{WorkerId: w.workerId, Error: err}
 		}
 
-		outcome := w.runUnit(session, uow)
+		if recorder.Concurrency != nil {
+			recorder.Concurrency.begin()
+		}
+		unitStart := w.now()
+		outcome := w.runUnit(session, uow, recorder.CountRecords)
+		if recorder.Concurrency != nil {
+			recorder.Concurrency.end(w.now().Sub(unitStart))
+		}
+		if recorder.Pool != nil && outcome.acquireLatency > 0 {
+			recorder.Pool.track(outcome.acquireLatency)
+		}
 
 		uowLatency := w.now().Sub(nextStart)
 
 		if err = recorder.record(uow.ScriptName, uowLatency, outcome); err != nil {
 			return WorkerResult{WorkerId: w.workerId, Error: err}
 		}
+		if recorder.Trace != nil {
+			recorder.Trace.Record(nextStart, uowLatency, uow.ScriptName, outcome.succeeded)
+		}
+		if recorder.TopSlow > 0 {
+			recorder.recordSlow(uow, nextStart, uowLatency, outcome.succeeded)
+		}
 
 		transactionCounter++
 		if numTransactions != 0 && transactionCounter >= numTransactions {
@@ -96,23 +115,56 @@ func (w *Worker) gatherResults(workloadStats map[string]*ScriptResult, workStart
 	workloadResults := make([]ScriptResult, 0, len(workloadStats))
 	for _, result := range workloadStats {
 		workloadResults = append(workloadResults, ScriptResult{
-			ScriptName: result.ScriptName,
-			Rate:       float64(result.Succeeded+result.Failed) / w.now().Sub(workStartTime).Seconds(),
-			Failed:     result.Failed,
-			Succeeded:  result.Succeeded,
-			Latencies:  result.Latencies,
+			ScriptName:     result.ScriptName,
+			Rate:           float64(result.Succeeded+result.Failed) / w.now().Sub(workStartTime).Seconds(),
+			Failed:         result.Failed,
+			Succeeded:      result.Succeeded,
+			Latencies:      result.Latencies,
+			Phases:         result.Phases,
+			WithoutRetries: result.WithoutRetries,
+			AccessModes:    result.AccessModes,
+			OutOfRange:     result.OutOfRange,
+			Queries:        result.Queries,
+			QueryRate:      float64(result.Queries) / w.now().Sub(workStartTime).Seconds(),
+			Records:        result.Records,
+			RecordRate:     float64(result.Records) / w.now().Sub(workStartTime).Seconds(),
+			Bytes:          result.Bytes,
+			ByteRate:       float64(result.Bytes) / w.now().Sub(workStartTime).Seconds(),
+			Retries:        result.Retries,
 		})
 	}
 	return workloadResults
 }
 
-func (w *Worker) runUnit(session neo4j.Session, uow UnitOfWork) uowOutcome {
+// Records are only read, and their size estimated, if countRecords is set; that happens while the transaction
+// is timed, so it adds to the latency measured
+func (w *Worker) runUnit(session neo4j.Session, uow UnitOfWork, countRecords bool) uowOutcome {
+	// The driver calls the transaction function once it has a connection with a transaction open on it, and
+	// again for each retry; so the time until the first call is connection acquisition, and the time from
+	// the last call is the queries themselves
+	start := w.now()
+	var firstAttempt, lastAttempt time.Time
+	var attempts, records, bytes int64
 	transaction := func(tx neo4j.Transaction) (interface{}, error) {
+		attempts++
+		lastAttempt = w.now()
+		if firstAttempt.IsZero() {
+			firstAttempt = lastAttempt
+		}
+		// Only the last attempt's records count, earlier ones were retried
+		records, bytes = 0, 0
 		for _, s := range uow.Statements {
 			res, err := tx.Run(s.Query, s.Params)
 			if err != nil {
 				return nil, err
 			}
+			for countRecords && res.Next() {
+				records++
+				bytes += approxValueSize(res.Record().Values())
+			}
+			if err = res.Err(); err != nil {
+				return nil, err
+			}
 			_, err = res.Consume()
 			if err != nil {
 				return nil, err
@@ -128,15 +180,35 @@ func (w *Worker) runUnit(session neo4j.Session, uow UnitOfWork) uowOutcome {
 		_, err = session.WriteTransaction(transaction)
 	}
 
+	var retries int64
+	if attempts > 1 {
+		retries = attempts - 1
+	}
 	if err != nil {
 		return uowOutcome{
 			succeeded:    false,
+			readonly:     uow.Readonly,
 			failureGroup: groupError(err),
 			err:          err,
+			retries:      retries,
 		}
 	}
 
-	return uowOutcome{succeeded: true}
+	queries := int64(len(uow.Statements))
+	if firstAttempt.IsZero() {
+		return uowOutcome{succeeded: true, readonly: uow.Readonly, queries: queries}
+	}
+	return uowOutcome{
+		succeeded:      true,
+		readonly:       uow.Readonly,
+		retries:        retries,
+		acquireLatency: firstAttempt.Sub(start),
+		retryLatency:   lastAttempt.Sub(firstAttempt),
+		queryLatency:   w.now().Sub(lastAttempt),
+		queries:        queries,
+		records:        records,
+		bytes:          bytes,
+	}
 }
 
 // Converts a total target rate into a per-client "pacing" duration, used to slow down workers to match
@@ -146,6 +218,43 @@ func TotalRatePerSecondToDurationPerClient(numClients int, rate float64) time.Du
 	return time.Duration(1000*1000/ratePerWorkerPerSecond) * time.Microsecond
 }
 
+// Splits a total number of transactions between clients as evenly as it goes, for runs that go by count rather
+// than by time; the first clients get one more when it doesn't divide evenly
+func TotalTransactionsToTransactionsPerClient(numClients int, transactions uint64) []uint64 {
+	shares := make([]uint64, numClients)
+	for i := range shares {
+		shares[i] = transactions / uint64(numClients)
+		if uint64(i) < transactions%uint64(numClients) {
+			shares[i]++
+		}
+	}
+	return shares
+}
+
+// Reports how far each client is through its share of a run that goes by count, see
+// TotalTransactionsToTransactionsPerClient, for outputs that list the progress of each worker; recorders and
+// shares are by client
+func ReportClientProgress(out Output, recorders []*ResultRecorder, shares []uint64) {
+	var total uint64
+	for _, share := range shares {
+		total += share
+	}
+	// Padded, so workers sort in client order
+	width := len(strconv.Itoa(len(recorders) - 1))
+	for i, r := range recorders {
+		if shares[i] == 0 {
+			continue
+		}
+		out.ReportProgress(ProgressReport{
+			Section:      "run",
+			Step:         "transactions",
+			Completeness: float64(r.Transactions()) / float64(shares[i]),
+			Worker:       fmt.Sprintf("client %0*d", width, i),
+			Share:        float64(shares[i]) / float64(total),
+		})
+	}
+}
+
 // Concurrent data structure; used by the worker to record progress, accessible from other threads
 // to read progress checkpoints.
 type ResultRecorder struct {
@@ -155,28 +264,83 @@ type ResultRecorder struct {
 	current      WorkerResult
 	currentStart time.Time
 
+	// Stats since last sample, read and reset by calling SampleReport; kept apart from the progress
+	// report stats, since the two are read on different schedules
+	sample      WorkerResult
+	sampleStart time.Time
+
 	// Total since the workload started
 	total      WorkerResult
 	totalStart time.Time
+
+	// Every transaction is written here too if set, see TraceWriter
+	Trace *TraceWriter
+	// Counts transactions in flight if set, see ConcurrencyTracker
+	Concurrency *ConcurrencyTracker
+	// Times connection acquisition if set, see ConnectionPool
+	Pool *ConnectionPool
+	// How many of the slowest transactions to keep, see WorkerResult.Slowest; zero keeps none
+	TopSlow int
+	// Count the records transactions return and estimate their size, see ScriptResult.Records
+	CountRecords bool
+	// What latencies are recorded with, see HistogramConfig
+	histogram HistogramConfig
 }
 
-func NewResultRecorder(workerId int64) *ResultRecorder {
-	return &ResultRecorder{
-		current: NewWorkerResult(workerId),
-		total:   NewWorkerResult(workerId),
-	}
+// Records latencies with histogram, the zero value is DefaultHistogramConfig
+func NewResultRecorder(workerId int64, histogram HistogramConfig) *ResultRecorder {
+	t := &ResultRecorder{histogram: histogram}
+	t.current, t.sample, t.total = t.newWorkerResult(workerId), t.newWorkerResult(workerId), t.newWorkerResult(workerId)
+	return t
+}
+
+func (t *ResultRecorder) newWorkerResult(workerId int64) WorkerResult {
+	result := NewWorkerResult(workerId)
+	result.Histogram = t.histogram
+	return result
 }
 
 func (t *ResultRecorder) record(scriptName string, latency time.Duration, outcome uowOutcome) error {
 	t.mut.Lock()
 	defer t.mut.Unlock()
-
 	if err := t.current.record(scriptName, latency, outcome); err != nil {
 		return err
 	}
+	if err := t.sample.record(scriptName, latency, outcome); err != nil {
+		return err
+	}
 	return t.total.record(scriptName, latency, outcome)
 }
 
+// Transactions recorded since the workload started, succeeded or failed
+func (t *ResultRecorder) Transactions() int64 {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	var n int64
+	for _, script := range t.total.Scripts {
+		n += script.Succeeded + script.Failed
+	}
+	return n
+}
+
+// Only the total keeps the slowest transactions, progress reports and samples don't show them
+func (t *ResultRecorder) recordSlow(uow UnitOfWork, start time.Time, latency time.Duration, succeeded bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	slowest := t.total.Slowest
+	// Most transactions are faster than the ones kept, so they're turned away before their parameters are formatted
+	if len(slowest) >= t.TopSlow && latency <= slowest[len(slowest)-1].Latency {
+		return
+	}
+	t.total.Slowest = keepSlowest(append(slowest, SlowTransaction{
+		ScriptName: uow.ScriptName,
+		Start:      start,
+		Latency:    latency,
+		Succeeded:  succeeded,
+		Parameters: formatParameters(uow.Statements),
+	}), t.TopSlow)
+}
+
 // Reports progress since last time you called this function
 func (t *ResultRecorder) ProgressReport(now time.Time) WorkerResult {
 	t.mut.Lock()
@@ -187,12 +351,28 @@ func (t *ResultRecorder) ProgressReport(now time.Time) WorkerResult {
 	delta := now.Sub(t.currentStart)
 	out.calculateRate(delta)
 
-	t.current = NewWorkerResult(out.WorkerId)
+	t.current = t.newWorkerResult(out.WorkerId)
 	t.currentStart = now
 
 	return out
 }
 
+// Reports stats since last time you called this function, for per-interval samples
+func (t *ResultRecorder) SampleReport(now time.Time) WorkerResult {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	out := t.sample
+
+	delta := now.Sub(t.sampleStart)
+	out.calculateRate(delta)
+
+	t.sample = t.newWorkerResult(out.WorkerId)
+	t.sampleStart = now
+
+	return out
+}
+
 func (t *ResultRecorder) Complete(now time.Time) WorkerResult {
 	t.mut.Lock()
 	defer t.mut.Unlock()
@@ -204,7 +384,7 @@ func (t *ResultRecorder) Complete(now time.Time) WorkerResult {
 
 	// Not needed at the time of writing this, but since we're returning pointers
 	// (the maps etc inside t.total), clear this structures references before we exit the mutex
-	t.total = NewWorkerResult(out.WorkerId)
+	t.total = t.newWorkerResult(out.WorkerId)
 	t.totalStart = now
 
 	return out
@@ -230,6 +410,12 @@ type WorkerResult struct {
 
 	// Failure counts by cause
 	FailedByErrorGroup map[string]FailureGroup
+
+	// The slowest transactions, slowest first; empty unless ResultRecorder.TopSlow is set
+	Slowest []SlowTransaction
+
+	// What scripts' latencies are recorded with, the zero value is DefaultHistogramConfig
+	Histogram HistogramConfig
 }
 
 func (r *WorkerResult) getOrCreateScriptResult(scriptName string) *ScriptResult {
@@ -239,27 +425,110 @@ func (r *WorkerResult) getOrCreateScriptResult(scriptName string) *ScriptResult
 	}
 	stats = &ScriptResult{
 		ScriptName: scriptName,
-		Latencies:  hdrhistogram.New(0, 60*60*1000000, 5),
+		Latencies:  r.Histogram.orDefault().newHistogram(),
 	}
 	r.Scripts[scriptName] = stats
 	return stats
 }
 
+// Range and resolution latencies are recorded with, in microseconds; see LatencyUnit. A histogram takes memory
+// in proportion to ten to the power of its significant figures, times the log of its Max over its Min, so a
+// narrow range affords more figures. Results only merge with results recorded with the same config.
+type HistogramConfig struct {
+	// Latencies outside of these are recorded as the nearest of them, and counted in ScriptResult.OutOfRange.
+	// Min is also the smallest difference told apart, whatever the significant figures, so it trades
+	// resolution for memory; zero tells microseconds apart
+	Min time.Duration
+	Max time.Duration
+	// Latencies are accurate to this many significant figures, 1 to MaxSignificantFigures
+	SignificantFigures int
+}
+
+// From 0 up to one hour, with three significant figures; scripts used to be recorded with five, but their total
+// with three, and now the two use the same so merging one into the other loses nothing
+var DefaultHistogramConfig = HistogramConfig{Min: 0, Max: time.Hour, SignificantFigures: 3}
+
+// The most significant figures a histogram can record with
+const MaxSignificantFigures = 5
+
+// Fails unless histograms can be created with c
+func (c HistogramConfig) Validate() error {
+	if c.SignificantFigures < 1 || c.SignificantFigures > MaxSignificantFigures {
+		return fmt.Errorf("invalid significant figures: %d, needs to be between 1 and %d", c.SignificantFigures, MaxSignificantFigures)
+	}
+	if c.Min < 0 {
+		return fmt.Errorf("invalid histogram min: %s, can't be negative", c.Min)
+	}
+	// HdrHistogram needs the highest value to be at least twice the lowest
+	if c.Max < time.Microsecond || c.Max.Microseconds() < 2*c.Min.Microseconds() {
+		return fmt.Errorf("invalid histogram max: %s, needs to be at least 1µs and twice the min of %s", c.Max, c.Min)
+	}
+	return nil
+}
+
+func (c HistogramConfig) newHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(c.Min.Microseconds(), c.Max.Microseconds(), c.SignificantFigures)
+}
+
+// The config histo was created with
+func histogramConfigOf(histo *hdrhistogram.Histogram) HistogramConfig {
+	return HistogramConfig{
+		Min:                time.Duration(histo.LowestTrackableValue()) * time.Microsecond,
+		Max:                time.Duration(histo.HighestTrackableValue()) * time.Microsecond,
+		SignificantFigures: int(histo.SignificantFigures()),
+	}
+}
+
+// Latencies are recorded with DefaultHistogramConfig unless a run asks for another
+func newLatencyHistogram() *hdrhistogram.Histogram {
+	return DefaultHistogramConfig.newHistogram()
+}
+
+// The zero value, as in a WorkerResult that wasn't given a config, is DefaultHistogramConfig
+func (c HistogramConfig) orDefault() HistogramConfig {
+	if c == (HistogramConfig{}) {
+		return DefaultHistogramConfig
+	}
+	return c
+}
+
 func (r *WorkerResult) record(scriptName string, latency time.Duration, outcome uowOutcome) error {
 	stats, found := r.Scripts[scriptName]
 	if !found {
 		stats = &ScriptResult{
 			ScriptName: scriptName,
-			Latencies:  hdrhistogram.New(0, 60*60*1000000, 3),
+			Latencies:  r.Histogram.orDefault().newHistogram(),
 		}
 		r.Scripts[scriptName] = stats
 	}
 
+	stats.Retries += outcome.retries
 	if outcome.succeeded {
 		stats.Succeeded++
-		if err := stats.Latencies.RecordValue(latency.Microseconds()); err != nil {
+		stats.Queries += outcome.queries
+		stats.Records += outcome.records
+		stats.Bytes += outcome.bytes
+		outOfRange, err := recordClamped(stats.Latencies, latency)
+		if err != nil {
 			return errors.Wrapf(err, "failed to record latency: %s", latency)
 		}
+		if outOfRange {
+			stats.OutOfRange++
+		}
+		if err := stats.recordAccessMode(outcome.accessMode(), latency); err != nil {
+			return err
+		}
+		if outcome.acquireLatency > 0 || outcome.queryLatency > 0 {
+			if err := stats.recordPhase(PhaseConnectionAcquire, outcome.acquireLatency); err != nil {
+				return err
+			}
+			if err := stats.recordPhase(PhaseQuery, outcome.queryLatency); err != nil {
+				return err
+			}
+			if err := stats.recordWithoutRetries(latency - outcome.retryLatency); err != nil {
+				return err
+			}
+		}
 	} else {
 		stats.Failed++
 		failedGroup, found := r.FailedByErrorGroup[outcome.failureGroup]
@@ -283,6 +552,9 @@ func (r *WorkerResult) record(scriptName string, latency time.Duration, outcome
 func (r *WorkerResult) calculateRate(delta time.Duration) {
 	for _, script := range r.Scripts {
 		script.Rate = (float64(script.Succeeded+script.Failed) / float64(delta.Microseconds())) * 1000 * 1000
+		script.QueryRate = (float64(script.Queries) / float64(delta.Microseconds())) * 1000 * 1000
+		script.RecordRate = (float64(script.Records) / float64(delta.Microseconds())) * 1000 * 1000
+		script.ByteRate = (float64(script.Bytes) / float64(delta.Microseconds())) * 1000 * 1000
 	}
 }
 
@@ -302,9 +574,29 @@ func groupError(err error) string {
 
 type uowOutcome struct {
 	succeeded bool
+	// Whether the unit of work ran in a read transaction rather than a write transaction
+	readonly bool
 	// An opaque string used to group errors; we track counts for each unique string
 	failureGroup string
 	err          error
+	// Attempts the driver retried, after transient failures, before the unit of work succeeded or gave up
+	retries int64
+	// Time spent in each phase of a successful unit of work, zero if the phases weren't measured
+	acquireLatency time.Duration
+	queryLatency   time.Duration
+	// Time from the first attempt to the last, spent on attempts the driver retried and waiting between them
+	retryLatency time.Duration
+	// Statements the unit of work ran, and records they returned with their approximate size, if it succeeded
+	queries int64
+	records int64
+	bytes   int64
+}
+
+func (o uowOutcome) accessMode() string {
+	if o.readonly {
+		return AccessModeRead
+	}
+	return AccessModeWrite
 }
 
 func NewWorker(driver neo4j.Driver, workerId int64) *Worker {
@@ -315,3 +607,48 @@ func NewWorker(driver neo4j.Driver, workerId int64) *Worker {
 		sleep:    time.Sleep,
 	}
 }
+
+// Rough size of a value returned by the driver, in bytes: the length of strings and byte arrays, eight bytes
+// per number or id, and the sum of the parts of anything bigger. The driver doesn't tell us what actually went
+// over the wire, but this tracks it well enough to tell a workload moving kilobytes from one moving megabytes
+func approxValueSize(v interface{}) int64 {
+	switch v := v.(type) {
+	case nil, bool:
+		return 1
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case []interface{}:
+		var n int64
+		for _, item := range v {
+			n += approxValueSize(item)
+		}
+		return n
+	case map[string]interface{}:
+		var n int64
+		for key, item := range v {
+			n += int64(len(key)) + approxValueSize(item)
+		}
+		return n
+	case neo4j.Node:
+		n := 8 + approxValueSize(v.Props())
+		for _, label := range v.Labels() {
+			n += int64(len(label))
+		}
+		return n
+	case neo4j.Relationship:
+		return 24 + int64(len(v.Type())) + approxValueSize(v.Props())
+	case neo4j.Path:
+		var n int64
+		for _, node := range v.Nodes() {
+			n += approxValueSize(node)
+		}
+		for _, rel := range v.Relationships() {
+			n += approxValueSize(rel)
+		}
+		return n
+	}
+	// Numbers, and temporal and spatial values, which are a few numbers each
+	return 8
+}
@@ -1,11 +1,15 @@
 package neobench
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/codahale/hdrhistogram"
 	"github.com/neo4j/neo4j-go-driver/neo4j"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -28,7 +32,7 @@ func TestMaintainsRateInFaceOfFailure(t *testing.T) {
 		now:      clock.now,
 		sleep:    clock.sleep,
 	}
-	rec := NewResultRecorder(0)
+	rec := NewResultRecorder(0, HistogramConfig{})
 
 	targetRatePerSecond := float64(1)
 	txDuration := TotalRatePerSecondToDurationPerClient(1, targetRatePerSecond)
@@ -40,6 +44,61 @@ func TestMaintainsRateInFaceOfFailure(t *testing.T) {
 	assert.InDelta(t, targetRatePerSecond, sr.Rate, 0.1)
 }
 
+func TestRunsByCountStopOnceEveryClientIsThroughItsShare(t *testing.T) {
+	assert.Equal(t, []uint64{4, 3, 3}, TotalTransactionsToTransactionsPerClient(3, 10))
+	assert.Equal(t, []uint64{0, 0}, TotalTransactionsToTransactionsPerClient(2, 0))
+
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{currentTime: time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)}
+	driver := &fakeDriver{clock: clock, r: r, minLatency: time.Millisecond, maxLatency: 2 * time.Millisecond}
+	result := NewResult("db", "")
+	for i, share := range TotalTransactionsToTransactionsPerClient(3, 10) {
+		w := Worker{workerId: int64(i), driver: driver, now: clock.now, sleep: clock.sleep}
+		result.Add(w.RunBenchmark(newTestWorkload(r), "", 0, share, make(chan struct{}), NewResultRecorder(int64(i), HistogramConfig{})))
+	}
+	assert.Equal(t, int64(10), result.TotalSucceeded()+result.TotalFailed())
+}
+
+func TestSamplesAreIndependentOfProgressReports(t *testing.T) {
+	start := time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	rec := NewResultRecorder(0, HistogramConfig{})
+	rec.currentStart, rec.sampleStart, rec.totalStart = start, start, start
+
+	assert.NoError(t, rec.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	progress := rec.ProgressReport(start.Add(time.Second))
+	assert.NoError(t, rec.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+
+	sample := rec.SampleReport(start.Add(2 * time.Second))
+	assert.Equal(t, int64(1), progress.Scripts["a"].Succeeded)
+	assert.Equal(t, int64(2), sample.Scripts["a"].Succeeded)
+	assert.InDelta(t, 1.0, sample.Scripts["a"].Rate, 0.001)
+	assert.Equal(t, 0, len(rec.SampleReport(start.Add(3*time.Second)).Scripts))
+}
+
+// Latencies are measured from when each transaction was scheduled to start rather than when it did, which is
+// what RecordCorrectedValue approximates after the fact; so a stall shows up in every transaction queued behind it
+func TestStallInflatesTailOfScheduledLatencies(t *testing.T) {
+	clock := &fakeSpaceTimeContinuum{currentTime: time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)}
+	driver := &stallingDriver{
+		fakeDriver:   fakeDriver{clock: clock},
+		latency:      time.Millisecond,
+		stallOn:      500,
+		stall:        time.Second,
+		serviceTimes: newLatencyHistogram(),
+	}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleep}
+	rec := NewResultRecorder(0, HistogramConfig{})
+
+	// 100 transactions per second, so the one second stall holds up the hundred or so scheduled during it
+	result := w.RunBenchmark(newTestWorkload(rand.New(rand.NewSource(1337))), "", 10*time.Millisecond, 1000, make(chan struct{}), rec)
+
+	assert.NoError(t, result.Error)
+	latencies := result.Scripts["workertest"].Latencies
+	assert.Greater(t, valueAtPercentile(latencies, 95), int64(500*1000))
+	assert.Less(t, valueAtPercentile(driver.serviceTimes, 95), int64(2*1000))
+	assert.Equal(t, int64(1000), driver.serviceTimes.TotalCount())
+}
+
 func newTestWorkload(r *rand.Rand) ClientWorkload {
 	script, err := Parse("workertest", `RETURN 1;`, 1)
 	if err != nil {
@@ -125,3 +184,417 @@ func (d *fakeDriver) Run(cypher string, params map[string]interface{}, configure
 var _ neo4j.Driver = &fakeDriver{}
 
 var _ neo4j.Session = &fakeDriver{}
+
+func TestLatenciesOutOfRangeAreClampedAndCounted(t *testing.T) {
+	wr := NewWorkerResult(0)
+
+	assert.NoError(t, wr.record("a", 2*time.Hour, uowOutcome{succeeded: true, acquireLatency: time.Millisecond, queryLatency: 2 * time.Hour}))
+	assert.NoError(t, wr.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+
+	script := wr.Scripts["a"]
+	assert.Equal(t, int64(1), script.OutOfRange)
+	assert.Equal(t, int64(2), script.Latencies.TotalCount())
+	// Max is the highest value equivalent to the one recorded, within the histogram's precision
+	assert.InDelta(t, time.Hour.Seconds(), (time.Duration(script.Latencies.Max()) * time.Microsecond).Seconds(), 1)
+	assert.Equal(t, int64(1), script.Phases[PhaseQuery].TotalCount())
+
+	result := NewResult("db", "-c 1")
+	result.Add(wr)
+	result.Add(wr)
+	assert.Equal(t, int64(2), result.TotalOutOfRange())
+	assert.Equal(t, int64(2), result.Total().OutOfRange)
+}
+
+func TestCountsQueriesOfSuccessfulTransactions(t *testing.T) {
+	rec := NewResultRecorder(0, HistogramConfig{})
+	start := time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	rec.currentStart, rec.sampleStart, rec.totalStart = start, start, start
+
+	assert.NoError(t, rec.record("a", time.Millisecond, uowOutcome{succeeded: true, queries: 3}))
+	assert.NoError(t, rec.record("a", time.Millisecond, uowOutcome{succeeded: true, queries: 3}))
+	assert.NoError(t, rec.record("a", time.Millisecond, uowOutcome{succeeded: false, queries: 3}))
+
+	script := rec.Complete(start.Add(2 * time.Second)).Scripts["a"]
+	assert.Equal(t, int64(6), script.Queries)
+	assert.InDelta(t, 3.0, script.QueryRate, 0.001)
+	assert.InDelta(t, 1.5, script.Rate, 0.001)
+}
+
+// Takes a fixed time for every transaction except one, which stalls; records how long each actually took
+type stallingDriver struct {
+	fakeDriver
+	latency      time.Duration
+	stallOn      int
+	stall        time.Duration
+	calls        int
+	serviceTimes *hdrhistogram.Histogram
+}
+
+func (d *stallingDriver) NewSession(config neo4j.SessionConfig) (neo4j.Session, error) {
+	return d, nil
+}
+
+func (d *stallingDriver) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	d.calls++
+	latency := d.latency
+	if d.calls == d.stallOn {
+		latency = d.stall
+	}
+	d.clock.sleep(latency)
+	return nil, d.serviceTimes.RecordValue(latency.Microseconds())
+}
+
+func TestCountsRecordsAndTheirApproximateSize(t *testing.T) {
+	clock := &fakeSpaceTimeContinuum{currentTime: time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)}
+	driver := &recordsDriver{fakeDriver: fakeDriver{clock: clock}, values: [][]interface{}{
+		{int64(1), "hello"},
+		{[]interface{}{true, nil}, map[string]interface{}{"k": "value"}},
+	}}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleep}
+
+	outcome := w.runUnit(driver, UnitOfWork{Statements: []Statement{{Query: "RETURN 1"}, {Query: "RETURN 2"}}}, true)
+
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, int64(4), outcome.records)
+	assert.Equal(t, 2*(8+5+2+6), int(outcome.bytes))
+
+	// Records aren't read unless asked for, they're consumed with the rest of the result
+	outcome = w.runUnit(driver, UnitOfWork{Statements: []Statement{{Query: "RETURN 1"}, {Query: "RETURN 2"}}}, false)
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, int64(0), outcome.records)
+	assert.Equal(t, int64(0), outcome.bytes)
+}
+
+// Returns the same records for every statement
+type recordsDriver struct {
+	fakeDriver
+	values [][]interface{}
+}
+
+func (d *recordsDriver) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	return work(&fakeTransaction{values: d.values})
+}
+
+type fakeTransaction struct {
+	values [][]interface{}
+}
+
+func (tx *fakeTransaction) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	return &fakeResult{values: tx.values, next: -1}, nil
+}
+
+func (tx *fakeTransaction) Commit() error {
+	return nil
+}
+
+func (tx *fakeTransaction) Rollback() error {
+	return nil
+}
+
+func (tx *fakeTransaction) Close() error {
+	return nil
+}
+
+var _ neo4j.Transaction = &fakeTransaction{}
+
+type fakeResult struct {
+	values [][]interface{}
+	next   int
+}
+
+func (r *fakeResult) Keys() ([]string, error) {
+	panic("implement me")
+}
+
+func (r *fakeResult) Next() bool {
+	r.next++
+	return r.next < len(r.values)
+}
+
+func (r *fakeResult) Err() error {
+	return nil
+}
+
+func (r *fakeResult) Record() neo4j.Record {
+	return fakeRecord(r.values[r.next])
+}
+
+func (r *fakeResult) Summary() (neo4j.ResultSummary, error) {
+	panic("implement me")
+}
+
+func (r *fakeResult) Consume() (neo4j.ResultSummary, error) {
+	return nil, nil
+}
+
+type fakeRecord []interface{}
+
+func (r fakeRecord) Keys() []string {
+	panic("implement me")
+}
+
+func (r fakeRecord) Values() []interface{} {
+	return r
+}
+
+func (r fakeRecord) Get(key string) (interface{}, bool) {
+	panic("implement me")
+}
+
+func (r fakeRecord) GetByIndex(index int) interface{} {
+	return r[index]
+}
+
+func TestTraceRecordsEveryTransactionFromItsScheduledStart(t *testing.T) {
+	start := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	clock := &fakeSpaceTimeContinuum{currentTime: start}
+	driver := &stallingDriver{
+		fakeDriver:   fakeDriver{clock: clock},
+		latency:      time.Millisecond,
+		stallOn:      2,
+		stall:        time.Second,
+		serviceTimes: newLatencyHistogram(),
+	}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleep}
+	buf := &bytes.Buffer{}
+	rec := NewResultRecorder(0, HistogramConfig{})
+	rec.Trace = NewTraceWriter(buf)
+
+	result := w.RunBenchmark(newTestWorkload(rand.New(rand.NewSource(1337))), "", 10*time.Millisecond, 3, make(chan struct{}), rec)
+	assert.NoError(t, result.Error)
+	assert.NoError(t, rec.Trace.Flush())
+
+	rows := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, "timestamp_ns,latency_us,script,succeeded", rows[0])
+	assert.Equal(t, 4, len(rows))
+	// The third transaction was due 20ms in, but waited behind the stalled second one
+	third := strings.Split(rows[3], ",")
+	assert.Equal(t, fmt.Sprint(start.Add(20*time.Millisecond).UnixNano()), third[0])
+	latency, err := strconv.ParseInt(third[1], 10, 64)
+	assert.NoError(t, err)
+	assert.Greater(t, latency, int64(900*1000))
+	assert.Equal(t, []string{`"workertest"`, "true"}, third[2:])
+}
+
+// With a target rate workers sit idle between transactions, so far fewer are in flight than there are clients
+func TestTracksTransactionsInFlight(t *testing.T) {
+	start := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	clock := &fakeSpaceTimeContinuum{currentTime: start}
+	driver := &stallingDriver{fakeDriver: fakeDriver{clock: clock}, latency: time.Millisecond, serviceTimes: newLatencyHistogram()}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleep}
+	rec := NewResultRecorder(0, HistogramConfig{})
+	rec.Concurrency = NewConcurrencyTracker()
+	// Another worker, halfway through a transaction of its own
+	rec.Concurrency.begin()
+
+	// 1ms transactions every 10ms
+	result := w.RunBenchmark(newTestWorkload(rand.New(rand.NewSource(1337))), "", 10*time.Millisecond, 100, make(chan struct{}), rec)
+	assert.NoError(t, result.Error)
+	rec.Concurrency.end(clock.now().Sub(start) / 2)
+
+	c := rec.Concurrency.Summary(clock.now().Sub(start))
+	assert.Equal(t, 2, c.Max)
+	assert.InDelta(t, 0.1+0.5, c.Mean, 0.01)
+	assert.Nil(t, rec.Concurrency.Summary(0))
+}
+
+// Calls the transaction function once per attempt, like the driver does when it retries transient failures
+type retryingDriver struct {
+	fakeDriver
+	attempts       int
+	attemptLatency time.Duration
+}
+
+func (d *retryingDriver) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	var err error
+	for i := 0; i < d.attempts; i++ {
+		_, err = work(&fakeTransaction{})
+		d.clock.sleep(d.attemptLatency)
+	}
+	return nil, err
+}
+
+func TestCountsRetriesAndTimesTheSuccessfulAttemptApart(t *testing.T) {
+	clock := &fakeSpaceTimeContinuum{currentTime: time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)}
+	driver := &retryingDriver{fakeDriver: fakeDriver{clock: clock}, attempts: 3, attemptLatency: 10 * time.Millisecond}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleep}
+
+	outcome := w.runUnit(driver, UnitOfWork{ScriptName: "a", Statements: []Statement{{Query: "RETURN 1"}}}, false)
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, int64(2), outcome.retries)
+	// The attempts before it are in the latency as a whole, not in the query phase
+	assert.Equal(t, 10*time.Millisecond, outcome.queryLatency)
+	assert.Equal(t, 20*time.Millisecond, outcome.retryLatency)
+
+	driver.attempts = 1
+	assert.Equal(t, int64(0), w.runUnit(driver, UnitOfWork{ScriptName: "a"}, false).retries)
+
+	wr := NewWorkerResult(0)
+	// Started 5ms late, which stays in the latency without retries, unlike in the query phase
+	assert.NoError(t, wr.record("a", 35*time.Millisecond, outcome))
+	assert.NoError(t, wr.record("a", 30*time.Millisecond, uowOutcome{succeeded: false, retries: 1, failureGroup: "unknown"}))
+	result := NewResult("db", "-c 1")
+	result.Add(wr)
+	assert.Equal(t, int64(3), result.TotalRetries())
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, (&InteractiveOutput{OutStream: buf, ErrStream: &bytes.Buffer{}}).ReportLatency(result))
+	assert.Contains(t, buf.String(), "Retries: 3 (1.500 per transaction)\n")
+	assert.Contains(t, buf.String(), "  Retries: 3, latency including retries: mean 34.992ms, P99 35.007ms\n"+
+		"    Without retries: mean 15.004ms, P99 15.007ms\n")
+
+	buf.Reset()
+	assert.NoError(t, (&JsonOutput{OutStream: buf, ErrStream: &bytes.Buffer{}, Percentiles: []float64{50}}).ReportLatency(result))
+	assert.Contains(t, buf.String(), `"latency_without_retries":{"min_ms":15`)
+}
+
+func TestConcurrencyRestartsAfterTheWarmup(t *testing.T) {
+	c := NewConcurrencyTracker()
+	c.begin()
+	c.begin()
+	c.end(time.Second)
+
+	warmup := c.Restart(time.Second)
+	assert.Equal(t, 2, warmup.Max)
+	assert.InDelta(t, 1, warmup.Mean, 0.001)
+
+	// The transaction still in flight carries over
+	c.end(time.Second)
+	measured := c.Summary(2 * time.Second)
+	assert.Equal(t, 1, measured.Max)
+	assert.InDelta(t, 0.5, measured.Mean, 0.001)
+}
+
+func TestRecordsLatenciesWithTheConfiguredHistogram(t *testing.T) {
+	config := HistogramConfig{Min: time.Microsecond, Max: 10 * time.Second, SignificantFigures: 4}
+	rec := NewResultRecorder(0, config)
+	assert.NoError(t, rec.record("a", 12345*time.Microsecond, uowOutcome{succeeded: true, queryLatency: time.Millisecond}))
+	assert.NoError(t, rec.record("a", time.Minute, uowOutcome{succeeded: true}))
+
+	result := NewResult("db", "")
+	result.Add(rec.Complete(time.Now()))
+	assert.Equal(t, config, result.HistogramConfig())
+	assert.Equal(t, config, histogramConfigOf(result.Total().Latencies))
+	assert.Equal(t, config, histogramConfigOf(result.Scripts["a"].Phases[PhaseQuery]))
+	// Four figures tell 12345us apart from its neighbours, the default three wouldn't
+	assert.Equal(t, int64(12345), result.Scripts["a"].Latencies.Min())
+	assert.Equal(t, int64(1), result.Scripts["a"].OutOfRange)
+	// Results made for whatever is recorded next keep the config
+	assert.NoError(t, rec.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	assert.Equal(t, config, histogramConfigOf(rec.Complete(time.Now()).Scripts["a"].Latencies))
+
+	// Recorded with the default unless configured
+	empty := NewResult("db", "")
+	assert.Equal(t, DefaultHistogramConfig, empty.HistogramConfig())
+	other := NewResultRecorder(1, HistogramConfig{})
+	assert.NoError(t, other.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	assert.Equal(t, DefaultHistogramConfig, histogramConfigOf(other.Complete(time.Now()).Scripts["a"].Latencies))
+}
+
+func TestValidatesHistogramConfig(t *testing.T) {
+	assert.NoError(t, DefaultHistogramConfig.Validate())
+	assert.NoError(t, HistogramConfig{Min: time.Millisecond, Max: 2 * time.Millisecond, SignificantFigures: 5}.Validate())
+	assert.EqualError(t, HistogramConfig{Max: time.Hour, SignificantFigures: 6}.Validate(),
+		"invalid significant figures: 6, needs to be between 1 and 5")
+	assert.EqualError(t, HistogramConfig{Min: -time.Second, Max: time.Hour, SignificantFigures: 3}.Validate(),
+		"invalid histogram min: -1s, can't be negative")
+	assert.EqualError(t, HistogramConfig{Min: time.Second, Max: 1500 * time.Millisecond, SignificantFigures: 3}.Validate(),
+		"invalid histogram max: 1.5s, needs to be at least 1µs and twice the min of 1s")
+}
+
+func TestScriptsAndTheirTotalAreRecordedWithThreeSignificantFiguresByDefault(t *testing.T) {
+	rec := NewResultRecorder(0, HistogramConfig{})
+	assert.NoError(t, rec.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	result := NewResult("db", "")
+	result.Add(rec.Complete(time.Now()))
+	assert.Equal(t, int64(3), result.Scripts["a"].Latencies.SignificantFigures())
+	assert.Equal(t, int64(3), result.Total().Latencies.SignificantFigures())
+	assert.Equal(t, int64(3), newLatencyHistogram().SignificantFigures())
+}
+
+func TestTracksClientCpuUtilization(t *testing.T) {
+	now, cpu := time.Unix(0, 0), time.Duration(0)
+	tracker := newCpuTracker(2, func() time.Time { return now }, func() (time.Duration, error) { return cpu, nil })
+	// Both CPUs busy for a second, then idle for three
+	now, cpu = now.Add(time.Second), cpu+2*time.Second
+	tracker.sample()
+	now = now.Add(3 * time.Second)
+	summary := tracker.Restart()
+	assert.Equal(t, &ClientCpu{Cpus: 2, Time: 2 * time.Second, Mean: 0.25, Peak: 1}, summary)
+
+	// Counted over after a restart, eg. at the end of warmup
+	now, cpu = now.Add(2*time.Second), cpu+time.Second
+	assert.Equal(t, &ClientCpu{Cpus: 2, Time: time.Second, Mean: 0.25, Peak: 0.25}, tracker.Summary())
+
+	unreadable := newCpuTracker(2, time.Now, func() (time.Duration, error) { return 0, fmt.Errorf("not supported") })
+	assert.Nil(t, unreadable.Summary())
+}
+
+// Takes a while to hand out a connection before calling the transaction function, like a driver whose pool is
+// exhausted; every fifth transaction waits longer
+type acquiringDriver struct {
+	fakeDriver
+	acquire time.Duration
+	calls   int
+}
+
+func (d *acquiringDriver) NewSession(config neo4j.SessionConfig) (neo4j.Session, error) {
+	return d, nil
+}
+
+func (d *acquiringDriver) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	d.calls++
+	d.clock.sleep(d.acquire)
+	if d.calls%5 == 0 {
+		d.clock.sleep(4 * d.acquire)
+	}
+	return work(&fakeTransaction{})
+}
+
+func TestTimesConnectionAcquisitionAsTheDriverDoesIt(t *testing.T) {
+	clock := &fakeSpaceTimeContinuum{currentTime: time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)}
+	driver := &acquiringDriver{fakeDriver: fakeDriver{clock: clock}, acquire: time.Millisecond}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleep}
+	rec := NewResultRecorder(0, HistogramConfig{})
+	rec.Pool = NewConnectionPool(1)
+
+	// Many clients can share one connection per server, nothing in neobench holds them back
+	result := w.RunBenchmark(newTestWorkload(rand.New(rand.NewSource(1337))), "", 10*time.Millisecond, 100, make(chan struct{}), rec)
+	assert.NoError(t, result.Error)
+	assert.Equal(t, &PoolWait{Size: 1, Transactions: 100, Mean: 1800 * time.Microsecond, Max: 5 * time.Millisecond}, rec.Pool.Restart())
+	assert.Equal(t, &PoolWait{Size: 1}, rec.Pool.Summary())
+}
+
+func TestKeepsTheSlowestTransactions(t *testing.T) {
+	start := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	slow := func(name string, id int) UnitOfWork {
+		return UnitOfWork{ScriptName: name, Statements: []Statement{
+			{Query: "MATCH (n {id: $id}) RETURN n", Params: map[string]interface{}{"id": id, "scale": 1}},
+			{Query: "CREATE (:Log {text: $text})", Params: map[string]interface{}{"id": id, "text": strings.Repeat("x", 50)}},
+		}}
+	}
+	rec := NewResultRecorder(0, HistogramConfig{})
+	rec.TopSlow = 2
+	rec.recordSlow(slow("a.script", 1), start, time.Millisecond, true)
+	rec.recordSlow(slow("a.script", 2), start, 5*time.Millisecond, false)
+	rec.recordSlow(slow("b.script", 3), start, 3*time.Millisecond, true)
+	rec.recordSlow(slow("b.script", 4), start, 2*time.Millisecond, true)
+	first := rec.Complete(start)
+	text := "text=" + strings.Repeat("x", 37) + "..."
+	assert.Equal(t, []SlowTransaction{
+		{ScriptName: "a.script", Start: start, Latency: 5 * time.Millisecond, Parameters: "id=2, scale=1, " + text},
+		{ScriptName: "b.script", Start: start, Latency: 3 * time.Millisecond, Succeeded: true, Parameters: "id=3, scale=1, " + text},
+	}, first.Slowest)
+
+	// Across workers, the run keeps as many as each worker did
+	other := NewResultRecorder(1, HistogramConfig{})
+	other.TopSlow = 2
+	other.recordSlow(slow("a.script", 5), start, 4*time.Millisecond, true)
+	other.recordSlow(slow("a.script", 6), start, time.Millisecond, true)
+	result := NewResult("db", "-c 2")
+	result.Add(first)
+	result.Add(other.Complete(start))
+	assert.Len(t, result.Slowest, 2)
+	assert.Equal(t, 5*time.Millisecond, result.Slowest[0].Latency)
+	assert.Equal(t, 4*time.Millisecond, result.Slowest[1].Latency)
+}